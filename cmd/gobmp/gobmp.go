@@ -2,13 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 
@@ -34,24 +36,103 @@ var (
 	natsSrv           string
 	intercept         string
 	splitAF           string
+	tableTopics       string
+	typedCommunities  string
+	rawTopic          string
+	routerNames       string
+	collectorAdminID  string
 	dump              string
 	file              string
 	storeData         string
+	workers           int
+	queueSize         int
+	shardBy           string
+	activeRouters     string
+	activeBackoffMax  time.Duration
+	tlsCert           string
+	tlsKey            string
+	tlsClientCA       string
+	interceptTLS      string
+	interceptTLSCA    string
+	interceptTLSCert  string
+	interceptTLSKey   string
+	interceptTLSName  string
+	allowedClients    string
+	deniedClients     string
+	maxSessions       int
+	messageRate       int
+	byteRate          int
+	rateLimitAction   string
+	keepAlive         time.Duration
+	interceptDsts     string
+	interceptBuffer   int
+	proxyFilter       string
+	relayTo           string
+	relayPort         int
+	relayTLS          string
+	relayTLSCA        string
+	relayTLSCert      string
+	relayTLSKey       string
+	relayTLSName      string
+	relayListenerCert string
+	relayListenerKey  string
+	relayListenerCA   string
+	relayBuffer       int
+	idleTimeout       time.Duration
 )
 
 func init() {
-	runtime.GOMAXPROCS(1)
 	flag.IntVar(&srcPort, "source-port", 5000, "port exposed to outside")
 	flag.IntVar(&dstPort, "destination-port", 5050, "port openBMP is listening")
 	flag.StringVar(&kafkaSrv, "kafka-server", "", "URL to access Kafka server")
 	flag.StringVar(&kafkaTpRetnTimeMs, "kafka-topic-retention-time-ms", "900000", "Kafka topic retention time in ms, default is 900000 ms i.e 15 minutes")
 	flag.StringVar(&natsSrv, "nats-server", "", "URL to access NATS server")
 	flag.StringVar(&intercept, "intercept", "false", "When intercept set \"true\", all incomming BMP messges will be copied to TCP port specified by destination-port, otherwise received BMP messages will be published to Kafka.")
+	flag.StringVar(&tableTopics, "table-topics", "false", "When set \"true\", route messages of Loc-RIB instances are published to per table topics, named after the message's topic and the VRF/Table name, for example gobmp.parsed.unicast_prefix_v4.global, kafka and nats only")
+	flag.StringVar(&typedCommunities, "typed-communities", "false", "When set \"true\", base attributes of route messages carry decoded communities, extended communities and large communities in communities, ext_communities and large_communities lists")
+	flag.StringVar(&rawTopic, "raw-topic", "false", "When set \"true\", every received BMP message is published to gobmp.bmp_raw topic prepended with OpenBMP binary header, keyed by the router hash")
+	flag.StringVar(&collectorAdminID, "collector-admin-id", "", "Collector Admin ID of OpenBMP binary header of raw messages, the host name is used when not set")
+	flag.StringVar(&routerNames, "router-names", "", "Comma separated list of router names in ip=name format, the name is published instead of the sysName advertised by the router")
 	flag.StringVar(&splitAF, "split-af", "true", "When set \"true\" (default) ipv4 and ipv6 will be published in separate topics. if set \"false\" the same topic will be used for both address families.")
 	flag.IntVar(&perfPort, "performance-port", 56767, "port used for performance debugging")
 	flag.StringVar(&dump, "dump", "", "Dump resulting messages to file when \"dump=file\", to standard output when \"dump=console\" or to NATS when \"dump=nats\"")
 	flag.StringVar(&file, "msg-file", "/tmp/messages.json", "Full path anf file name to store messages when \"dump=file\"")
 	flag.StringVar(&storeData, "store-data", "false", "When store-data is set to \"true\", the supported (BGP-LS only for now) BMP state will be stored and accesible through API")
+	flag.IntVar(&workers, "workers", 0, "Number of workers parsing BMP messages, when set to 0 (default) the number of usable CPUs is used")
+	flag.IntVar(&queueSize, "queue-size", gobmpsrv.DefaultQueueSize, "Maximum number of BMP messages queued per worker and per BMP session's producer")
+	flag.StringVar(&activeRouters, "active-routers", "", "Comma separated list of routers in host:port format gobmp connects to, for routers supporting only passive BMP mode")
+	flag.DurationVar(&activeBackoffMax, "active-backoff-max", gobmpsrv.DefaultActiveBackoffMax, "Maximum delay between connection attempts to active routers")
+	flag.StringVar(&tlsCert, "tls-cert", "", "PEM encoded certificate of BMP listener, when set together with tls-key, routers must connect with TLS")
+	flag.StringVar(&tlsKey, "tls-key", "", "PEM encoded private key of BMP listener certificate")
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", "PEM encoded CA certificates used to verify routers' certificates, when set routers must present a certificate and its subject is used as the router identity")
+	flag.StringVar(&interceptTLS, "intercept-tls", "false", "When set \"true\", the connection to the intercept destination uses TLS")
+	flag.StringVar(&interceptTLSCA, "intercept-tls-ca", "", "PEM encoded CA certificates used to verify intercept destination's certificate, system CAs are used when not set")
+	flag.StringVar(&interceptTLSCert, "intercept-tls-cert", "", "PEM encoded certificate presented to the intercept destination")
+	flag.StringVar(&interceptTLSKey, "intercept-tls-key", "", "PEM encoded private key of the certificate presented to the intercept destination")
+	flag.StringVar(&interceptTLSName, "intercept-tls-server-name", "localhost", "Name used to verify intercept destination's certificate")
+	flag.StringVar(&allowedClients, "allowed-clients", "", "Comma separated list of CIDRs routers are allowed to open BMP sessions from, all routers are allowed when not set")
+	flag.StringVar(&deniedClients, "denied-clients", "", "Comma separated list of CIDRs routers are not allowed to open BMP sessions from, takes precedence over allowed-clients")
+	flag.IntVar(&maxSessions, "max-sessions", 0, "Maximum number of concurrent BMP sessions accepted from routers, not limited when set to 0 (default)")
+	flag.IntVar(&messageRate, "message-rate", 0, "Maximum number of BMP messages per second received from a router, not limited when set to 0 (default)")
+	flag.IntVar(&byteRate, "byte-rate", 0, "Maximum number of bytes per second received from a router, not limited when set to 0 (default)")
+	flag.StringVar(&rateLimitAction, "rate-limit-action", "backpressure", "Action taken when a router exceeds message-rate or byte-rate, \"backpressure\" (default) stops reading from the router, \"disconnect\" closes the BMP session")
+	flag.DurationVar(&keepAlive, "keepalive", gobmpsrv.DefaultKeepAlive, "Period of TCP keepalive probes on BMP sessions")
+	flag.DurationVar(&idleTimeout, "idle-timeout", 0, "Time after which a BMP session without any received message is closed, never when set to 0 (default)")
+	flag.StringVar(&interceptDsts, "intercept-destinations", "", "Comma separated list of BMP stations in host:port format receiving copies of BMP messages in intercept mode, when not set destination-port on the local host is used")
+	flag.IntVar(&interceptBuffer, "intercept-buffer-size", gobmpsrv.DefaultInterceptBufferSize, "Maximum number of BMP messages buffered per intercept destination while the destination is slow or disconnected")
+	flag.StringVar(&proxyFilter, "proxy-filter", "", "Filter of BMP messages copied to intercept destinations, for example \"keep peer=192.0.2.0/24 afi-safi=1/1; drop msg=stats,route-mirroring\"")
+	flag.StringVar(&relayTo, "relay-to", "", "Address of the core gobmp in host:port format, when set BMP sessions are relayed to the core gobmp instead of being published")
+	flag.IntVar(&relayPort, "relay-port", 0, "Port the core gobmp accepts relayed BMP sessions from edge gobmp on, disabled when set to 0 (default)")
+	flag.StringVar(&relayTLS, "relay-tls", "false", "When set \"true\", the connection to the core gobmp uses TLS configured by relay-tls-ca, relay-tls-cert, relay-tls-key and relay-tls-server-name")
+	flag.StringVar(&relayTLSCA, "relay-tls-ca", "", "PEM encoded CA certificates used to verify core gobmp's certificate, system CAs are used when not set")
+	flag.StringVar(&relayTLSCert, "relay-tls-cert", "", "PEM encoded certificate presented to the core gobmp")
+	flag.StringVar(&relayTLSKey, "relay-tls-key", "", "PEM encoded private key of the certificate presented to the core gobmp")
+	flag.StringVar(&relayTLSName, "relay-tls-server-name", "localhost", "Name used to verify core gobmp's certificate")
+	flag.StringVar(&relayListenerCert, "relay-listener-tls-cert", "", "PEM encoded certificate of the relay listener, required with relay-port")
+	flag.StringVar(&relayListenerKey, "relay-listener-tls-key", "", "PEM encoded private key of the relay listener certificate, required with relay-port")
+	flag.StringVar(&relayListenerCA, "relay-listener-tls-client-ca", "", "PEM encoded CA certificates used to verify edge gobmp's certificates, required with relay-port")
+	flag.IntVar(&relayBuffer, "relay-buffer-size", gobmpsrv.DefaultRelayBufferSize, "Maximum number of BMP messages buffered while the core gobmp is slow or not reachable")
+	flag.StringVar(&shardBy, "shard-by", "peer", "Distribution of BMP messages between workers, \"peer\" (default) processes messages of different peers in parallel, \"router\" processes all messages of a router by the same worker")
 }
 
 func main() {
@@ -64,7 +145,14 @@ func main() {
 	// Initializing publisher
 	var publisher pub.Publisher
 	var err error
-	switch strings.ToLower(dump) {
+	mode := strings.ToLower(dump)
+	if relayTo != "" {
+		mode = "relay"
+	}
+	switch mode {
+	case "relay":
+		// BMP messages are parsed and published by the core gobmp
+		glog.V(5).Infof("relaying BMP sessions to %s, no publisher is initialized.", relayTo)
 	case "file":
 		publisher, err = filer.NewFiler(file)
 		if err != nil {
@@ -110,18 +198,136 @@ func main() {
 		glog.Errorf("failed to parse to bool the value of the intercept flag with error: %+v", err)
 		os.Exit(1)
 	}
+	tableTopicsFlag, err := strconv.ParseBool(tableTopics)
+	if err != nil {
+		glog.Errorf("failed to parse to bool the value of the table-topics flag with error: %+v", err)
+		os.Exit(1)
+	}
+	typedCommunitiesFlag, err := strconv.ParseBool(typedCommunities)
+	if err != nil {
+		glog.Errorf("failed to parse to bool the value of the typed-communities flag with error: %+v", err)
+		os.Exit(1)
+	}
+	rawTopicFlag, err := strconv.ParseBool(rawTopic)
+	if err != nil {
+		glog.Errorf("failed to parse to bool the value of the raw-topic flag with error: %+v", err)
+		os.Exit(1)
+	}
+	routerNamesValue, err := parseRouterNames(routerNames)
+	if err != nil {
+		glog.Errorf("failed to parse the value of the router-names flag with error: %+v", err)
+		os.Exit(1)
+	}
 	storeDataFlag, err := strconv.ParseBool(storeData)
 	if err != nil {
 		glog.Errorf("failed to parse to bool the value of the store-data flag with error: %+v", err)
 		os.Exit(1)
 	}
-	bmpSrv, err := gobmpsrv.NewBMPServer(srcPort, dstPort, interceptFlag, publisher, splitAFFlag, storeDataFlag)
+	shardByValue, err := gobmpsrv.ParseShardBy(shardBy)
+	if err != nil {
+		glog.Errorf("failed to parse the value of the shard-by flag with error: %+v", err)
+		os.Exit(1)
+	}
+	rateLimitActionValue, err := gobmpsrv.ParseRateLimitAction(rateLimitAction)
+	if err != nil {
+		glog.Errorf("failed to parse the value of the rate-limit-action flag with error: %+v", err)
+		os.Exit(1)
+	}
+	srvConfig := &gobmpsrv.Config{
+		Workers:               workers,
+		QueueSize:             queueSize,
+		ShardBy:               shardByValue,
+		TableTopics:           tableTopicsFlag,
+		TypedCommunities:      typedCommunitiesFlag,
+		RawTopic:              rawTopicFlag,
+		RouterNames:           routerNamesValue,
+		CollectorAdminID:      collectorAdminID,
+		ActiveRouters:         splitList(activeRouters),
+		ActiveBackoffMax:      activeBackoffMax,
+		AllowedClients:        splitList(allowedClients),
+		DeniedClients:         splitList(deniedClients),
+		MaxSessions:           maxSessions,
+		MessageRate:           messageRate,
+		ByteRate:              byteRate,
+		RateLimitAction:       rateLimitActionValue,
+		KeepAlive:             keepAlive,
+		IdleTimeout:           idleTimeout,
+		InterceptDestinations: splitList(interceptDsts),
+		InterceptBufferSize:   interceptBuffer,
+		RelayTo:               relayTo,
+		RelayBufferSize:       relayBuffer,
+		RelayPort:             relayPort,
+	}
+	if tlsCert != "" || tlsKey != "" {
+		srvConfig.TLS, err = gobmpsrv.NewServerTLSConfig(tlsCert, tlsKey, tlsClientCA)
+		if err != nil {
+			glog.Errorf("failed to setup TLS of BMP listener with error: %+v", err)
+			os.Exit(1)
+		}
+	}
+	interceptTLSFlag, err := strconv.ParseBool(interceptTLS)
+	if err != nil {
+		glog.Errorf("failed to parse to bool the value of the intercept-tls flag with error: %+v", err)
+		os.Exit(1)
+	}
+	if interceptTLSFlag {
+		srvConfig.InterceptTLS, err = gobmpsrv.NewClientTLSConfig(interceptTLSCA, interceptTLSCert, interceptTLSKey, interceptTLSName)
+		if err != nil {
+			glog.Errorf("failed to setup TLS of intercept connection with error: %+v", err)
+			os.Exit(1)
+		}
+	}
+	relayTLSFlag, err := strconv.ParseBool(relayTLS)
+	if err != nil {
+		glog.Errorf("failed to parse to bool the value of the relay-tls flag with error: %+v", err)
+		os.Exit(1)
+	}
+	if relayTLSFlag {
+		srvConfig.RelayTLS, err = gobmpsrv.NewClientTLSConfig(relayTLSCA, relayTLSCert, relayTLSKey, relayTLSName)
+		if err != nil {
+			glog.Errorf("failed to setup TLS of relay connection with error: %+v", err)
+			os.Exit(1)
+		}
+	}
+	if relayPort != 0 {
+		if relayListenerCA == "" {
+			glog.Errorf("relay-listener-tls-client-ca is required with relay-port")
+			os.Exit(1)
+		}
+		srvConfig.RelayListenerTLS, err = gobmpsrv.NewServerTLSConfig(relayListenerCert, relayListenerKey, relayListenerCA)
+		if err != nil {
+			glog.Errorf("failed to setup TLS of relay listener with error: %+v", err)
+			os.Exit(1)
+		}
+	}
+	if proxyFilter != "" {
+		srvConfig.ProxyFilter, err = gobmpsrv.ParseFilter(proxyFilter)
+		if err != nil {
+			glog.Errorf("failed to parse the value of the proxy-filter flag with error: %+v", err)
+			os.Exit(1)
+		}
+	}
+	bmpSrv, err := gobmpsrv.NewBMPServer(srcPort, dstPort, interceptFlag, publisher, splitAFFlag, storeDataFlag, srvConfig)
 	if err != nil {
 		glog.Errorf("failed to setup new gobmp server with error: %+v", err)
 		os.Exit(1)
 	}
 	// Starting Interceptor server
 	bmpSrv.Start()
+	// Reporting passive and active BMP sessions on the performance port
+	http.HandleFunc("/clients", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(bmpSrv.GetClients()); err != nil {
+			glog.Errorf("failed to encode clients with error: %+v", err)
+		}
+	})
+	// Reporting BMP sessions rejected by admission control
+	http.HandleFunc("/admission", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(bmpSrv.GetAdmissionStats()); err != nil {
+			glog.Errorf("failed to encode admission stats with error: %+v", err)
+		}
+	})
 
 	// Create gRPC server for store services
 	grpcSrv, err := grpcsrv.NewGRPCServer(bmpSrv, registerGRPCStoreServices)
@@ -147,6 +353,30 @@ func main() {
 	os.Exit(0)
 }
 
+// splitList returns non empty elements of a comma separated list
+func splitList(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+// parseRouterNames converts a comma separated list of ip=name pairs into a map of router names keyed by IP address
+func parseRouterNames(s string) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, e := range splitList(s) {
+		ip, name, ok := strings.Cut(e, "=")
+		if !ok || name == "" || net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid router name %q, expected ip=name", e)
+		}
+		names[net.ParseIP(ip).String()] = name
+	}
+	return names, nil
+}
+
 // registerGRPCStoreServices is responsible for instantiating the gRPC store services and to register them with the gRPC server
 func registerGRPCStoreServices(s *grpc.Server, bmpsrv gobmpsrv.BMPServer) error {
 	// Create & register StoreContents service server
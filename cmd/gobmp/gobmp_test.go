@@ -124,3 +124,50 @@ func TestInitiationMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRouterNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expect map[string]string
+		fail   bool
+	}{
+		{
+			name:   "empty",
+			input:  "",
+			expect: map[string]string{},
+		},
+		{
+			name:   "ipv4 and ipv6 routers",
+			input:  "192.0.2.1=edge1, 2001:DB8::1=edge2",
+			expect: map[string]string{"192.0.2.1": "edge1", "2001:db8::1": "edge2"},
+		},
+		{
+			name:  "missing name",
+			input: "192.0.2.1=",
+			fail:  true,
+		},
+		{
+			name:  "invalid address",
+			input: "router1=edge1",
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := parseRouterNames(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("supposed to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			if !reflect.DeepEqual(names, tt.expect) {
+				t.Errorf("expected %+v got %+v", tt.expect, names)
+			}
+		})
+	}
+}
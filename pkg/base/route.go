@@ -26,6 +26,11 @@ type Route struct {
 
 // UnmarshalRoutes builds BGP Withdrawn routes object
 func UnmarshalRoutes(b []byte, pathID bool) ([]Route, error) {
+	return unmarshalRoutes(b, pathID, true)
+}
+
+// unmarshalRoutes builds routes, when retry is true and routes cannot be built, the opposite value of pathID is tried
+func unmarshalRoutes(b []byte, pathID, retry bool) ([]Route, error) {
 	if glog.V(6) {
 		glog.Infof("Routes Raw: %s Path ID flag: %t", tools.MessageHex(b), pathID)
 	}
@@ -78,7 +83,10 @@ error_handle:
 		// might be advertised and received, but BGP Update would not have PathID set due to some other conditions,
 		// example when bgp speakers are in different AS. In error handle, attempting to Unmarshal again with reversed
 		// value of PathID flag.
-		if r, e := UnmarshalRoutes(b, !pathID); e == nil {
+		if !retry {
+			return nil, err
+		}
+		if r, e := unmarshalRoutes(b, !pathID, false); e == nil {
 			return r, nil
 		}
 		glog.Errorf("failed to reconstruct routes from slice %s with error: %+v", tools.MessageHex(b), err)
@@ -66,6 +66,15 @@ func TestGetPrefixIPReachability(t *testing.T) {
 	}
 }
 
+func TestUnmarshalRoutesMalformed(t *testing.T) {
+	// Routes cannot be built with or without Path ID
+	for _, pathID := range []bool{false, true} {
+		if _, err := UnmarshalRoutes([]byte{0, 0, 0, 1, 32, 10}, pathID); err == nil {
+			t.Errorf("expected to fail with path id flag %t but succeeded", pathID)
+		}
+	}
+}
+
 func TestUnmarshalBaseNLRI(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -0,0 +1,209 @@
+package bgp
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/glog"
+)
+
+// AS_PATH segment types defined in RFC 4271 and RFC 5065
+const (
+	ASSet            = 1
+	ASSequence       = 2
+	ASConfedSequence = 3
+	ASConfedSet      = 4
+)
+
+// ASTrans is the reserved 2 bytes AS replacing 4 bytes ASes in AS_PATH sent to speakers
+// not supporting 4 bytes ASes, RFC 6793
+const ASTrans = 23456
+
+// ASPathSegment defines a segment of AS_PATH or AS4_PATH attribute
+type ASPathSegment struct {
+	Type     uint8    `json:"type"`
+	TypeText string   `json:"type_text,omitempty"`
+	ASes     []uint32 `json:"ases"`
+}
+
+// ASPathSegmentTypeString returns the name of AS_PATH segment type
+func ASPathSegmentTypeString(t uint8) string {
+	switch t {
+	case ASSet:
+		return "AS_SET"
+	case ASSequence:
+		return "AS_SEQUENCE"
+	case ASConfedSequence:
+		return "AS_CONFED_SEQUENCE"
+	case ASConfedSet:
+		return "AS_CONFED_SET"
+	}
+
+	return ""
+}
+
+// isConfed returns true for segments of AS_CONFED_SEQUENCE and AS_CONFED_SET types
+func (s ASPathSegment) isConfed() bool {
+	return s.Type == ASConfedSequence || s.Type == ASConfedSet
+}
+
+// unmarshalASPath returns segments of AS_PATH or AS4_PATH attribute, ASes are 4 bytes long when as4 is true
+// and 2 bytes long otherwise. Decoding stops at a truncated segment.
+func unmarshalASPath(b []byte, as4 bool) []ASPathSegment {
+	if len(b) == 0 {
+		return nil
+	}
+	asLen := 2
+	if as4 {
+		asLen = 4
+	}
+	segs := make([]ASPathSegment, 0)
+	for p := 0; p+2 <= len(b); {
+		t := b[p]
+		p++
+		// Number of ASes in the segment
+		l := int(b[p])
+		p++
+		if p+l*asLen > len(b) {
+			glog.Warningf("invalid length %d of AS_PATH segment", l)
+			break
+		}
+		s := ASPathSegment{
+			Type:     t,
+			TypeText: ASPathSegmentTypeString(t),
+			ASes:     make([]uint32, l),
+		}
+		for n := 0; n < l; n++ {
+			if as4 {
+				s.ASes[n] = binary.BigEndian.Uint32(b[p : p+4])
+			} else {
+				s.ASes[n] = uint32(binary.BigEndian.Uint16(b[p : p+2]))
+			}
+			p += asLen
+		}
+		segs = append(segs, s)
+	}
+
+	return segs
+}
+
+// asPathASes returns a slice with a list of ASes of all segments
+func asPathASes(segs []ASPathSegment) []uint32 {
+	if len(segs) == 0 {
+		return nil
+	}
+	ases := make([]uint32, 0)
+	for _, s := range segs {
+		ases = append(ases, s.ASes...)
+	}
+
+	return ases
+}
+
+// asPathLength returns the length of the path as defined in RFC 4271, AS_SET counts as 1
+// and confederation segments are not counted.
+func asPathLength(segs []ASPathSegment) int {
+	l := 0
+	for _, s := range segs {
+		switch s.Type {
+		case ASSet:
+			l++
+		case ASSequence:
+			l += len(s.ASes)
+		}
+	}
+
+	return l
+}
+
+// asPathOriginAS returns the AS originating the route, the last AS of the path when the last segment
+// not counting confederation segments is AS_SEQUENCE, 0 is returned when the path is empty or ends with AS_SET.
+func asPathOriginAS(segs []ASPathSegment) uint32 {
+	for i := len(segs) - 1; i >= 0; i-- {
+		s := segs[i]
+		if s.isConfed() {
+			continue
+		}
+		if s.Type != ASSequence || len(s.ASes) == 0 {
+			return 0
+		}
+		return s.ASes[len(s.ASes)-1]
+	}
+
+	return 0
+}
+
+// asPathHasAS returns true when the AS is found in any segment of the path
+func asPathHasAS(segs []ASPathSegment, as uint32) bool {
+	for _, s := range segs {
+		for _, a := range s.ASes {
+			if a == as {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// mergeAS4Path rebuilds the path from AS_PATH of 2 bytes ASes and AS4_PATH as defined in RFC 6793 section 4.2.3,
+// leading ASes of AS_PATH not carried by AS4_PATH are prepended to AS4_PATH. Confederation segments of AS4_PATH
+// are discarded, AS4_PATH longer than AS_PATH is ignored.
+func mergeAS4Path(asPath, as4Path []ASPathSegment) []ASPathSegment {
+	as4 := make([]ASPathSegment, 0, len(as4Path))
+	for _, s := range as4Path {
+		if !s.isConfed() {
+			as4 = append(as4, s)
+		}
+	}
+	keep := asPathLength(asPath) - asPathLength(as4)
+	if keep < 0 {
+		return asPath
+	}
+	merged := make([]ASPathSegment, 0, len(asPath)+len(as4))
+	for _, s := range asPath {
+		if s.isConfed() {
+			merged = append(merged, s)
+			continue
+		}
+		if keep == 0 {
+			break
+		}
+		if s.Type == ASSet {
+			merged = append(merged, s)
+			keep--
+			continue
+		}
+		n := len(s.ASes)
+		if n > keep {
+			n = keep
+		}
+		merged = append(merged, ASPathSegment{Type: s.Type, TypeText: s.TypeText, ASes: append([]uint32{}, s.ASes[:n]...)})
+		keep -= n
+	}
+	for i, s := range as4 {
+		if last := len(merged) - 1; i == 0 && last >= 0 && merged[last].Type == ASSequence && s.Type == ASSequence {
+			// Joining the prepended sequence with the first sequence of AS4_PATH
+			merged[last].ASes = append(merged[last].ASes, s.ASes...)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	return merged
+}
+
+// setASPath sets the path of base attributes from AS_PATH and AS4_PATH attributes, ASes of AS_PATH are 4 bytes long
+// when as4 is true and 2 bytes long otherwise. AS_PATH of 2 bytes ASes carrying AS_TRANS is merged with AS4_PATH.
+func (ba *BaseAttributes) setASPath(asPath, as4Path []byte, as4 bool) {
+	segs := unmarshalASPath(asPath, as4)
+	as4Segs := unmarshalASPath(as4Path, true)
+	if !as4 && len(as4Segs) != 0 && asPathHasAS(segs, ASTrans) {
+		segs = mergeAS4Path(segs, as4Segs)
+	}
+	ba.ASPathSegments = segs
+	ba.ASPath = asPathASes(segs)
+	ba.ASPathCount = int32(asPathLength(segs))
+	ba.OriginAS = asPathOriginAS(segs)
+	ba.AS4Path = asPathASes(as4Segs)
+	ba.AS4PathCount = int32(asPathLength(as4Segs))
+}
@@ -0,0 +1,191 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+// asPathSegment returns a segment of AS_PATH attribute of ASes of asLen bytes
+func asPathSegment(t uint8, asLen int, ases ...uint32) []byte {
+	b := []byte{t, byte(len(ases))}
+	for _, as := range ases {
+		if asLen == 4 {
+			b = binary.BigEndian.AppendUint32(b, as)
+		} else {
+			b = binary.BigEndian.AppendUint16(b, uint16(as))
+		}
+	}
+	return b
+}
+
+func TestSetASPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		asPath      []byte
+		as4Path     []byte
+		as4         bool
+		expect      []ASPathSegment
+		expectCount int32
+		expectOrig  uint32
+	}{
+		{
+			name:        "empty",
+			expect:      nil,
+			expectCount: 0,
+		},
+		{
+			name:   "confederation sequence and sequence",
+			asPath: append(asPathSegment(ASConfedSequence, 4, 65001, 65002), asPathSegment(ASSequence, 4, 1, 2, 3)...),
+			as4:    true,
+			expect: []ASPathSegment{
+				{Type: ASConfedSequence, TypeText: "AS_CONFED_SEQUENCE", ASes: []uint32{65001, 65002}},
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{1, 2, 3}},
+			},
+			expectCount: 3,
+			expectOrig:  3,
+		},
+		{
+			name:   "sequence and set",
+			asPath: append(asPathSegment(ASSequence, 2, 1, 2), asPathSegment(ASSet, 2, 3, 4, 5)...),
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{1, 2}},
+				{Type: ASSet, TypeText: "AS_SET", ASes: []uint32{3, 4, 5}},
+			},
+			expectCount: 3,
+		},
+		{
+			name:    "as_trans merged with as4_path",
+			asPath:  asPathSegment(ASSequence, 2, 100, ASTrans, ASTrans),
+			as4Path: asPathSegment(ASSequence, 4, 196608, 196609),
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{100, 196608, 196609}},
+			},
+			expectCount: 3,
+			expectOrig:  196609,
+		},
+		{
+			name: "confederation and set merged with as4_path",
+			asPath: append(append(asPathSegment(ASConfedSequence, 2, 65001),
+				asPathSegment(ASSequence, 2, 100, ASTrans)...), asPathSegment(ASSet, 2, ASTrans, 200)...),
+			as4Path: append(append(asPathSegment(ASConfedSequence, 4, 65001),
+				asPathSegment(ASSequence, 4, 196608)...), asPathSegment(ASSet, 4, 196609, 200)...),
+			expect: []ASPathSegment{
+				{Type: ASConfedSequence, TypeText: "AS_CONFED_SEQUENCE", ASes: []uint32{65001}},
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{100, 196608}},
+				{Type: ASSet, TypeText: "AS_SET", ASes: []uint32{196609, 200}},
+			},
+			expectCount: 3,
+		},
+		{
+			name:    "as4_path longer than as_path",
+			asPath:  asPathSegment(ASSequence, 2, ASTrans),
+			as4Path: asPathSegment(ASSequence, 4, 196608, 196609),
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{ASTrans}},
+			},
+			expectCount: 1,
+			expectOrig:  ASTrans,
+		},
+		{
+			name:    "as4_path without as_trans",
+			asPath:  asPathSegment(ASSequence, 2, 100, 200),
+			as4Path: asPathSegment(ASSequence, 4, 196608),
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{100, 200}},
+			},
+			expectCount: 2,
+			expectOrig:  200,
+		},
+		{
+			name:    "as4_path of 4 bytes as_path",
+			asPath:  asPathSegment(ASSequence, 4, 100, ASTrans),
+			as4Path: asPathSegment(ASSequence, 4, 196608),
+			as4:     true,
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{100, ASTrans}},
+			},
+			expectCount: 2,
+			expectOrig:  ASTrans,
+		},
+		{
+			name:   "truncated segment",
+			asPath: append(asPathSegment(ASSequence, 2, 1, 2), ASSequence, 3, 0, 3),
+			expect: []ASPathSegment{
+				{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{1, 2}},
+			},
+			expectCount: 2,
+			expectOrig:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ba := &BaseAttributes{}
+			ba.setASPath(tt.asPath, tt.as4Path, tt.as4)
+			if !reflect.DeepEqual(ba.ASPathSegments, tt.expect) {
+				t.Logf("Diffs: %+v", deep.Equal(ba.ASPathSegments, tt.expect))
+				t.Fatal("as path segments do not match")
+			}
+			if !reflect.DeepEqual(ba.ASPath, asPathASes(tt.expect)) {
+				t.Errorf("expected as path %v got %v", asPathASes(tt.expect), ba.ASPath)
+			}
+			if ba.ASPathCount != tt.expectCount {
+				t.Errorf("expected as path count %d got %d", tt.expectCount, ba.ASPathCount)
+			}
+			if ba.OriginAS != tt.expectOrig {
+				t.Errorf("expected origin as %d got %d", tt.expectOrig, ba.OriginAS)
+			}
+		})
+	}
+}
+
+func TestUpdateSetAS4MergesAS4Path(t *testing.T) {
+	// AS_PATH of two segments with 2 bytes ASes 100, AS_TRANS and 3, which are decoded as 2 ASes of 4 bytes
+	// when ASes are 4 bytes long, and AS4_PATH with ASes 196608 and 3
+	b := []byte{
+		0, 0, // Withdrawn Routes Length
+		0, 30, // Total Path Attribute Length
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 10, 2, 2, 0, 100, 0x5b, 0xa0, 2, 1, 0, 3, // AS_PATH
+		0xc0, 17, 10, 2, 2, 0, 3, 0, 0, 0, 0, 0, 3, // AS4_PATH
+		8, 10, // NLRI
+	}
+	tests := []struct {
+		name   string
+		as4    bool
+		expect []uint32
+		origin uint32
+	}{
+		{
+			name:   "2 bytes as",
+			expect: []uint32{100, 196608, 3},
+			origin: 3,
+		},
+		{
+			name:   "4 bytes as",
+			as4:    true,
+			expect: []uint32{0x00645ba0, 0x02010003},
+			origin: 0x02010003,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := UnmarshalBGPUpdate(b)
+			if err != nil {
+				t.Fatalf("failed to unmarshal update with error: %+v", err)
+			}
+			u.SetAS4(tt.as4)
+			if !reflect.DeepEqual(u.BaseAttributes.ASPath, tt.expect) {
+				t.Errorf("expected as path %v got %v", tt.expect, u.BaseAttributes.ASPath)
+			}
+			if u.BaseAttributes.OriginAS != tt.origin {
+				t.Errorf("expected origin as %d got %d", tt.origin, u.BaseAttributes.OriginAS)
+			}
+			if !reflect.DeepEqual(u.BaseAttributes.AS4Path, []uint32{196608, 3}) {
+				t.Errorf("expected as4 path [196608 3] got %v", u.BaseAttributes.AS4Path)
+			}
+		})
+	}
+}
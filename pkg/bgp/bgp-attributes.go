@@ -0,0 +1,218 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+)
+
+// TrafficEngineering defines Traffic Engineering attribute, RFC 5543. MaxLSPBandwidth carries Max LSP Bandwidth
+// in bytes per second at priorities 0 to 7.
+type TrafficEngineering struct {
+	SwitchingCapability         uint8     `json:"switching_capability"`
+	Encoding                    uint8     `json:"encoding"`
+	MaxLSPBandwidth             []float32 `json:"max_lsp_bandwidth"`
+	SwitchingCapabilitySpecific string    `json:"switching_capability_specific,omitempty"`
+}
+
+// UnmarshalTrafficEngineering builds Traffic Engineering attribute object
+func UnmarshalTrafficEngineering(b []byte) (*TrafficEngineering, error) {
+	if len(b) < 36 {
+		return nil, fmt.Errorf("invalid length %d of Traffic Engineering attribute", len(b))
+	}
+	te := &TrafficEngineering{
+		SwitchingCapability: b[0],
+		Encoding:            b[1],
+		MaxLSPBandwidth:     make([]float32, 8),
+	}
+	// 2 bytes are reserved
+	p := 4
+	for i := range te.MaxLSPBandwidth {
+		te.MaxLSPBandwidth[i] = math.Float32frombits(binary.BigEndian.Uint32(b[p : p+4]))
+		p += 4
+	}
+	if p < len(b) {
+		te.SwitchingCapabilitySpecific = hex.EncodeToString(b[p:])
+	}
+
+	return te, nil
+}
+
+// AIGP defines Accumulated IGP Metric attribute, RFC 7311
+type AIGP struct {
+	Metric uint64 `json:"metric"`
+}
+
+// UnmarshalAIGP builds AIGP attribute object from AIGP TLV, TLVs of unknown types are skipped
+func UnmarshalAIGP(b []byte) (*AIGP, error) {
+	for p := 0; p < len(b); {
+		if p+3 > len(b) {
+			return nil, fmt.Errorf("invalid length %d of AIGP attribute", len(b))
+		}
+		t := b[p]
+		// Length of TLV includes Type and Length fields
+		l := int(binary.BigEndian.Uint16(b[p+1 : p+3]))
+		if l < 3 || p+l > len(b) {
+			return nil, fmt.Errorf("invalid length %d of AIGP TLV", l)
+		}
+		if t == 1 {
+			if l != 11 {
+				return nil, fmt.Errorf("invalid length %d of AIGP TLV", l)
+			}
+			return &AIGP{Metric: binary.BigEndian.Uint64(b[p+3 : p+11])}, nil
+		}
+		p += l
+	}
+
+	return nil, fmt.Errorf("AIGP TLV not found")
+}
+
+// PEDistinguisherLabel defines PE Address and Label of PE Distinguisher Labels attribute, RFC 6514 section 8
+type PEDistinguisherLabel struct {
+	PEAddress string `json:"pe_address"`
+	Label     uint32 `json:"label"`
+}
+
+// UnmarshalPEDistinguisherLabels builds a slice of PE Distinguisher Labels, the family of PE addresses
+// is defined by AFI of MP_REACH_NLRI attribute of the update. When the update does not carry MP_REACH_NLRI,
+// afi is 0 and the family is detected from the length of the attribute, the length which fits both
+// families is rejected as ambiguous.
+func UnmarshalPEDistinguisherLabels(b []byte, afi uint16) ([]PEDistinguisherLabel, error) {
+	var addrLen int
+	switch afi {
+	case 1:
+		addrLen = 4
+	case 2:
+		addrLen = 16
+	case 0:
+		ipv4, ipv6 := len(b)%7 == 0, len(b)%19 == 0
+		switch {
+		case ipv4 && ipv6:
+			return nil, fmt.Errorf("ambiguous length %d of PE Distinguisher Labels attribute", len(b))
+		case ipv4:
+			addrLen = 4
+		case ipv6:
+			addrLen = 16
+		default:
+			return nil, fmt.Errorf("invalid length %d of PE Distinguisher Labels attribute", len(b))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported afi %d of PE Distinguisher Labels attribute", afi)
+	}
+	if len(b) == 0 || len(b)%(addrLen+3) != 0 {
+		return nil, fmt.Errorf("invalid length %d of PE Distinguisher Labels attribute", len(b))
+	}
+	labels := make([]PEDistinguisherLabel, 0)
+	for p := 0; p < len(b); p += addrLen + 3 {
+		l := b[p+addrLen:]
+		labels = append(labels, PEDistinguisherLabel{
+			PEAddress: net.IP(b[p : p+addrLen]).String(),
+			Label:     (uint32(l[0])<<16 | uint32(l[1])<<8 | uint32(l[2])) >> 4,
+		})
+	}
+
+	return labels, nil
+}
+
+// BGPsecSecurePathSegment defines Secure_Path Segment of BGPsec_Path attribute, RFC 8205 section 3.1
+type BGPsecSecurePathSegment struct {
+	PCount        uint8  `json:"pcount"`
+	ConfedSegment bool   `json:"confed_segment,omitempty"`
+	AS            uint32 `json:"as"`
+}
+
+// BGPsecSignatureSegment defines Signature Segment of BGPsec_Path attribute, RFC 8205 section 3.2
+type BGPsecSignatureSegment struct {
+	SKI       string `json:"ski"`
+	Signature string `json:"signature"`
+}
+
+// BGPsecSignatureBlock defines Signature_Block of BGPsec_Path attribute, RFC 8205 section 3.2
+type BGPsecSignatureBlock struct {
+	AlgorithmSuite uint8                    `json:"algorithm_suite"`
+	Segments       []BGPsecSignatureSegment `json:"segments"`
+}
+
+// BGPsecPath defines BGPsec_Path attribute, RFC 8205
+type BGPsecPath struct {
+	SecurePath      []BGPsecSecurePathSegment `json:"secure_path"`
+	SignatureBlocks []BGPsecSignatureBlock    `json:"signature_blocks,omitempty"`
+}
+
+// UnmarshalBGPsecPath builds BGPsec_Path attribute object
+func UnmarshalBGPsecPath(b []byte) (*BGPsecPath, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("invalid length %d of BGPsec_Path attribute", len(b))
+	}
+	// Length of Secure_Path includes the Length field
+	l := int(binary.BigEndian.Uint16(b[:2]))
+	if l < 2 || l > len(b) || (l-2)%6 != 0 {
+		return nil, fmt.Errorf("invalid length %d of Secure_Path", l)
+	}
+	sp := &BGPsecPath{
+		SecurePath: make([]BGPsecSecurePathSegment, 0, (l-2)/6),
+	}
+	for p := 2; p < l; p += 6 {
+		sp.SecurePath = append(sp.SecurePath, BGPsecSecurePathSegment{
+			PCount:        b[p],
+			ConfedSegment: b[p+1]&0x80 == 0x80,
+			AS:            binary.BigEndian.Uint32(b[p+2 : p+6]),
+		})
+	}
+	for p := l; p < len(b); {
+		if p+3 > len(b) {
+			return nil, fmt.Errorf("invalid length of Signature_Block")
+		}
+		// Length of Signature_Block includes the Length field
+		bl := int(binary.BigEndian.Uint16(b[p : p+2]))
+		if bl < 3 || p+bl > len(b) {
+			return nil, fmt.Errorf("invalid length %d of Signature_Block", bl)
+		}
+		block := BGPsecSignatureBlock{
+			AlgorithmSuite: b[p+2],
+			Segments:       make([]BGPsecSignatureSegment, 0),
+		}
+		for s := p + 3; s < p+bl; {
+			if s+22 > p+bl {
+				return nil, fmt.Errorf("invalid length of Signature Segment")
+			}
+			sl := int(binary.BigEndian.Uint16(b[s+20 : s+22]))
+			if s+22+sl > p+bl {
+				return nil, fmt.Errorf("invalid length %d of Signature", sl)
+			}
+			block.Segments = append(block.Segments, BGPsecSignatureSegment{
+				SKI:       hex.EncodeToString(b[s : s+20]),
+				Signature: hex.EncodeToString(b[s+22 : s+22+sl]),
+			})
+			s += 22 + sl
+		}
+		sp.SignatureBlocks = append(sp.SignatureBlocks, block)
+		p += bl
+	}
+
+	return sp, nil
+}
+
+// AttrSet defines ATTR_SET attribute carrying path attributes of the customer network, RFC 6368
+type AttrSet struct {
+	OriginAS   uint32          `json:"origin_as"`
+	Attributes *BaseAttributes `json:"attributes,omitempty"`
+}
+
+// UnmarshalAttrSet builds ATTR_SET attribute object
+func UnmarshalAttrSet(b []byte) (*AttrSet, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid length %d of ATTR_SET attribute", len(b))
+	}
+	attrs, err := unmarshalBaseAttributes(b[4:], true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path attributes of ATTR_SET: %w", err)
+	}
+
+	return &AttrSet{
+		OriginAS:   binary.BigEndian.Uint32(b[:4]),
+		Attributes: attrs,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package bgp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/sbezverk/gobmp/pkg/tunnelencap"
+)
+
+func TestUnmarshalPathAttributes(t *testing.T) {
+	// 7 IPv6 PE Distinguisher Labels take 133 bytes, the length of 19 IPv4 ones
+	ipv6PELabel := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 1}
+	ipv6PELabels := make([]PEDistinguisherLabel, 7)
+	for i := range ipv6PELabels {
+		ipv6PELabels[i] = PEDistinguisherLabel{PEAddress: "2001:db8::1", Label: 16}
+	}
+	tests := []struct {
+		name   string
+		input  []byte
+		expect *BaseAttributes
+	}{
+		{
+			name:  "pmsi tunnel ingress replication",
+			input: []byte{0xc0, 22, 9, 0, PMSITunnelIngressReplication, 0, 0, 100, 192, 0, 2, 1},
+			expect: &BaseAttributes{
+				PMSITunnel: &PMSITunnel{
+					TunnelType:     PMSITunnelIngressReplication,
+					TunnelTypeText: "Ingress Replication",
+					Label:          6,
+					VNI:            100,
+					TunnelID:       "192.0.2.1",
+				},
+			},
+		},
+		{
+			name:  "pmsi tunnel pim-ssm tree",
+			input: []byte{0xc0, 22, 13, 1, PMSITunnelPIMSSM, 0, 1, 1, 192, 0, 2, 1, 232, 1, 1, 1},
+			expect: &BaseAttributes{
+				PMSITunnel: &PMSITunnel{
+					LeafInfoRequired: true,
+					TunnelType:       PMSITunnelPIMSSM,
+					TunnelTypeText:   "PIM-SSM Tree",
+					Label:            16,
+					VNI:              257,
+					TunnelID:         "192.0.2.1,232.1.1.1",
+				},
+			},
+		},
+		{
+			name:  "tunnel encapsulation",
+			input: []byte{0xc0, 23, 8, 0, tunnelencap.VXLAN, 0, 4, tunnelencap.UDPDestinationPortSTLV, 2, 0x12, 0xb5},
+			expect: &BaseAttributes{
+				TunnelEncapAttr: []byte{0, tunnelencap.VXLAN, 0, 4, tunnelencap.UDPDestinationPortSTLV, 2, 0x12, 0xb5},
+				TunnelEncap: []tunnelencap.Tunnel{
+					{Type: tunnelencap.VXLAN, TypeText: "VXLAN", UDPDestinationPort: 4789},
+				},
+			},
+		},
+		{
+			name:  "malformed tunnel encapsulation",
+			input: []byte{0xc0, 23, 4, 0, tunnelencap.VXLAN, 0, 4},
+			expect: &BaseAttributes{
+				TunnelEncapAttr: []byte{0, tunnelencap.VXLAN, 0, 4},
+			},
+		},
+		{
+			name:  "traffic engineering",
+			input: append([]byte{0x80, 24, 36, 1, 2, 0, 0}, bytes.Repeat([]byte{0x44, 0x7a, 0, 0}, 8)...),
+			expect: &BaseAttributes{
+				TrafficEngineering: &TrafficEngineering{
+					SwitchingCapability: 1,
+					Encoding:            2,
+					MaxLSPBandwidth:     []float32{1000, 1000, 1000, 1000, 1000, 1000, 1000, 1000},
+				},
+			},
+		},
+		{
+			name:  "ipv6 address specific extended community",
+			input: []byte{0xc0, 25, 20, 0, 2, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 100},
+			expect: &BaseAttributes{
+				IPv6ExtCommunityList: []string{"rt=2001:db8::1:100"},
+			},
+		},
+		{
+			name:  "aigp",
+			input: []byte{0x80, 26, 11, 1, 0, 11, 0, 0, 0, 0, 0, 0, 0, 100},
+			expect: &BaseAttributes{
+				AIGP: &AIGP{Metric: 100},
+			},
+		},
+		{
+			name:   "truncated aigp",
+			input:  []byte{0x80, 26, 7, 1, 0, 11, 0, 0, 0, 0},
+			expect: &BaseAttributes{},
+		},
+		{
+			name:  "pe distinguisher labels",
+			input: []byte{0xc0, 27, 14, 192, 0, 2, 1, 0, 1, 1, 192, 0, 2, 2, 0, 2, 1},
+			expect: &BaseAttributes{
+				PEDistinguisherLabels: []PEDistinguisherLabel{
+					{PEAddress: "192.0.2.1", Label: 16},
+					{PEAddress: "192.0.2.2", Label: 32},
+				},
+			},
+		},
+		{
+			name:  "entropy label capability",
+			input: []byte{0xc0, 28, 0},
+			expect: &BaseAttributes{
+				EntropyLabelCapable: true,
+			},
+		},
+		{
+			name: "pe distinguisher labels with family of mp_reach_nlri",
+			input: append(append([]byte{0xc0, 27, 133}, bytes.Repeat(ipv6PELabel, 7)...),
+				0x80, 14, 5, 0, 2, 5, 0, 0),
+			expect: &BaseAttributes{
+				PEDistinguisherLabels: ipv6PELabels,
+			},
+		},
+		{
+			name:   "pe distinguisher labels of ambiguous length",
+			input:  append([]byte{0xc0, 27, 133}, bytes.Repeat(ipv6PELabel, 7)...),
+			expect: &BaseAttributes{},
+		},
+		{
+			name: "pe distinguisher labels with family of mp_unreach_nlri",
+			input: append(append([]byte{0xc0, 27, 133}, bytes.Repeat(ipv6PELabel, 7)...),
+				0x80, 15, 3, 0, 2, 1),
+			expect: &BaseAttributes{
+				PEDistinguisherLabels: ipv6PELabels,
+			},
+		},
+		{
+			name:   "pe distinguisher labels of invalid length",
+			input:  []byte{0xc0, 27, 6, 192, 0, 2, 1, 0, 1},
+			expect: &BaseAttributes{},
+		},
+		{
+			name:   "pe distinguisher labels not matching family of mp_reach_nlri",
+			input:  []byte{0x80, 14, 5, 0, 2, 5, 0, 0, 0xc0, 27, 7, 192, 0, 2, 1, 0, 1, 1},
+			expect: &BaseAttributes{},
+		},
+		{
+			name:   "bgp-ls attribute is decoded by bgp-ls messages",
+			input:  []byte{0x80, 29, 6, 0x04, 0x02, 0, 2, 'r', '1'},
+			expect: &BaseAttributes{},
+		},
+		{
+			name: "bgpsec path",
+			input: append(append([]byte{0x80, 33, 35, 0, 8, 1, 0, 0, 0, 0xfd, 0xe9, 0, 27, 1},
+				bytes.Repeat([]byte{0xaa}, 20)...), 0, 2, 1, 2),
+			expect: &BaseAttributes{
+				BGPsecPath: &BGPsecPath{
+					SecurePath: []BGPsecSecurePathSegment{{PCount: 1, AS: 65001}},
+					SignatureBlocks: []BGPsecSignatureBlock{
+						{
+							AlgorithmSuite: 1,
+							Segments: []BGPsecSignatureSegment{
+								{SKI: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Signature: "0102"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "attr set",
+			input: []byte{0xc0, 128, 15, 0, 0, 0xfd, 0xe8, 0x40, 1, 1, 0, 0x40, 5, 4, 0, 0, 0, 100},
+			expect: &BaseAttributes{
+				AttrSet: &AttrSet{
+					OriginAS:   65000,
+					Attributes: &BaseAttributes{Origin: "igp", LocalPref: 100},
+				},
+			},
+		},
+		{
+			name:  "attr set with as path",
+			input: []byte{0xc0, 128, 17, 0, 0, 0xfd, 0xe8, 0x40, 1, 1, 0, 0x40, 2, 6, 2, 1, 0, 0, 0xfd, 0xe9},
+			expect: &BaseAttributes{
+				AttrSet: &AttrSet{
+					OriginAS: 65000,
+					Attributes: &BaseAttributes{
+						Origin:         "igp",
+						ASPath:         []uint32{65001},
+						ASPathCount:    1,
+						ASPathSegments: []ASPathSegment{{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{65001}}},
+						OriginAS:       65001,
+					},
+				},
+			},
+		},
+		{
+			name:   "attr set with truncated attribute",
+			input:  []byte{0xc0, 128, 11, 0, 0, 0xfd, 0xe8, 0x40, 1, 1, 0, 0x40, 5, 4},
+			expect: &BaseAttributes{},
+		},
+		{
+			name:   "nested attr set",
+			input:  []byte{0xc0, 128, 11, 0, 0, 0xfd, 0xe8, 0xc0, 128, 4, 0, 0, 0xfd, 0xe9},
+			expect: &BaseAttributes{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalBGPBaseAttributes(tt.input)
+			if err != nil {
+				t.Fatalf("expected to succeed but failed with error: %+v", err)
+			}
+			if got.BaseAttrHash == "" {
+				t.Errorf("expected hash of base attributes")
+			}
+			got.BaseAttrHash = ""
+			if got.AttrSet != nil && got.AttrSet.Attributes != nil {
+				got.AttrSet.Attributes.BaseAttrHash = ""
+			}
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Logf("differences: %+v", deep.Equal(got, tt.expect))
+				t.Fatal("decoded and expected base attributes do not match")
+			}
+		})
+	}
+}
@@ -6,11 +6,13 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net"
 	"reflect"
 	"strconv"
 
 	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/tunnelencap"
 	"github.com/sbezverk/tools"
 	"github.com/sbezverk/tools/sort"
 )
@@ -35,15 +37,37 @@ type BaseAttributes struct {
 	AS4Path          []uint32 `json:"as4_path,omitempty"`
 	AS4PathCount     int32    `json:"as4_path_count,omitempty"`
 	AS4Aggregator    []byte   `json:"as4_aggregator,omitempty"`
+	// ASPathSegments carries segments of the path rebuilt from AS_PATH and AS4_PATH, ASPath lists ASes of all
+	// segments and ASPathCount is the length of the path as defined in RFC 4271.
+	ASPathSegments []ASPathSegment `json:"as_path_segments,omitempty"`
+	OriginAS       uint32          `json:"origin_as,omitempty"`
 	// PMSITunnel
+	PMSITunnel *PMSITunnel `json:"pmsi_tunnel,omitempty"`
+	// TunnelEncap
 	TunnelEncapAttr []byte `json:"-"`
+	// TunnelEncap carries Tunnel TLVs of Tunnel Encapsulation attribute decoded per RFC 9012
+	TunnelEncap []tunnelencap.Tunnel `json:"tunnel_encap,omitempty"`
 	// TraficEng
+	TrafficEngineering *TrafficEngineering `json:"traffic_engineering,omitempty"`
 	// IPv6SpecExtCommunity
+	IPv6ExtCommunityList []string `json:"ipv6_ext_community_list,omitempty"`
 	// AIGP
+	AIGP *AIGP `json:"aigp,omitempty"`
 	// PEDistinguisherLable
+	PEDistinguisherLabels []PEDistinguisherLabel `json:"pe_distinguisher_labels,omitempty"`
+	// EntropyLabel
+	EntropyLabelCapable bool `json:"entropy_label_capable,omitempty"`
+	// LgCommunity
 	LgCommunityList []string `json:"large_community_list,omitempty"`
 	// SecPath
+	BGPsecPath *BGPsecPath `json:"bgpsec_path,omitempty"`
 	// AttrSet
+	AttrSet *AttrSet `json:"attr_set,omitempty"`
+	// Communities, ExtCommunities and LgCommunities carry decoded communities of CommunityList,
+	// ExtCommunityList and LgCommunityList in the same order.
+	Communities    []Community         `json:"communities,omitempty"`
+	ExtCommunities []TypedExtCommunity `json:"ext_communities,omitempty"`
+	LgCommunities  []LgCommunity       `json:"large_communities,omitempty"`
 }
 
 func (ba *BaseAttributes) Equal(oba *BaseAttributes) (bool, []string) {
@@ -62,6 +86,14 @@ func (ba *BaseAttributes) Equal(oba *BaseAttributes) (bool, []string) {
 		equal = false
 		diffs = append(diffs, "as_path_count mismatch: "+strconv.Itoa(int(ba.ASPathCount))+" and "+strconv.Itoa(int(oba.ASPathCount)))
 	}
+	if !reflect.DeepEqual(ba.ASPathSegments, oba.ASPathSegments) {
+		equal = false
+		diffs = append(diffs, "as_path_segments mismatch")
+	}
+	if ba.OriginAS != oba.OriginAS {
+		equal = false
+		diffs = append(diffs, "origin_as mismatch: "+strconv.Itoa(int(ba.OriginAS))+" and "+strconv.Itoa(int(oba.OriginAS)))
+	}
 	if ba.Nexthop != oba.Nexthop {
 		equal = false
 		diffs = append(diffs, "nexthop mismatch: "+ba.Nexthop+" and "+oba.Nexthop)
@@ -114,6 +146,42 @@ func (ba *BaseAttributes) Equal(oba *BaseAttributes) (bool, []string) {
 		equal = false
 		diffs = append(diffs, "large_community_list mismatch")
 	}
+	if !reflect.DeepEqual(ba.PMSITunnel, oba.PMSITunnel) {
+		equal = false
+		diffs = append(diffs, "pmsi_tunnel mismatch")
+	}
+	if !reflect.DeepEqual(ba.TunnelEncap, oba.TunnelEncap) {
+		equal = false
+		diffs = append(diffs, "tunnel_encap mismatch")
+	}
+	if !reflect.DeepEqual(ba.TrafficEngineering, oba.TrafficEngineering) {
+		equal = false
+		diffs = append(diffs, "traffic_engineering mismatch")
+	}
+	if !reflect.DeepEqual(sort.SortMergeComparableSlice(ba.IPv6ExtCommunityList), sort.SortMergeComparableSlice(oba.IPv6ExtCommunityList)) {
+		equal = false
+		diffs = append(diffs, "ipv6_ext_community_list mismatch")
+	}
+	if !reflect.DeepEqual(ba.AIGP, oba.AIGP) {
+		equal = false
+		diffs = append(diffs, "aigp mismatch")
+	}
+	if !reflect.DeepEqual(ba.PEDistinguisherLabels, oba.PEDistinguisherLabels) {
+		equal = false
+		diffs = append(diffs, "pe_distinguisher_labels mismatch")
+	}
+	if ba.EntropyLabelCapable != oba.EntropyLabelCapable {
+		equal = false
+		diffs = append(diffs, "entropy_label_capable mismatch: "+strconv.FormatBool(ba.EntropyLabelCapable)+" and "+strconv.FormatBool(oba.EntropyLabelCapable))
+	}
+	if !reflect.DeepEqual(ba.BGPsecPath, oba.BGPsecPath) {
+		equal = false
+		diffs = append(diffs, "bgpsec_path mismatch")
+	}
+	if !reflect.DeepEqual(ba.AttrSet, oba.AttrSet) {
+		equal = false
+		diffs = append(diffs, "attr_set mismatch")
+	}
 
 	return equal, diffs
 
@@ -122,11 +190,23 @@ func (ba *BaseAttributes) Equal(oba *BaseAttributes) (bool, []string) {
 // UnmarshalBGPBaseAttributes discovers all present Base Attributes in BGP Update
 // and instantiates BaseAttributes object
 func UnmarshalBGPBaseAttributes(b []byte) (*BaseAttributes, error) {
+	return unmarshalBaseAttributes(b, false)
+}
+
+// unmarshalBaseAttributes instantiates BaseAttributes object, nested is true for path attributes carried
+// in ATTR_SET attribute which cannot carry another ATTR_SET, RFC 6368 section 5
+func unmarshalBaseAttributes(b []byte, nested bool) (*BaseAttributes, error) {
 	if glog.V(6) {
 		glog.Infof("UnmarshalBGPBaseAttributes RAW: %+v", tools.MessageHex(b))
 	}
 	baseAttr := BaseAttributes{}
+	var asPath, as4Path, peLabels []byte
+	var mpAFI, mpUnreachAFI uint16
+	var err error
 	for p := 0; p < len(b); {
+		if p+3 > len(b) {
+			return nil, fmt.Errorf("truncated path attribute")
+		}
 		flag := b[p]
 		p++
 		t := b[p]
@@ -134,18 +214,26 @@ func UnmarshalBGPBaseAttributes(b []byte) (*BaseAttributes, error) {
 		var l uint16
 		// Checking for Extened
 		if flag&0x10 == 0x10 {
+			if p+2 > len(b) {
+				return nil, fmt.Errorf("truncated path attribute")
+			}
 			l = binary.BigEndian.Uint16(b[p : p+2])
 			p += 2
 		} else {
 			l = uint16(b[p])
 			p++
 		}
+		if p+int(l) > len(b) {
+			return nil, fmt.Errorf("invalid length %d of path attribute type %d", l, t)
+		}
+		if nested && t == 128 {
+			return nil, fmt.Errorf("nested ATTR_SET")
+		}
 		switch t {
 		case 1:
 			baseAttr.Origin = unmarshalAttrOrigin(b[p : p+int(l)])
 		case 2:
-			baseAttr.ASPath = unmarshalAttrASPath(b[p : p+int(l)])
-			baseAttr.ASPathCount = int32(len(baseAttr.ASPath))
+			asPath = b[p : p+int(l)]
 		case 3:
 			baseAttr.Nexthop = unmarshalAttrNextHop(b[p : p+int(l)])
 		case 4:
@@ -158,47 +246,154 @@ func UnmarshalBGPBaseAttributes(b []byte) (*BaseAttributes, error) {
 			baseAttr.Aggregator = unmarshalAttrAggregator(b[p : p+int(l)])
 		case 8:
 			baseAttr.CommunityList = unmarshalAttrCommunity(b[p : p+int(l)])
+			if baseAttr.Communities, err = UnmarshalBGPCommunity(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal Communities attribute with error: %+v", err)
+			}
 		case 9:
 			baseAttr.OriginatorID = unmarshalAttrOriginatorID(b[p : p+int(l)])
 		case 10:
 			baseAttr.ClusterList = unmarshalAttrClusterList(b[p : p+int(l)])
+		case 14:
+			if l >= 2 {
+				mpAFI = binary.BigEndian.Uint16(b[p : p+2])
+			}
+		case 15:
+			if l >= 2 {
+				mpUnreachAFI = binary.BigEndian.Uint16(b[p : p+2])
+			}
 		case 16:
 			baseAttr.ExtCommunityList = unmarshalAttrExtCommunity(b[p : p+int(l)])
+			baseAttr.ExtCommunities = unmarshalAttrTypedExtCommunity(b[p : p+int(l)])
 		case 17:
-			baseAttr.AS4Path = unmarshalAttrAS4Path(b[p : p+int(l)])
-			baseAttr.AS4PathCount = int32(len(baseAttr.AS4Path))
+			as4Path = b[p : p+int(l)]
 		case 18:
 			baseAttr.AS4Aggregator = unmarshalAttrAS4Aggregator(b[p : p+int(l)])
 		case 22:
+			if baseAttr.PMSITunnel, err = UnmarshalPMSITunnel(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal PMSI Tunnel attribute with error: %+v", err)
+			}
 		case 23:
 			baseAttr.TunnelEncapAttr = make([]byte, l)
 			copy(baseAttr.TunnelEncapAttr, b[p:p+int(l)])
+			if baseAttr.TunnelEncap, err = tunnelencap.UnmarshalTunnelEncapAttr(baseAttr.TunnelEncapAttr); err != nil {
+				glog.Warningf("failed to unmarshal Tunnel Encapsulation attribute with error: %+v", err)
+			}
 		case 24:
+			if baseAttr.TrafficEngineering, err = UnmarshalTrafficEngineering(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal Traffic Engineering attribute with error: %+v", err)
+			}
 		case 25:
+			baseAttr.IPv6ExtCommunityList = unmarshalAttrIPv6ExtCommunity(b[p : p+int(l)])
 		case 26:
+			if baseAttr.AIGP, err = UnmarshalAIGP(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal AIGP attribute with error: %+v", err)
+			}
 		case 27:
+			peLabels = b[p : p+int(l)]
 		case 28:
-		case 29:
+			baseAttr.EntropyLabelCapable = true
 		case 32:
 			baseAttr.LgCommunityList = unmarshalAttrLgCommunity(b[p : p+int(l)])
+			if baseAttr.LgCommunities, err = UnmarshalBGPLgCommunity(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal Large Communities attribute with error: %+v", err)
+			}
 		case 33:
+			if baseAttr.BGPsecPath, err = UnmarshalBGPsecPath(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal BGPsec_Path attribute with error: %+v", err)
+			}
 		case 128:
+			if baseAttr.AttrSet, err = UnmarshalAttrSet(b[p : p+int(l)]); err != nil {
+				glog.Warningf("failed to unmarshal ATTR_SET attribute with error: %+v", err)
+			}
 		}
 		p += int(l)
 	}
+	// PE Distinguisher Labels attribute can precede MP_REACH_NLRI defining the family of PE addresses,
+	// MP_UNREACH_NLRI defines the family when the Update carries no MP_REACH_NLRI
+	if peLabels != nil {
+		if mpAFI == 0 {
+			mpAFI = mpUnreachAFI
+		}
+		if baseAttr.PEDistinguisherLabels, err = UnmarshalPEDistinguisherLabels(peLabels, mpAFI); err != nil {
+			glog.Warningf("failed to unmarshal PE Distinguisher Labels attribute with error: %+v", err)
+		}
+	}
+	// ASes of AS_PATH are 4 bytes long unless the BMP Per-Peer header indicates otherwise, see Update SetAS4
+	baseAttr.setASPath(asPath, as4Path, true)
 	// Calculating hash of all recovered base attributes
-	ba, err := json.Marshal(baseAttr)
-	if err != nil {
+	if err := baseAttr.setHash(); err != nil {
 		return nil, err
 	}
-	s := md5.Sum(ba)
-	baseAttr.BaseAttrHash = hex.EncodeToString(s[:])
 
 	return &baseAttr, nil
 }
 
+// hashedAttributes defines the fields of base attributes the hash is calculated over, decoded forms of
+// the attributes added later are not part of it to keep the hash of the same attributes unchanged.
+type hashedAttributes struct {
+	Origin           string   `json:"origin,omitempty"`
+	ASPath           []uint32 `json:"as_path,omitempty"`
+	ASPathCount      int32    `json:"as_path_count,omitempty"`
+	Nexthop          string   `json:"nexthop,omitempty"`
+	MED              uint32   `json:"med,omitempty"`
+	LocalPref        uint32   `json:"local_pref,omitempty"`
+	IsAtomicAgg      bool     `json:"is_atomic_agg"`
+	Aggregator       []byte   `json:"aggregator,omitempty"`
+	CommunityList    []string `json:"community_list,omitempty"`
+	OriginatorID     string   `json:"originator_id,omitempty"`
+	ClusterList      string   `json:"cluster_list,omitempty"`
+	ExtCommunityList []string `json:"ext_community_list,omitempty"`
+	AS4Path          []uint32 `json:"as4_path,omitempty"`
+	AS4PathCount     int32    `json:"as4_path_count,omitempty"`
+	AS4Aggregator    []byte   `json:"as4_aggregator,omitempty"`
+	LgCommunityList  []string `json:"large_community_list,omitempty"`
+}
+
+// setHash calculates the hash of base attributes
+func (ba *BaseAttributes) setHash() error {
+	b, err := json.Marshal(&hashedAttributes{
+		Origin:           ba.Origin,
+		ASPath:           ba.ASPath,
+		ASPathCount:      ba.ASPathCount,
+		Nexthop:          ba.Nexthop,
+		MED:              ba.MED,
+		LocalPref:        ba.LocalPref,
+		IsAtomicAgg:      ba.IsAtomicAgg,
+		Aggregator:       ba.Aggregator,
+		CommunityList:    ba.CommunityList,
+		OriginatorID:     ba.OriginatorID,
+		ClusterList:      ba.ClusterList,
+		ExtCommunityList: ba.ExtCommunityList,
+		AS4Path:          ba.AS4Path,
+		AS4PathCount:     ba.AS4PathCount,
+		AS4Aggregator:    ba.AS4Aggregator,
+		LgCommunityList:  ba.LgCommunityList,
+	})
+	if err != nil {
+		return err
+	}
+	s := md5.Sum(b)
+	ba.BaseAttrHash = hex.EncodeToString(s[:])
+
+	return nil
+}
+
+// ClearTypedCommunities removes decoded communities, extended communities and large communities,
+// including the ones of ATTR_SET, leaving their string forms only.
+func (ba *BaseAttributes) ClearTypedCommunities() {
+	ba.Communities = nil
+	ba.ExtCommunities = nil
+	ba.LgCommunities = nil
+	if ba.AttrSet != nil && ba.AttrSet.Attributes != nil {
+		ba.AttrSet.Attributes.ClearTypedCommunities()
+	}
+}
+
 // unmarshalAttrOrigin returns the value of Origin attribute
 func unmarshalAttrOrigin(b []byte) string {
+	if len(b) != 1 {
+		return ""
+	}
 	switch b[0] {
 	case 0:
 		return "igp"
@@ -211,71 +406,6 @@ func unmarshalAttrOrigin(b []byte) string {
 	}
 }
 
-// unmarshalAttrASPath returns a slice with a list of ASes
-func unmarshalAttrASPath(b []byte) []uint32 {
-	if len(b) == 0 {
-		return nil
-	}
-	path := make([]uint32, 0)
-	as4 := isASPath4(b)
-	for p := 0; p < len(b); {
-		// Skipping type
-		p++
-		// Length of path segment of type
-		l := b[p]
-		p++
-		// Attempting to detect if 2 or 4 bytes AS is used
-		for n := 0; n < int(l); n++ {
-			if as4 {
-				as := binary.BigEndian.Uint32(b[p : p+4])
-				p += 4
-				path = append(path, as)
-			} else {
-				as := binary.BigEndian.Uint16(b[p : p+2])
-				p += 2
-				path = append(path, uint32(as))
-			}
-		}
-	}
-
-	return path
-}
-
-func isASPath4(b []byte) bool {
-	p := 0
-	// Skipping type
-	p++
-	// Length of path segment in 2 or 4 bytes depending if AS2 or AS4 is used.
-	l := int(b[p])
-	p++
-	// Check if next segment can be found with AS4
-	if l*4 == len(b[p:]) {
-		// Found last AS4 segment, confirmed AS4
-		return true
-	}
-	// Check if next segment can be found with AS4
-	if l*2 == len(b[p:]) {
-		// Found last AS2 segment, confirmed AS2
-		return false
-	}
-	// Check if next segment can be found with AS4
-	if p+l*4 < len(b) {
-		if b[p+l*4] == 0x1 || b[p+l*4] == 0x2 {
-			// Found next AS4 segment, confirmed AS4
-			return true
-		}
-	}
-	// Check if next segment can be found with AS2
-	if p+l*2 < len(b) {
-		if b[p+l*2] == 0x1 || b[p+l*2] == 0x2 {
-			// Found next AS2 segment, confirmed AS2
-			return false
-		}
-	}
-	// Should never reach here
-	return false
-}
-
 // unmarshalAttrNextHop returns the value of Next Hop attribute
 func unmarshalAttrNextHop(b []byte) string {
 	if len(b) == 4 {
@@ -311,7 +441,7 @@ func unmarshalAttrAggregator(b []byte) []byte {
 // getCommunity returns a slice of communities
 func getCommunity(b []byte) []uint32 {
 	comm := make([]uint32, 0)
-	for p := 0; p < len(b); {
+	for p := 0; p+4 <= len(b); {
 		c := binary.BigEndian.Uint32(b[p : p+4])
 		p += 4
 		comm = append(comm, c)
@@ -344,7 +474,7 @@ func unmarshalAttrOriginatorID(b []byte) string {
 func getClusterID(b []byte) [][]byte {
 	cl := make([][]byte, 0)
 	i := 0
-	for p := 0; p < len(b); {
+	for p := 0; p+4 <= len(b); {
 		c := make([]byte, 4)
 		copy(c, b[p:p+4])
 		p += 4
@@ -383,6 +513,20 @@ func unmarshalAttrExtCommunity(b []byte) []string {
 	return s
 }
 
+// unmarshalAttrTypedExtCommunity returns a slice with all decoded extended communities found in bgp update
+func unmarshalAttrTypedExtCommunity(b []byte) []TypedExtCommunity {
+	ext, err := UnmarshalBGPExtCommunity(b)
+	if err != nil {
+		return nil
+	}
+	t := make([]TypedExtCommunity, len(ext))
+	for i, c := range ext {
+		t[i] = *c.Typed()
+	}
+
+	return t
+}
+
 // unmarshalAttrLgCommunity returns a slice with all large communities found in bgp update
 func unmarshalAttrLgCommunity(b []byte) []string {
 	lg, err := UnmarshalBGPLgCommunity(b)
@@ -397,25 +541,6 @@ func unmarshalAttrLgCommunity(b []byte) []string {
 	return s
 }
 
-// unmarshalAttrAS4Path returns a sequence of AS4 path segments
-func unmarshalAttrAS4Path(b []byte) []uint32 {
-	path := make([]uint32, 0)
-	for p := 0; p < len(b); {
-		// Skipping type
-		p++
-		// Length of path segment in 4 bytes
-		l := b[p]
-		p++
-		for n := 0; n < int(l); n++ {
-			as := binary.BigEndian.Uint32(b[p : p+4])
-			p += 4
-			path = append(path, as)
-		}
-	}
-
-	return path
-}
-
 // getAttrAS4Aggregator returns the value of AS4 AGGREGATOR attribute
 func unmarshalAttrAS4Aggregator(b []byte) []byte {
 	agg := make([]byte, len(b))
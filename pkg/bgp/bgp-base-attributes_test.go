@@ -25,6 +25,18 @@ func TestUnmarshaBaseAttributes(t *testing.T) {
 				Aggregator:      []byte{0, 0, 101, 32, 192, 120, 81, 136},
 				CommunityList:   []string{"0:39533", "6453:86", "6453:3000", "6453:3100", "6453:3102", "39533:49666"},
 				LgCommunityList: []string{"34872:10:211", "34872:11:1", "34872:100:49", "34872:122:1"},
+				ASPathSegments: []ASPathSegment{
+					{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{34872, 39533, 6453, 2687, 25888, 21326}},
+					{Type: ASSet, TypeText: "AS_SET", ASes: []uint32{4809}},
+				},
+				Communities: []Community{
+					{Value: 39533}, {ASN: 6453, Value: 86}, {ASN: 6453, Value: 3000},
+					{ASN: 6453, Value: 3100}, {ASN: 6453, Value: 3102}, {ASN: 39533, Value: 49666},
+				},
+				LgCommunities: []LgCommunity{
+					{GlobalAdmin: 34872, LocalData1: 10, LocalData2: 211}, {GlobalAdmin: 34872, LocalData1: 11, LocalData2: 1},
+					{GlobalAdmin: 34872, LocalData1: 100, LocalData2: 49}, {GlobalAdmin: 34872, LocalData1: 122, LocalData2: 1},
+				},
 			},
 		},
 	}
@@ -48,31 +60,37 @@ func TestUnmarshalASPath(t *testing.T) {
 	tests := []struct {
 		name   string
 		input  []byte
+		as4    bool
 		asPath []uint32
 	}{
 		{
 			name:   "panic #1",
 			input:  []byte{0x02, 0x08, 0x00, 0x00, 0x24, 0x58, 0x00, 0x00, 0x92, 0x5c, 0x00, 0x00, 0xf1, 0x88, 0x00, 0x04, 0x03, 0xb8, 0x00, 0x00, 0x6e, 0xd0, 0x00, 0x04, 0x03, 0xb8, 0x00, 0x00, 0x6e, 0xd0, 0x00, 0x04, 0x03, 0xb8},
+			as4:    true,
 			asPath: []uint32{9304, 37468, 61832, 263096, 28368, 263096, 28368, 263096},
 		},
 		{
 			name:   "panic #2",
 			input:  []byte{0x02, 0x48, 0x00, 0x00, 0xce, 0x89, 0x00, 0x00, 0x32, 0x9c, 0x00, 0x00, 0xf0, 0x1c, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0x14},
+			as4:    true,
 			asPath: []uint32{52873, 12956, 61468, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 269844},
 		},
 		{
 			name:   "panic #3",
 			input:  []byte{0x02, 0xa2, 0x00, 0x00, 0xbe, 0xb5, 0x00, 0x03, 0x21, 0x38, 0x00, 0x00, 0xc5, 0xc5, 0x00, 0x00, 0x00, 0xae, 0x00, 0x00, 0x6c, 0x66, 0x00, 0x00, 0xf0, 0x1c, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0xfa, 0x00, 0x04, 0x1e, 0x14},
+			as4:    true,
 			asPath: []uint32{48821, 205112, 50629, 174, 27750, 61468, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 270074, 269844},
 		},
 		{
 			name:   "panic #4",
 			input:  []byte{0x02, 0x06, 0x00, 0x00, 0x88, 0x38, 0x00, 0x00, 0x9a, 0x6d, 0x00, 0x00, 0x19, 0x35, 0x00, 0x00, 0x0a, 0x7f, 0x00, 0x00, 0x65, 0x20, 0x00, 0x00, 0x53, 0x4e, 0x01, 0x01, 0x00, 0x00, 0x12, 0xc9},
+			as4:    true,
 			asPath: []uint32{34872, 39533, 6453, 2687, 25888, 21326, 4809},
 		},
 		{
 			name:   "1 AS4 segment",
 			input:  []byte{0x02, 0x01, 0x00, 0x00, 0x88, 0x38},
+			as4:    true,
 			asPath: []uint32{34872},
 		},
 		{
@@ -83,6 +101,7 @@ func TestUnmarshalASPath(t *testing.T) {
 		{
 			name:   "2 AS4 segments",
 			input:  []byte{0x02, 0x01, 0x00, 0x00, 0x88, 0x38, 0x01, 0x01, 0x00, 0x00, 0x88, 0x38},
+			as4:    true,
 			asPath: []uint32{34872, 34872},
 		},
 		{
@@ -97,7 +116,7 @@ func TestUnmarshalASPath(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		r := unmarshalAttrASPath(tt.input)
+		r := asPathASes(unmarshalASPath(tt.input, tt.as4))
 		if !reflect.DeepEqual(tt.asPath, r) {
 			t.Fatalf("expected %+v and result %+v as path do not match", tt.asPath, r)
 		}
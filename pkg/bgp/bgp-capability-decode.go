@@ -0,0 +1,339 @@
+package bgp
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/glog"
+)
+
+// BGP Capability codes decoded into DecodedCapabilities
+const (
+	CapabilityMultiProtocol        = 1
+	CapabilityRouteRefresh         = 2
+	CapabilityExtendedNextHop      = 5
+	CapabilityExtendedMessage      = 6
+	CapabilityMultipleLabels       = 8
+	CapabilityRole                 = 9
+	CapabilityGracefulRestart      = 64
+	CapabilityFourOctetAS          = 65
+	CapabilityAddPath              = 69
+	CapabilityEnhancedRouteRefresh = 70
+	CapabilityLLGR                 = 71
+	CapabilityFQDN                 = 73
+)
+
+// BGP Roles per rfc9234
+const (
+	RoleProvider = 0
+	RoleRS       = 1
+	RoleRSClient = 2
+	RoleCustomer = 3
+	RolePeer     = 4
+)
+
+var roleNames = map[uint8]string{
+	RoleProvider: "Provider",
+	RoleRS:       "RS",
+	RoleRSClient: "RS-Client",
+	RoleCustomer: "Customer",
+	RolePeer:     "Peer",
+}
+
+// roleMatch lists the role expected from the peer for each local role
+var roleMatch = map[uint8]uint8{
+	RoleProvider: RoleCustomer,
+	RoleCustomer: RoleProvider,
+	RoleRS:       RoleRSClient,
+	RoleRSClient: RoleRS,
+	RolePeer:     RolePeer,
+}
+
+// AFISAFI defines AFI/SAFI pair
+type AFISAFI struct {
+	AFI  uint16 `json:"afi"`
+	SAFI uint8  `json:"safi"`
+}
+
+// AddPathAFISAFI defines ADD-PATH Send/Receive mode of AFI/SAFI
+type AddPathAFISAFI struct {
+	AFI     uint16 `json:"afi"`
+	SAFI    uint8  `json:"safi"`
+	Send    bool   `json:"send"`
+	Receive bool   `json:"receive"`
+}
+
+// MultipleLabelsAFISAFI defines the maximum number of labels of AFI/SAFI
+type MultipleLabelsAFISAFI struct {
+	AFI   uint16 `json:"afi"`
+	SAFI  uint8  `json:"safi"`
+	Count uint8  `json:"count"`
+}
+
+// ExtendedNextHop defines NLRI AFI/SAFI which may carry Next Hop of the Next Hop AFI per rfc8950
+type ExtendedNextHop struct {
+	AFI        uint16 `json:"afi"`
+	SAFI       uint16 `json:"safi"`
+	NextHopAFI uint16 `json:"nexthop_afi"`
+}
+
+// GracefulRestartAFISAFI defines Graceful Restart AFI/SAFI and its Forwarding State flag
+type GracefulRestartAFISAFI struct {
+	AFI                 uint16 `json:"afi"`
+	SAFI                uint8  `json:"safi"`
+	ForwardingPreserved bool   `json:"forwarding_preserved"`
+}
+
+// GracefulRestart defines Graceful Restart capability per rfc4724 and rfc8538
+type GracefulRestart struct {
+	Restarting   bool                     `json:"restarting"`
+	Notification bool                     `json:"notification"`
+	Time         uint16                   `json:"time"`
+	AFISAFI      []GracefulRestartAFISAFI `json:"afi_safi,omitempty"`
+}
+
+// LLGRAFISAFI defines Long-Lived Graceful Restart AFI/SAFI, its Forwarding State flag and Long-Lived Stale Time
+type LLGRAFISAFI struct {
+	AFI                 uint16 `json:"afi"`
+	SAFI                uint8  `json:"safi"`
+	ForwardingPreserved bool   `json:"forwarding_preserved"`
+	StaleTime           uint32 `json:"stale_time"`
+}
+
+// FQDN defines FQDN capability
+type FQDN struct {
+	Hostname   string `json:"hostname,omitempty"`
+	DomainName string `json:"domain_name,omitempty"`
+}
+
+// Role defines BGP Role capability per rfc9234
+type Role struct {
+	Value uint8  `json:"value"`
+	Name  string `json:"name"`
+}
+
+// DecodedCapabilities defines the values of common BGP Capabilities advertised in Open message
+type DecodedCapabilities struct {
+	MultiProtocol        []AFISAFI               `json:"multiprotocol,omitempty"`
+	RouteRefresh         bool                    `json:"route_refresh,omitempty"`
+	EnhancedRouteRefresh bool                    `json:"enhanced_route_refresh,omitempty"`
+	ExtendedNextHop      []ExtendedNextHop       `json:"extended_nexthop,omitempty"`
+	ExtendedMessage      bool                    `json:"extended_message,omitempty"`
+	MultipleLabels       []MultipleLabelsAFISAFI `json:"multiple_labels,omitempty"`
+	Role                 *Role                   `json:"role,omitempty"`
+	GracefulRestart      *GracefulRestart        `json:"graceful_restart,omitempty"`
+	FourOctetAS          uint32                  `json:"four_octet_as,omitempty"`
+	AddPath              []AddPathAFISAFI        `json:"add_path,omitempty"`
+	LLGR                 []LLGRAFISAFI           `json:"llgr,omitempty"`
+	FQDN                 *FQDN                   `json:"fqdn,omitempty"`
+}
+
+// NegotiatedCapabilities defines capabilities in effect for the session, which are advertised by both
+// the local speaker and the remote speaker. ADD-PATH modes are from the perspective of the local speaker.
+type NegotiatedCapabilities struct {
+	MultiProtocol        []AFISAFI         `json:"multiprotocol,omitempty"`
+	RouteRefresh         bool              `json:"route_refresh"`
+	EnhancedRouteRefresh bool              `json:"enhanced_route_refresh"`
+	ExtendedNextHop      []ExtendedNextHop `json:"extended_nexthop,omitempty"`
+	ExtendedMessage      bool              `json:"extended_message"`
+	FourOctetAS          bool              `json:"four_octet_as"`
+	AddPath              []AddPathAFISAFI  `json:"add_path,omitempty"`
+	GracefulRestart      []AFISAFI         `json:"graceful_restart,omitempty"`
+	LLGR                 []AFISAFI         `json:"llgr,omitempty"`
+	// Role is the role of the local speaker when both speakers advertise matching roles
+	Role string `json:"role,omitempty"`
+	// RoleMismatch is set when both speakers advertise roles, which do not match
+	RoleMismatch bool `json:"role_mismatch,omitempty"`
+}
+
+// RoleString returns the name of BGP Role
+func RoleString(role uint8) string {
+	if s, ok := roleNames[role]; ok {
+		return s
+	}
+	return "Unknown"
+}
+
+// Decode returns the values of common capabilities, malformed capabilities are skipped. Values of a capability
+// advertised more than once are kept in the order of advertisement.
+func (c Capability) Decode() *DecodedCapabilities {
+	d := &DecodedCapabilities{}
+	for code, data := range c {
+		for _, cd := range data {
+			if !d.decode(code, cd.Value) {
+				glog.Warningf("invalid length %d of capability %d", len(cd.Value), code)
+			}
+		}
+	}
+
+	return d
+}
+
+// decode sets the value of the capability, it returns false if the value is malformed
+func (d *DecodedCapabilities) decode(code uint8, v []byte) bool {
+	switch code {
+	case CapabilityMultiProtocol:
+		if len(v) != 4 {
+			return false
+		}
+		d.MultiProtocol = append(d.MultiProtocol, AFISAFI{AFI: binary.BigEndian.Uint16(v[0:2]), SAFI: v[3]})
+	case CapabilityRouteRefresh:
+		d.RouteRefresh = true
+	case CapabilityEnhancedRouteRefresh:
+		d.EnhancedRouteRefresh = true
+	case CapabilityExtendedMessage:
+		d.ExtendedMessage = true
+	case CapabilityExtendedNextHop:
+		if len(v)%6 != 0 {
+			return false
+		}
+		for p := 0; p < len(v); p += 6 {
+			d.ExtendedNextHop = append(d.ExtendedNextHop, ExtendedNextHop{
+				AFI:        binary.BigEndian.Uint16(v[p : p+2]),
+				SAFI:       binary.BigEndian.Uint16(v[p+2 : p+4]),
+				NextHopAFI: binary.BigEndian.Uint16(v[p+4 : p+6]),
+			})
+		}
+	case CapabilityMultipleLabels:
+		if len(v)%4 != 0 {
+			return false
+		}
+		for p := 0; p < len(v); p += 4 {
+			d.MultipleLabels = append(d.MultipleLabels, MultipleLabelsAFISAFI{
+				AFI:   binary.BigEndian.Uint16(v[p : p+2]),
+				SAFI:  v[p+2],
+				Count: v[p+3],
+			})
+		}
+	case CapabilityRole:
+		if len(v) != 1 {
+			return false
+		}
+		d.Role = &Role{Value: v[0], Name: RoleString(v[0])}
+	case CapabilityGracefulRestart:
+		if len(v) < 2 || (len(v)-2)%4 != 0 {
+			return false
+		}
+		gr := &GracefulRestart{
+			Restarting:   v[0]&0x80 != 0,
+			Notification: v[0]&0x40 != 0,
+			Time:         binary.BigEndian.Uint16(v[0:2]) & 0x0fff,
+		}
+		for p := 2; p < len(v); p += 4 {
+			gr.AFISAFI = append(gr.AFISAFI, GracefulRestartAFISAFI{
+				AFI:                 binary.BigEndian.Uint16(v[p : p+2]),
+				SAFI:                v[p+2],
+				ForwardingPreserved: v[p+3]&0x80 != 0,
+			})
+		}
+		d.GracefulRestart = gr
+	case CapabilityFourOctetAS:
+		if len(v) != 4 {
+			return false
+		}
+		d.FourOctetAS = binary.BigEndian.Uint32(v)
+	case CapabilityAddPath:
+		if len(v)%4 != 0 {
+			return false
+		}
+		for p := 0; p < len(v); p += 4 {
+			d.AddPath = append(d.AddPath, AddPathAFISAFI{
+				AFI:     binary.BigEndian.Uint16(v[p : p+2]),
+				SAFI:    v[p+2],
+				Send:    v[p+3]&AddPathSend != 0,
+				Receive: v[p+3]&AddPathReceive != 0,
+			})
+		}
+	case CapabilityLLGR:
+		if len(v)%7 != 0 {
+			return false
+		}
+		for p := 0; p < len(v); p += 7 {
+			d.LLGR = append(d.LLGR, LLGRAFISAFI{
+				AFI:                 binary.BigEndian.Uint16(v[p : p+2]),
+				SAFI:                v[p+2],
+				ForwardingPreserved: v[p+3]&0x80 != 0,
+				StaleTime:           uint32(v[p+4])<<16 | uint32(v[p+5])<<8 | uint32(v[p+6]),
+			})
+		}
+	case CapabilityFQDN:
+		if len(v) < 1 || len(v) < 1+int(v[0])+1 {
+			return false
+		}
+		hl := int(v[0])
+		dl := int(v[1+hl])
+		if len(v) != 2+hl+dl {
+			return false
+		}
+		d.FQDN = &FQDN{
+			Hostname:   string(v[1 : 1+hl]),
+			DomainName: string(v[2+hl:]),
+		}
+	}
+
+	return true
+}
+
+// NegotiateCapabilities returns capabilities advertised by both the local speaker and the remote speaker,
+// nil is returned if capabilities of any of the speakers are not known.
+func NegotiateCapabilities(local, remote *DecodedCapabilities) *NegotiatedCapabilities {
+	if local == nil || remote == nil {
+		return nil
+	}
+	n := &NegotiatedCapabilities{
+		RouteRefresh:         local.RouteRefresh && remote.RouteRefresh,
+		EnhancedRouteRefresh: local.EnhancedRouteRefresh && remote.EnhancedRouteRefresh,
+		ExtendedMessage:      local.ExtendedMessage && remote.ExtendedMessage,
+		FourOctetAS:          local.FourOctetAS != 0 && remote.FourOctetAS != 0,
+	}
+	for _, l := range local.MultiProtocol {
+		for _, r := range remote.MultiProtocol {
+			if l == r {
+				n.MultiProtocol = append(n.MultiProtocol, l)
+			}
+		}
+	}
+	for _, l := range local.ExtendedNextHop {
+		for _, r := range remote.ExtendedNextHop {
+			if l == r {
+				n.ExtendedNextHop = append(n.ExtendedNextHop, l)
+			}
+		}
+	}
+	for _, l := range local.AddPath {
+		for _, r := range remote.AddPath {
+			if l.AFI != r.AFI || l.SAFI != r.SAFI {
+				continue
+			}
+			// Local speaker sends multiple paths received by the remote speaker and the other way around
+			a := AddPathAFISAFI{AFI: l.AFI, SAFI: l.SAFI, Send: l.Send && r.Receive, Receive: l.Receive && r.Send}
+			if a.Send || a.Receive {
+				n.AddPath = append(n.AddPath, a)
+			}
+		}
+	}
+	if local.GracefulRestart != nil && remote.GracefulRestart != nil {
+		for _, l := range local.GracefulRestart.AFISAFI {
+			for _, r := range remote.GracefulRestart.AFISAFI {
+				if l.AFI == r.AFI && l.SAFI == r.SAFI {
+					n.GracefulRestart = append(n.GracefulRestart, AFISAFI{AFI: l.AFI, SAFI: l.SAFI})
+				}
+			}
+		}
+	}
+	for _, l := range local.LLGR {
+		for _, r := range remote.LLGR {
+			if l.AFI == r.AFI && l.SAFI == r.SAFI {
+				n.LLGR = append(n.LLGR, AFISAFI{AFI: l.AFI, SAFI: l.SAFI})
+			}
+		}
+	}
+	if local.Role != nil && remote.Role != nil {
+		if m, ok := roleMatch[local.Role.Value]; ok && m == remote.Role.Value {
+			n.Role = local.Role.Name
+		} else {
+			n.RoleMismatch = true
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,176 @@
+package bgp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestCapabilityDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		open   []byte
+		caps   Capability
+		expect *DecodedCapabilities
+	}{
+		{
+			name: "open message",
+			open: []byte{0x00, 0x5F, 0x01, 0x04, 0x00, 0x01, 0x00, 0xB4, 0x01, 0x01, 0x01, 0x01, 0x42, 0x02, 0x06, 0x01, 0x04, 0x00, 0x01, 0x00, 0x01, 0x02, 0x02, 0x80, 0x00, 0x02, 0x02, 0x02, 0x00, 0x02, 0x02, 0x46, 0x00, 0x02, 0x06, 0x41, 0x04, 0x00, 0x00, 0x00, 0x01, 0x02, 0x02, 0x06, 0x00, 0x02, 0x06, 0x45, 0x04, 0x00, 0x01, 0x01, 0x01, 0x02, 0x07, 0x49, 0x05, 0x03, 0x66, 0x72, 0x72, 0x00, 0x02, 0x04, 0x40, 0x02, 0x80, 0x78, 0x02, 0x09, 0x47, 0x07, 0x00, 0x01, 0x01, 0x80, 0x00, 0x00, 0x00},
+			expect: &DecodedCapabilities{
+				MultiProtocol:        []AFISAFI{{AFI: 1, SAFI: 1}},
+				RouteRefresh:         true,
+				EnhancedRouteRefresh: true,
+				ExtendedMessage:      true,
+				FourOctetAS:          1,
+				AddPath:              []AddPathAFISAFI{{AFI: 1, SAFI: 1, Receive: true}},
+				FQDN:                 &FQDN{Hostname: "frr"},
+				GracefulRestart:      &GracefulRestart{Restarting: true, Time: 120},
+				LLGR:                 []LLGRAFISAFI{{AFI: 1, SAFI: 1, ForwardingPreserved: true}},
+			},
+		},
+		{
+			name: "graceful restart with address families",
+			caps: Capability{
+				CapabilityGracefulRestart: {{Value: []byte{0x40, 0xb4, 0, 1, 1, 0x80, 0, 2, 1, 0}}},
+			},
+			expect: &DecodedCapabilities{
+				GracefulRestart: &GracefulRestart{
+					Notification: true,
+					Time:         180,
+					AFISAFI: []GracefulRestartAFISAFI{
+						{AFI: 1, SAFI: 1, ForwardingPreserved: true},
+						{AFI: 2, SAFI: 1},
+					},
+				},
+			},
+		},
+		{
+			name: "multiprotocol advertised more than once",
+			caps: Capability{
+				CapabilityMultiProtocol: {{Value: []byte{0, 1, 0, 1}}, {Value: []byte{0, 2, 0, 128}}},
+			},
+			expect: &DecodedCapabilities{
+				MultiProtocol: []AFISAFI{{AFI: 1, SAFI: 1}, {AFI: 2, SAFI: 128}},
+			},
+		},
+		{
+			name: "fqdn, role, extended next hop and multiple labels",
+			caps: Capability{
+				CapabilityFQDN:            {{Value: []byte{2, 'r', '1', 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'}}},
+				CapabilityRole:            {{Value: []byte{RoleCustomer}}},
+				CapabilityExtendedNextHop: {{Value: []byte{0, 1, 0, 1, 0, 2, 0, 1, 0, 128, 0, 2}}},
+				CapabilityMultipleLabels:  {{Value: []byte{0, 1, 4, 2}}},
+			},
+			expect: &DecodedCapabilities{
+				FQDN:            &FQDN{Hostname: "r1", DomainName: "example.com"},
+				Role:            &Role{Value: RoleCustomer, Name: "Customer"},
+				ExtendedNextHop: []ExtendedNextHop{{AFI: 1, SAFI: 1, NextHopAFI: 2}, {AFI: 1, SAFI: 128, NextHopAFI: 2}},
+				MultipleLabels:  []MultipleLabelsAFISAFI{{AFI: 1, SAFI: 4, Count: 2}},
+			},
+		},
+		{
+			name: "malformed capabilities",
+			caps: Capability{
+				CapabilityMultiProtocol:   {{Value: []byte{0, 1, 0}}},
+				CapabilityFourOctetAS:     {{Value: []byte{0, 1}}},
+				CapabilityAddPath:         {{Value: []byte{0, 1, 1}}},
+				CapabilityGracefulRestart: {{Value: []byte{0x40, 0xb4, 0, 1}}},
+				CapabilityLLGR:            {{Value: []byte{0, 1, 1, 0x80, 0, 0}}},
+				CapabilityFQDN:            {{Value: []byte{5, 'r', '1'}}},
+				CapabilityRole:            {{Value: []byte{}}},
+			},
+			expect: &DecodedCapabilities{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := tt.caps
+			if tt.open != nil {
+				om, err := UnmarshalBGPOpenMessage(tt.open)
+				if err != nil {
+					t.Fatalf("failed to unmarshal open message with error: %+v", err)
+				}
+				caps = om.Capabilities
+			}
+			got := caps.Decode()
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Logf("Diffs: %+v", deep.Equal(got, tt.expect))
+				t.Fatal("decoded and expected capabilities do not match")
+			}
+		})
+	}
+}
+
+func TestNegotiateCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  *DecodedCapabilities
+		remote *DecodedCapabilities
+		expect *NegotiatedCapabilities
+	}{
+		{
+			name:   "unknown capabilities",
+			local:  &DecodedCapabilities{},
+			expect: nil,
+		},
+		{
+			name: "intersection",
+			local: &DecodedCapabilities{
+				MultiProtocol:   []AFISAFI{{AFI: 1, SAFI: 1}, {AFI: 2, SAFI: 1}},
+				RouteRefresh:    true,
+				ExtendedMessage: true,
+				FourOctetAS:     65000,
+				ExtendedNextHop: []ExtendedNextHop{{AFI: 1, SAFI: 1, NextHopAFI: 2}},
+				AddPath: []AddPathAFISAFI{
+					{AFI: 1, SAFI: 1, Send: true, Receive: true},
+					{AFI: 2, SAFI: 1, Receive: true},
+					{AFI: 1, SAFI: 128, Receive: true},
+				},
+				GracefulRestart: &GracefulRestart{AFISAFI: []GracefulRestartAFISAFI{{AFI: 1, SAFI: 1}, {AFI: 2, SAFI: 1}}},
+				LLGR:            []LLGRAFISAFI{{AFI: 1, SAFI: 1, StaleTime: 3600}},
+				Role:            &Role{Value: RoleProvider, Name: "Provider"},
+			},
+			remote: &DecodedCapabilities{
+				MultiProtocol:        []AFISAFI{{AFI: 1, SAFI: 1}},
+				RouteRefresh:         true,
+				EnhancedRouteRefresh: true,
+				FourOctetAS:          65001,
+				AddPath: []AddPathAFISAFI{
+					{AFI: 1, SAFI: 1, Receive: true},
+					{AFI: 2, SAFI: 1, Send: true},
+					{AFI: 1, SAFI: 128, Receive: true},
+				},
+				GracefulRestart: &GracefulRestart{AFISAFI: []GracefulRestartAFISAFI{{AFI: 2, SAFI: 1, ForwardingPreserved: true}}},
+				LLGR:            []LLGRAFISAFI{{AFI: 2, SAFI: 1}},
+				Role:            &Role{Value: RoleCustomer, Name: "Customer"},
+			},
+			expect: &NegotiatedCapabilities{
+				MultiProtocol: []AFISAFI{{AFI: 1, SAFI: 1}},
+				RouteRefresh:  true,
+				FourOctetAS:   true,
+				AddPath: []AddPathAFISAFI{
+					{AFI: 1, SAFI: 1, Send: true},
+					{AFI: 2, SAFI: 1, Receive: true},
+				},
+				GracefulRestart: []AFISAFI{{AFI: 2, SAFI: 1}},
+				Role:            "Provider",
+			},
+		},
+		{
+			name:   "role mismatch",
+			local:  &DecodedCapabilities{Role: &Role{Value: RolePeer, Name: "Peer"}},
+			remote: &DecodedCapabilities{Role: &Role{Value: RoleCustomer, Name: "Customer"}},
+			expect: &NegotiatedCapabilities{RoleMismatch: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NegotiateCapabilities(tt.local, tt.remote)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Logf("Diffs: %+v", deep.Equal(got, tt.expect))
+				t.Fatal("negotiated and expected capabilities do not match")
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/tools"
+)
+
+const (
+	// BGPMinNotificationMessageLength defines a minimum length of BGP Notification Message
+	BGPMinNotificationMessageLength = 21
+	// BGPNotificationMessageType defines the type of BGP Notification Message
+	BGPNotificationMessageType = 3
+)
+
+// BGP Notification Error codes
+const (
+	NotificationMessageHeaderError   = 1
+	NotificationOpenMessageError     = 2
+	NotificationUpdateMessageError   = 3
+	NotificationHoldTimerExpired     = 4
+	NotificationFSMError             = 5
+	NotificationCease                = 6
+	NotificationRouteRefreshError    = 7
+	NotificationSendHoldTimerExpired = 8
+)
+
+// BGP Notification Cease subcodes
+const (
+	CeaseMaximumPrefixesReached = 1
+	CeaseAdministrativeShutdown = 2
+	CeasePeerDeconfigured       = 3
+	CeaseAdministrativeReset    = 4
+)
+
+var notificationCodes = map[uint8]string{
+	NotificationMessageHeaderError:   "Message Header Error",
+	NotificationOpenMessageError:     "OPEN Message Error",
+	NotificationUpdateMessageError:   "UPDATE Message Error",
+	NotificationHoldTimerExpired:     "Hold Timer Expired",
+	NotificationFSMError:             "Finite State Machine Error",
+	NotificationCease:                "Cease",
+	NotificationRouteRefreshError:    "ROUTE-REFRESH Message Error",
+	NotificationSendHoldTimerExpired: "Send Hold Timer Expired",
+}
+
+var notificationSubCodes = map[uint8]map[uint8]string{
+	NotificationMessageHeaderError: {
+		1: "Connection Not Synchronized",
+		2: "Bad Message Length",
+		3: "Bad Message Type",
+	},
+	NotificationOpenMessageError: {
+		1:  "Unsupported Version Number",
+		2:  "Bad Peer AS",
+		3:  "Bad BGP Identifier",
+		4:  "Unsupported Optional Parameter",
+		6:  "Unacceptable Hold Time",
+		7:  "Unsupported Capability",
+		11: "Role Mismatch",
+	},
+	NotificationUpdateMessageError: {
+		1:  "Malformed Attribute List",
+		2:  "Unrecognized Well-known Attribute",
+		3:  "Missing Well-known Attribute",
+		4:  "Attribute Flags Error",
+		5:  "Attribute Length Error",
+		6:  "Invalid ORIGIN Attribute",
+		8:  "Invalid NEXT_HOP Attribute",
+		9:  "Optional Attribute Error",
+		10: "Invalid Network Field",
+		11: "Malformed AS_PATH",
+	},
+	NotificationFSMError: {
+		1: "Receive Unexpected Message in OpenSent State",
+		2: "Receive Unexpected Message in OpenConfirm State",
+		3: "Receive Unexpected Message in Established State",
+	},
+	NotificationCease: {
+		CeaseMaximumPrefixesReached: "Maximum Number of Prefixes Reached",
+		CeaseAdministrativeShutdown: "Administrative Shutdown",
+		CeasePeerDeconfigured:       "Peer De-configured",
+		CeaseAdministrativeReset:    "Administrative Reset",
+		5:                           "Connection Rejected",
+		6:                           "Other Configuration Change",
+		7:                           "Connection Collision Resolution",
+		8:                           "Out of Resources",
+		9:                           "Hard Reset",
+		10:                          "BFD Down",
+	},
+	NotificationRouteRefreshError: {
+		1: "Invalid Message Length",
+	},
+}
+
+// NotificationMessage defines BGP Notification Message structure
+type NotificationMessage struct {
+	Length       uint16
+	Type         byte
+	ErrorCode    uint8
+	ErrorSubCode uint8
+	Data         []byte
+}
+
+// UnmarshalBGPNotificationMessage validates and builds BGP Notification Message from the slice
+// starting right after the marker.
+func UnmarshalBGPNotificationMessage(b []byte) (*NotificationMessage, error) {
+	if glog.V(6) {
+		glog.Infof("BGPNotificationMessage Raw: %s", tools.MessageHex(b))
+	}
+	if len(b) < BGPMinNotificationMessageLength-16 {
+		return nil, fmt.Errorf("BGP Notification Message length %d is invalid", len(b))
+	}
+	p := 0
+	m := &NotificationMessage{}
+	m.Length = binary.BigEndian.Uint16(b[p : p+2])
+	p += 2
+	if int(m.Length) < BGPMinNotificationMessageLength || int(m.Length)-16 > len(b) {
+		return nil, fmt.Errorf("invalid length %d of BGP Notification Message", m.Length)
+	}
+	if b[p] != BGPNotificationMessageType {
+		return nil, fmt.Errorf("invalid message type %d for BGP Notification Message", b[p])
+	}
+	m.Type = b[p]
+	p++
+	m.ErrorCode = b[p]
+	p++
+	m.ErrorSubCode = b[p]
+	p++
+	m.Data = make([]byte, int(m.Length)-16-p)
+	copy(m.Data, b[p:int(m.Length)-16])
+
+	return m, nil
+}
+
+// ErrorCodeString returns the name of the Error code
+func (n *NotificationMessage) ErrorCodeString() string {
+	if s, ok := notificationCodes[n.ErrorCode]; ok {
+		return s
+	}
+	return "Unknown Error " + strconv.Itoa(int(n.ErrorCode))
+}
+
+// ErrorSubCodeString returns the name of the Error subcode, subcode 0 is Unspecific for all Error codes.
+func (n *NotificationMessage) ErrorSubCodeString() string {
+	if n.ErrorSubCode == 0 {
+		return "Unspecific"
+	}
+	if s, ok := notificationSubCodes[n.ErrorCode][n.ErrorSubCode]; ok {
+		return s
+	}
+	return "Unknown Subcode " + strconv.Itoa(int(n.ErrorSubCode))
+}
+
+// ShutdownCommunication returns the Shutdown Communication per rfc9003 carried by Cease Administrative Shutdown
+// and Administrative Reset Notifications. The second value is false if the message does not carry a valid one.
+func (n *NotificationMessage) ShutdownCommunication() (string, bool) {
+	if n.ErrorCode != NotificationCease {
+		return "", false
+	}
+	if n.ErrorSubCode != CeaseAdministrativeShutdown && n.ErrorSubCode != CeaseAdministrativeReset {
+		return "", false
+	}
+	if len(n.Data) == 0 {
+		return "", false
+	}
+	l := int(n.Data[0])
+	if l == 0 || l > len(n.Data)-1 {
+		return "", false
+	}
+	s := n.Data[1 : 1+l]
+	if !utf8.Valid(s) {
+		return "", false
+	}
+
+	return string(s), true
+}
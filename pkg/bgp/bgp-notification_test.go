@@ -0,0 +1,95 @@
+package bgp
+
+import (
+	"testing"
+)
+
+func TestUnmarshalBGPNotificationMessage(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		fail        bool
+		code        string
+		subcode     string
+		shutdown    string
+		hasShutdown bool
+	}{
+		{
+			name:    "cease administrative reset without communication",
+			input:   []byte{0x00, 0x15, 0x03, 0x06, 0x04},
+			code:    "Cease",
+			subcode: "Administrative Reset",
+		},
+		{
+			name:        "cease administrative shutdown with communication",
+			input:       []byte{0x00, 0x1b, 0x03, 0x06, 0x02, 0x05, 'm', 'a', 'i', 'n', 't'},
+			code:        "Cease",
+			subcode:     "Administrative Shutdown",
+			shutdown:    "maint",
+			hasShutdown: true,
+		},
+		{
+			name:    "hold timer expired",
+			input:   []byte{0x00, 0x15, 0x03, 0x04, 0x00},
+			code:    "Hold Timer Expired",
+			subcode: "Unspecific",
+		},
+		{
+			name:    "update message error with data",
+			input:   []byte{0x00, 0x17, 0x03, 0x03, 0x0b, 0x01, 0x02},
+			code:    "UPDATE Message Error",
+			subcode: "Malformed AS_PATH",
+		},
+		{
+			name:    "unknown code and subcode",
+			input:   []byte{0x00, 0x15, 0x03, 0x63, 0x07},
+			code:    "Unknown Error 99",
+			subcode: "Unknown Subcode 7",
+		},
+		{
+			name:    "shutdown communication longer than data",
+			input:   []byte{0x00, 0x18, 0x03, 0x06, 0x02, 0x09, 'a', 'b'},
+			code:    "Cease",
+			subcode: "Administrative Shutdown",
+		},
+		{
+			name:  "invalid type",
+			input: []byte{0x00, 0x15, 0x02, 0x06, 0x04},
+			fail:  true,
+		},
+		{
+			name:  "length exceeds data",
+			input: []byte{0x00, 0x20, 0x03, 0x06, 0x04},
+			fail:  true,
+		},
+		{
+			name:  "too short",
+			input: []byte{0x00, 0x15, 0x03, 0x06},
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := UnmarshalBGPNotificationMessage(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("supposed to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			if s := n.ErrorCodeString(); s != tt.code {
+				t.Errorf("expected error code %q got %q", tt.code, s)
+			}
+			if s := n.ErrorSubCodeString(); s != tt.subcode {
+				t.Errorf("expected error subcode %q got %q", tt.subcode, s)
+			}
+			s, ok := n.ShutdownCommunication()
+			if ok != tt.hasShutdown || s != tt.shutdown {
+				t.Errorf("expected shutdown communication %q/%t got %q/%t", tt.shutdown, tt.hasShutdown, s, ok)
+			}
+		})
+	}
+}
@@ -35,17 +35,35 @@ func (o *OpenMessage) GetCapabilities() Capability {
 // in case of true, it also returns 4 bytes Autonomous System Number.
 func (o *OpenMessage) Is4BytesASCapable() (uint32, bool) {
 	v, ok := o.Capabilities[65]
-	if !ok {
+	if !ok || len(v) == 0 || len(v[0].Value) != 4 {
 		return 0, false
 	}
 
 	return binary.BigEndian.Uint32(v[0].Value), true
 }
 
-// IsAddPathCapable returns a map of NLRI types and bool indicating if a particular NLRI type
-// supports Add Path capability
+// ADD-PATH capability Send/Receive modes per rfc7911
+const (
+	AddPathReceive     = 1
+	AddPathSend        = 2
+	AddPathSendReceive = 3
+)
+
+// AddPathCapability returns a map of NLRI types and bool indicating if a particular NLRI type
+// supports Send and Receive of Add Path
 func (o *OpenMessage) AddPathCapability() map[int]bool {
 	m := make(map[int]bool)
+	for t, mode := range o.AddPathModes() {
+		// Only Send/Receive mode enables AddPath for the entry
+		m[t] = mode == AddPathSendReceive
+	}
+
+	return m
+}
+
+// AddPathModes returns a map of NLRI types and Send/Receive mode of Add Path advertised for them
+func (o *OpenMessage) AddPathModes() map[int]uint8 {
+	m := make(map[int]uint8)
 	v, ok := o.Capabilities[69]
 	if !ok {
 		return m
@@ -65,14 +83,9 @@ func (o *OpenMessage) AddPathCapability() map[int]bool {
 	for p := 0; p < len(v[0].Value); p += 4 {
 		afi := binary.BigEndian.Uint16(v[0].Value[p : p+2])
 		safi := v[0].Value[p+2]
-		// Check if last byte == 3 (Send/Receive) AddPath, if not, ignoring entry
-		flag := false
-		if v[0].Value[p+3] == 3 {
-			flag = true
-		}
-		m[NLRIMessageType(afi, safi)] = flag
+		m[NLRIMessageType(afi, safi)] = v[0].Value[p+3]
 		if glog.V(6) {
-			glog.Infof("AddPath Capability for AFI/SAFI: %d/%d is %t", afi, safi, flag)
+			glog.Infof("AddPath Capability for AFI/SAFI: %d/%d mode %d", afi, safi, v[0].Value[p+3])
 		}
 	}
 
@@ -89,6 +102,32 @@ func (o *OpenMessage) IsMultiLabelCapable() bool {
 	return false
 }
 
+// MultiLabelCapability returns a map of NLRI types and the maximum number of labels the speaker
+// is able to receive for them per rfc8277.
+func (o *OpenMessage) MultiLabelCapability() map[int]uint8 {
+	m := make(map[int]uint8)
+	for _, v := range o.Capabilities[8] {
+		if len(v.Value)%4 != 0 {
+			glog.Errorf("invalid length of Multiple Labels capability %d", len(v.Value))
+			continue
+		}
+		for p := 0; p < len(v.Value); p += 4 {
+			afi := binary.BigEndian.Uint16(v.Value[p : p+2])
+			safi := v.Value[p+2]
+			m[NLRIMessageType(afi, safi)] = v.Value[p+3]
+		}
+	}
+
+	return m
+}
+
+// IsExtendedMessageCapable returns true if Open message originated by a bgp speaker
+// supporting BGP Extended Message per rfc8654
+func (o *OpenMessage) IsExtendedMessageCapable() bool {
+	_, ok := o.Capabilities[6]
+	return ok
+}
+
 // UnmarshalBGPOpenMessage validate information passed in byte slice and returns BGPOpenMessage object
 func UnmarshalBGPOpenMessage(b []byte) (*OpenMessage, error) {
 	if glog.V(6) {
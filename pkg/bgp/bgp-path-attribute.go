@@ -15,7 +15,7 @@ type PathAttribute struct {
 	Attribute          []byte
 }
 
-// UnmarshalBGPPathAttributes builds BGP Path attributes slice
+// UnmarshalBGPPathAttributes builds BGP Path attributes slice, values of the attributes reference the slice.
 func UnmarshalBGPPathAttributes(b []byte) ([]PathAttribute, error) {
 	if glog.V(6) {
 		glog.Infof("BGPPathAttributes Raw: %s", tools.MessageHex(b))
@@ -40,8 +40,7 @@ func UnmarshalBGPPathAttributes(b []byte) ([]PathAttribute, error) {
 			AttributeType:      t,
 			AttributeLength:    l,
 		}
-		pa.Attribute = make([]byte, int(l))
-		copy(pa.Attribute, b[p:p+int(l)])
+		pa.Attribute = b[p : p+int(l)]
 		attrs = append(attrs, pa)
 
 		p += int(l)
@@ -0,0 +1,37 @@
+package bgp
+
+// UpdateCapabilities defines capabilities negotiated by BGP speakers in Open messages, which change the encoding
+// of Update messages sent in one direction of the session.
+type UpdateCapabilities struct {
+	// AddPath lists NLRI types carrying Path Identifier
+	AddPath map[int]bool
+	// MultiLabel lists NLRI types which may carry more than one label
+	MultiLabel map[int]bool
+}
+
+// NegotiateUpdateCapabilities returns capabilities of Update messages sent by the speaker which sent Open message
+// sender to the speaker which sent Open message receiver.
+func NegotiateUpdateCapabilities(sender, receiver *OpenMessage) *UpdateCapabilities {
+	c := &UpdateCapabilities{
+		AddPath:    make(map[int]bool),
+		MultiLabel: make(map[int]bool),
+	}
+	if sender == nil || receiver == nil {
+		return c
+	}
+	// Path Identifier is carried when the sender is able to send and the receiver is able to receive multiple paths
+	rAddPath := receiver.AddPathModes()
+	for t, mode := range sender.AddPathModes() {
+		if mode&AddPathSend != 0 && rAddPath[t]&AddPathReceive != 0 {
+			c.AddPath[t] = true
+		}
+	}
+	// The count advertised by the receiver limits the number of labels the sender may send
+	for t, count := range receiver.MultiLabelCapability() {
+		if count > 1 {
+			c.MultiLabel[t] = true
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,124 @@
+package bgp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegotiateUpdateCapabilities(t *testing.T) {
+	ipv4 := NLRIMessageType(1, 1)
+	lu := NLRIMessageType(1, 4)
+	tests := []struct {
+		name     string
+		sender   Capability
+		receiver Capability
+		expect   *UpdateCapabilities
+	}{
+		{
+			name:     "no capabilities",
+			sender:   Capability{},
+			receiver: Capability{},
+			expect:   &UpdateCapabilities{AddPath: map[int]bool{}, MultiLabel: map[int]bool{}},
+		},
+		{
+			name: "add-path send and receive",
+			sender: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathSendReceive, 0, 1, 128, AddPathSendReceive}}},
+			},
+			receiver: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathSendReceive}}},
+			},
+			expect: &UpdateCapabilities{AddPath: map[int]bool{ipv4: true}, MultiLabel: map[int]bool{}},
+		},
+		{
+			name: "add-path send by sender and receive by receiver",
+			sender: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathSend, 0, 1, 128, AddPathSend}}},
+			},
+			receiver: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathReceive, 0, 1, 128, AddPathSend}}},
+			},
+			expect: &UpdateCapabilities{AddPath: map[int]bool{ipv4: true}, MultiLabel: map[int]bool{}},
+		},
+		{
+			name: "add-path receive by sender",
+			sender: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathReceive}}},
+			},
+			receiver: Capability{
+				69: {{Value: []byte{0, 1, 1, AddPathSend}}},
+			},
+			expect: &UpdateCapabilities{AddPath: map[int]bool{}, MultiLabel: map[int]bool{}},
+		},
+		{
+			name:   "multiple labels received by receiver",
+			sender: Capability{},
+			receiver: Capability{
+				8: {{Value: []byte{0, 1, 4, 3, 0, 1, 128, 1}}},
+			},
+			expect: &UpdateCapabilities{AddPath: map[int]bool{}, MultiLabel: map[int]bool{lu: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NegotiateUpdateCapabilities(&OpenMessage{Capabilities: tt.sender}, &OpenMessage{Capabilities: tt.receiver})
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected capabilities %+v got %+v", *tt.expect, *got)
+			}
+		})
+	}
+}
+
+func TestUpdateSetAS4(t *testing.T) {
+	// AS_PATH of two segments with 2 bytes ASes 1, 2 and 3 which is decoded by default as a single segment with 4 bytes ASes
+	b := []byte{
+		0, 0, // Withdrawn Routes Length
+		0, 17, // Total Path Attribute Length
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 10, 2, 2, 0, 1, 0, 2, 2, 1, 0, 3, // AS_PATH
+		8, 10, // NLRI
+	}
+	tests := []struct {
+		name   string
+		set    bool
+		as4    bool
+		expect []uint32
+	}{
+		{
+			name:   "default",
+			expect: []uint32{0x00010002, 0x02010003},
+		},
+		{
+			name:   "2 bytes as",
+			set:    true,
+			expect: []uint32{1, 2, 3},
+		},
+		{
+			name:   "4 bytes as",
+			set:    true,
+			as4:    true,
+			expect: []uint32{0x00010002, 0x02010003},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := UnmarshalBGPUpdate(b)
+			if err != nil {
+				t.Fatalf("failed to unmarshal update with error: %+v", err)
+			}
+			hash := u.BaseAttributes.BaseAttrHash
+			if tt.set {
+				u.SetAS4(tt.as4)
+			}
+			if !reflect.DeepEqual(u.BaseAttributes.ASPath, tt.expect) {
+				t.Errorf("expected as path %v got %v", tt.expect, u.BaseAttributes.ASPath)
+			}
+			if int(u.BaseAttributes.ASPathCount) != len(tt.expect) {
+				t.Errorf("expected as path count %d got %d", len(tt.expect), u.BaseAttributes.ASPathCount)
+			}
+			if changed := u.BaseAttributes.BaseAttrHash != hash; changed != (tt.set && !tt.as4) {
+				t.Errorf("base attributes hash changed: %t", changed)
+			}
+		})
+	}
+}
@@ -90,6 +90,31 @@ func (up *Update) HasPrefixSID() bool {
 	return false
 }
 
+// SetAS4 decodes AS_PATH attribute of the update with 4 bytes ASes when as4 is true and with 2 bytes ASes otherwise,
+// it is used instead of detecting the size of ASes when the capabilities negotiated by the speakers are known.
+// AS_PATH of 2 bytes ASes carrying AS_TRANS is merged with AS4_PATH attribute.
+func (up *Update) SetAS4(as4 bool) {
+	if up.BaseAttributes == nil {
+		return
+	}
+	var asPath, as4Path []byte
+	for _, attr := range up.PathAttributes {
+		switch attr.AttributeType {
+		case 2:
+			asPath = attr.Attribute
+		case 17:
+			as4Path = attr.Attribute
+		}
+	}
+	if asPath == nil {
+		return
+	}
+	up.BaseAttributes.setASPath(asPath, as4Path, as4)
+	if err := up.BaseAttributes.setHash(); err != nil {
+		glog.Errorf("failed to calculate hash of base attributes with error: %+v", err)
+	}
+}
+
 func (up *Update) GetNLRIType() (uint8, int) {
 	if len(up.PathAttributes) == 0 {
 		// Fall back to default NLRI
@@ -107,7 +132,8 @@ func (up *Update) GetNLRIType() (uint8, int) {
 	return BGP4_NLRI, 0
 }
 
-// UnmarshalBGPUpdate build BGP Update object from the byte slice provided
+// UnmarshalBGPUpdate build BGP Update object from the byte slice provided, Withdrawn Routes, NLRI and
+// Path Attributes of the returned Update reference the slice.
 func UnmarshalBGPUpdate(b []byte) (*Update, error) {
 	if glog.V(6) {
 		glog.Infof("BGPUpdate Raw: %s", tools.MessageHex(b))
@@ -116,8 +142,7 @@ func UnmarshalBGPUpdate(b []byte) (*Update, error) {
 	u := Update{}
 	u.WithdrawnRoutesLength = binary.BigEndian.Uint16(b[p : p+2])
 	p += 2
-	u.WithdrawnRoutes = make([]byte, u.WithdrawnRoutesLength)
-	copy(u.WithdrawnRoutes, b[p:p+int(u.WithdrawnRoutesLength)])
+	u.WithdrawnRoutes = b[p : p+int(u.WithdrawnRoutesLength)]
 	p += int(u.WithdrawnRoutesLength)
 	u.TotalPathAttributeLength = binary.BigEndian.Uint16(b[p : p+2])
 	p += 2
@@ -133,8 +158,7 @@ func UnmarshalBGPUpdate(b []byte) (*Update, error) {
 	u.PathAttributes = attrs
 	u.BaseAttributes = baseAttrs
 	p += int(u.TotalPathAttributeLength)
-	u.NLRI = make([]byte, len(b)-p)
-	copy(u.NLRI, b[p:])
+	u.NLRI = b[p:]
 
 	return &u, nil
 }
@@ -24,7 +24,11 @@ func TestUnmarshalBGPUpdate(t *testing.T) {
 					BaseAttrHash: "3b87061fdf773278959113c6f010f24c",
 					ASPath:       []uint32{65001, 65003},
 					ASPathCount:  2,
-					Origin:       "incomplete",
+					ASPathSegments: []ASPathSegment{
+						{Type: ASSequence, TypeText: "AS_SEQUENCE", ASes: []uint32{65001, 65003}},
+					},
+					OriginAS: 65003,
+					Origin:   "incomplete",
 				},
 				PathAttributes: []PathAttribute{
 					{
@@ -0,0 +1,50 @@
+package bgp
+
+import (
+	"fmt"
+)
+
+// Well-known Communities
+// https://www.iana.org/assignments/bgp-well-known-communities/bgp-well-known-communities.xhtml
+var wellKnownCommunities = map[uint32]string{
+	0xFFFF0000: "GRACEFUL_SHUTDOWN",
+	0xFFFF0001: "ACCEPT_OWN",
+	0xFFFF0002: "ROUTE_FILTER_TRANSLATED_v4",
+	0xFFFF0003: "ROUTE_FILTER_v4",
+	0xFFFF0004: "ROUTE_FILTER_TRANSLATED_v6",
+	0xFFFF0005: "ROUTE_FILTER_v6",
+	0xFFFF0006: "LLGR_STALE",
+	0xFFFF0007: "NO_LLGR",
+	0xFFFF0008: "accept-own-nexthop",
+	0xFFFF0009: "Standby PE",
+	0xFFFF029A: "BLACKHOLE",
+	0xFFFFFF01: "NO_EXPORT",
+	0xFFFFFF02: "NO_ADVERTISE",
+	0xFFFFFF03: "NO_EXPORT_SUBCONFED",
+	0xFFFFFF04: "NOPEER",
+}
+
+// Community defines BGP Community https://tools.ietf.org/html/rfc1997, Name carries the name of well-known community
+type Community struct {
+	ASN   uint16 `json:"asn"`
+	Value uint16 `json:"value"`
+	Name  string `json:"name,omitempty"`
+}
+
+// UnmarshalBGPCommunity builds a slice of Communities
+func UnmarshalBGPCommunity(b []byte) ([]Community, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("invalid length %d of Communities attribute", len(b))
+	}
+	cs := getCommunity(b)
+	comms := make([]Community, len(cs))
+	for i, c := range cs {
+		comms[i] = Community{
+			ASN:   uint16(c >> 16),
+			Value: uint16(c),
+			Name:  wellKnownCommunities[c],
+		}
+	}
+
+	return comms, nil
+}
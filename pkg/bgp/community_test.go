@@ -0,0 +1,52 @@
+package bgp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalBGPCommunity(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		expect []Community
+		fail   bool
+	}{
+		{
+			name:   "communities",
+			input:  []byte{0xfd, 0xe8, 0x00, 0x64, 0x00, 0x00, 0x9a, 0x6d},
+			expect: []Community{{ASN: 65000, Value: 100}, {Value: 39533}},
+		},
+		{
+			name:  "well-known communities",
+			input: []byte{0xff, 0xff, 0xff, 0x01, 0xff, 0xff, 0x02, 0x9a, 0xff, 0xff, 0x00, 0x00},
+			expect: []Community{
+				{ASN: 0xffff, Value: 0xff01, Name: "NO_EXPORT"},
+				{ASN: 0xffff, Value: 0x029a, Name: "BLACKHOLE"},
+				{ASN: 0xffff, Value: 0x0000, Name: "GRACEFUL_SHUTDOWN"},
+			},
+		},
+		{
+			name:  "invalid length",
+			input: []byte{0xfd, 0xe8, 0x00},
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalBGPCommunity(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("expected to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatal("expected to fail but succeeded")
+			}
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Fatalf("Result %+v does not match the expected communities: %+v", got, tt.expect)
+			}
+		})
+	}
+}
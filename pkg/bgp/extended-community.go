@@ -2,11 +2,13 @@ package bgp
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"net"
 
 	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/tunnelencap"
 	"github.com/sbezverk/tools"
 )
 
@@ -67,6 +69,9 @@ func makeExtCommunity(b []byte) (*ExtCommunity, error) {
 
 // UnmarshalBGPExtCommunity builds a slice of Extended Communities
 func UnmarshalBGPExtCommunity(b []byte) ([]ExtCommunity, error) {
+	if len(b)%8 != 0 {
+		return nil, fmt.Errorf("invalid length %d of Extended Communities attribute", len(b))
+	}
 	exts := make([]ExtCommunity, 0)
 	for p := 0; p < len(b); {
 		if glog.V(6) {
@@ -411,3 +416,133 @@ func (ext *ExtCommunity) String() string {
 	}
 	return f(subType, ext.Value)
 }
+
+// TypedExtCommunity defines decoded Extended Community, the fields are set according to the type and the sub type.
+// ASN or IP with Value carry Global and Local Administrator of AS and IPv4 Address Specific Extended Communities,
+// Raw carries the value of Extended Communities which are not decoded as hex.
+type TypedExtCommunity struct {
+	Type           uint8   `json:"type"`
+	SubType        *uint8  `json:"sub_type,omitempty"`
+	TypeText       string  `json:"type_text,omitempty"`
+	ASN            uint32  `json:"asn,omitempty"`
+	IP             string  `json:"ip,omitempty"`
+	Value          uint32  `json:"value,omitempty"`
+	Bandwidth      float32 `json:"bandwidth,omitempty"`
+	TunnelType     uint16  `json:"tunnel_type,omitempty"`
+	TunnelTypeText string  `json:"tunnel_type_text,omitempty"`
+	Color          uint32  `json:"color,omitempty"`
+	Sequence       uint32  `json:"sequence,omitempty"`
+	Sticky         bool    `json:"sticky,omitempty"`
+	SingleActive   bool    `json:"single_active,omitempty"`
+	Label          uint32  `json:"label,omitempty"`
+	VNI            uint32  `json:"vni,omitempty"`
+	MAC            string  `json:"mac,omitempty"`
+	Rate           float32 `json:"rate,omitempty"`
+	Sample         bool    `json:"sample,omitempty"`
+	Terminal       bool    `json:"terminal,omitempty"`
+	DSCP           uint8   `json:"dscp,omitempty"`
+	Raw            string  `json:"raw,omitempty"`
+}
+
+// Names of AS and IPv4 Address Specific Extended Community Sub-Types which are decoded in TypedExtCommunity
+var adminSubTypes = map[uint8]string{
+	0x2:  "Route Target",
+	0x3:  "Route Origin",
+	0x9:  "Source AS",
+	0x0b: "VRF Route Import",
+}
+
+// Typed returns decoded Extended Community
+func (ext *ExtCommunity) Typed() *TypedExtCommunity {
+	t := &TypedExtCommunity{
+		Type:    ext.Type,
+		SubType: ext.SubType,
+	}
+	var subType uint8
+	if ext.SubType == nil {
+		subType = 0xff
+	} else {
+		subType = *ext.SubType
+	}
+	v := ext.Value
+	if ext.Type == 0x08 {
+		// Flow spec redirect to IP next-hop does not carry a value
+		t.TypeText = "Flowspec Redirect to IP Next Hop"
+		return t
+	}
+	if len(v) != 6 {
+		t.Raw = hex.EncodeToString(v)
+		return t
+	}
+	switch {
+	case ext.Type == 0x00:
+		t.TypeText = adminSubTypes[subType]
+		t.ASN = uint32(binary.BigEndian.Uint16(v[0:2]))
+		t.Value = binary.BigEndian.Uint32(v[2:])
+	case ext.Type == 0x01:
+		t.TypeText = adminSubTypes[subType]
+		t.IP = net.IP(v[0:4]).To4().String()
+		t.Value = uint32(binary.BigEndian.Uint16(v[4:]))
+	case ext.Type == 0x02:
+		t.TypeText = adminSubTypes[subType]
+		t.ASN = binary.BigEndian.Uint32(v[0:4])
+		t.Value = uint32(binary.BigEndian.Uint16(v[4:]))
+	case ext.Type == 0x03 && subType == 0x0b:
+		// Color is carried in the last 4 bytes of the value
+		t.TypeText = "Color"
+		t.Color = binary.BigEndian.Uint32(v[0:4])
+	case ext.Type == 0x03 && subType == 0x0c:
+		t.TypeText = "Encapsulation"
+		t.TunnelType = binary.BigEndian.Uint16(v[2:4])
+		t.TunnelTypeText = tunnelencap.TunnelTypeString(t.TunnelType)
+	case ext.Type == 0x06 && subType == 0x00:
+		t.TypeText = "MAC Mobility"
+		t.Sticky = v[0]&0x01 == 0x01
+		t.Sequence = binary.BigEndian.Uint32(v[2:])
+	case ext.Type == 0x06 && subType == 0x01:
+		t.TypeText = "ESI Label"
+		t.SingleActive = v[0]&0x01 == 0x01
+		// The label field carries either MPLS label or VNI, RFC 8365
+		t.VNI = uint32(v[3])<<16 | uint32(v[4])<<8 | uint32(v[5])
+		t.Label = t.VNI >> 4
+	case ext.Type == 0x06 && subType == 0x02:
+		t.TypeText = "ES-Import Route Target"
+		t.MAC = net.HardwareAddr(v).String()
+	case ext.Type == 0x06 && subType == 0x03:
+		t.TypeText = "Router MAC"
+		t.MAC = net.HardwareAddr(v).String()
+	case ext.Type == 0x40 && subType == 0x04:
+		// Bandwidth is in bytes per second
+		t.TypeText = "Link Bandwidth"
+		t.ASN = uint32(binary.BigEndian.Uint16(v[0:2]))
+		t.Bandwidth = math.Float32frombits(binary.BigEndian.Uint32(v[2:]))
+	case ext.Type == 0x80 && subType == 0x06:
+		// Rate is in bytes per second
+		t.TypeText = "Flowspec Traffic Rate"
+		t.ASN = uint32(binary.BigEndian.Uint16(v[0:2]))
+		t.Rate = math.Float32frombits(binary.BigEndian.Uint32(v[2:]))
+	case ext.Type == 0x80 && subType == 0x07:
+		t.TypeText = "Flowspec Traffic Action"
+		t.Sample = v[5]&0x02 == 0x02
+		t.Terminal = v[5]&0x01 == 0x01
+	case ext.Type == 0x80 && subType == 0x09:
+		t.TypeText = "Flowspec Traffic Remarking"
+		t.DSCP = v[5] & 0x3f
+	case ext.Type == 0x80 && subType == 0x08:
+		t.TypeText = "Flowspec Redirect"
+		t.ASN = uint32(binary.BigEndian.Uint16(v[0:2]))
+		t.Value = binary.BigEndian.Uint32(v[2:])
+	case ext.Type == 0x81 && subType == 0x08:
+		t.TypeText = "Flowspec Redirect"
+		t.IP = net.IP(v[0:4]).To4().String()
+		t.Value = uint32(binary.BigEndian.Uint16(v[4:]))
+	case ext.Type == 0x82 && subType == 0x08:
+		t.TypeText = "Flowspec Redirect"
+		t.ASN = binary.BigEndian.Uint32(v[0:4])
+		t.Value = uint32(binary.BigEndian.Uint16(v[4:]))
+	default:
+		t.Raw = hex.EncodeToString(v)
+	}
+
+	return t
+}
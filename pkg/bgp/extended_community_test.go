@@ -1,6 +1,7 @@
 package bgp
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -45,3 +46,106 @@ func TestExtendedCommunity(t *testing.T) {
 		})
 	}
 }
+
+func TestTypedExtendedCommunity(t *testing.T) {
+	subType := func(st uint8) *uint8 { return &st }
+	tests := []struct {
+		name   string
+		input  []byte
+		expect *TypedExtCommunity
+	}{
+		{
+			name:   "two-octet as route target",
+			input:  []byte{0x00, 0x02, 0xfd, 0xe8, 0x00, 0x00, 0x00, 0x64},
+			expect: &TypedExtCommunity{Type: 0x00, SubType: subType(0x02), TypeText: "Route Target", ASN: 65000, Value: 100},
+		},
+		{
+			name:   "ipv4 address route origin",
+			input:  []byte{0x01, 0x03, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x64},
+			expect: &TypedExtCommunity{Type: 0x01, SubType: subType(0x03), TypeText: "Route Origin", IP: "192.0.2.1", Value: 100},
+		},
+		{
+			name:   "four-octet as route target",
+			input:  []byte{0x02, 0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x64},
+			expect: &TypedExtCommunity{Type: 0x02, SubType: subType(0x02), TypeText: "Route Target", ASN: 65536, Value: 100},
+		},
+		{
+			name:   "link bandwidth",
+			input:  []byte{0x40, 0x04, 0xfd, 0xe8, 0x4b, 0x3e, 0xbc, 0x20},
+			expect: &TypedExtCommunity{Type: 0x40, SubType: subType(0x04), TypeText: "Link Bandwidth", ASN: 65000, Bandwidth: 12500000},
+		},
+		{
+			name:  "encapsulation",
+			input: []byte{0x03, 0x0c, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08},
+			expect: &TypedExtCommunity{
+				Type:           0x03,
+				SubType:        subType(0x0c),
+				TypeText:       "Encapsulation",
+				TunnelType:     8,
+				TunnelTypeText: "VXLAN",
+			},
+		},
+		{
+			name:   "color",
+			input:  []byte{0x03, 0x0b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64},
+			expect: &TypedExtCommunity{Type: 0x03, SubType: subType(0x0b), TypeText: "Color", Color: 100},
+		},
+		{
+			name:   "sticky mac mobility",
+			input:  []byte{0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x05},
+			expect: &TypedExtCommunity{Type: 0x06, SubType: subType(0x00), TypeText: "MAC Mobility", Sticky: true, Sequence: 5},
+		},
+		{
+			name:   "esi label",
+			input:  []byte{0x06, 0x01, 0x01, 0x00, 0x00, 0x00, 0x01, 0x41},
+			expect: &TypedExtCommunity{Type: 0x06, SubType: subType(0x01), TypeText: "ESI Label", SingleActive: true, Label: 20, VNI: 321},
+		},
+		{
+			name:   "router mac",
+			input:  []byte{0x06, 0x03, 0x0c, 0x03, 0x00, 0x00, 0x1b, 0x08},
+			expect: &TypedExtCommunity{Type: 0x06, SubType: subType(0x03), TypeText: "Router MAC", MAC: "0c:03:00:00:1b:08"},
+		},
+		{
+			name:   "flowspec traffic rate",
+			input:  []byte{0x80, 0x06, 0xfd, 0xe8, 0x4b, 0x3e, 0xbc, 0x20},
+			expect: &TypedExtCommunity{Type: 0x80, SubType: subType(0x06), TypeText: "Flowspec Traffic Rate", ASN: 65000, Rate: 12500000},
+		},
+		{
+			name:   "flowspec traffic action",
+			input:  []byte{0x80, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03},
+			expect: &TypedExtCommunity{Type: 0x80, SubType: subType(0x07), TypeText: "Flowspec Traffic Action", Sample: true, Terminal: true},
+		},
+		{
+			name:   "flowspec traffic remarking",
+			input:  []byte{0x80, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2e},
+			expect: &TypedExtCommunity{Type: 0x80, SubType: subType(0x09), TypeText: "Flowspec Traffic Remarking", DSCP: 46},
+		},
+		{
+			name:   "flowspec redirect to ipv4 address",
+			input:  []byte{0x81, 0x08, 0xc0, 0x00, 0x02, 0x01, 0x00, 0x64},
+			expect: &TypedExtCommunity{Type: 0x81, SubType: subType(0x08), TypeText: "Flowspec Redirect", IP: "192.0.2.1", Value: 100},
+		},
+		{
+			name:   "flowspec redirect to ip next hop",
+			input:  []byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expect: &TypedExtCommunity{Type: 0x08, TypeText: "Flowspec Redirect to IP Next Hop"},
+		},
+		{
+			name:   "unknown type",
+			input:  []byte{0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+			expect: &TypedExtCommunity{Type: 0x05, Raw: "01020304050607"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext, err := makeExtCommunity(tt.input)
+			if err != nil {
+				t.Fatalf("with error: %+v", err)
+			}
+			result := ext.Typed()
+			if !reflect.DeepEqual(tt.expect, result) {
+				t.Errorf("Result %+v does not match the expected community: %+v", result, tt.expect)
+			}
+		})
+	}
+}
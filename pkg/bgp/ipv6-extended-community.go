@@ -0,0 +1,75 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Transitive IPv6-Address-Specific Extended Community Sub-Types
+// 0x02	Route Target	[RFC5701]
+// 0x03	Route Origin	[RFC5701]
+// 0x0b	VRF Route Import	[RFC6515]
+// 0x0c	Flow-spec Redirect to IPv6	[draft-ietf-idr-flow-spec-v6]
+// 0x10	Cisco VPN-Distinguisher	[Eric_Rosen]
+// 0x12	Inter-Area P2MP Segmented Next-Hop	[RFC7524]
+var transIPv6SubTypes = map[uint8]string{
+	0x2:  ECPRouteTarget,
+	0x3:  ECPRouteOrigin,
+	0x0b: ECPVRFRouteImport,
+	0x0c: ECPFlowSpecRedirIPv6,
+	0x10: ECPCiscoVPNDistinguisher,
+	0x12: ECPInterAreaP2MPSegmentedNexyHop,
+}
+
+// IPv6ExtCommunity defines IPv6 Address Specific Extended Community https://tools.ietf.org/html/rfc5701
+type IPv6ExtCommunity struct {
+	Type        uint8
+	SubType     uint8
+	GlobalAdmin net.IP
+	LocalAdmin  uint16
+}
+
+func (c *IPv6ExtCommunity) String() string {
+	prefix, ok := transIPv6SubTypes[c.SubType]
+	if !ok || c.Type&0x3f != 0 {
+		prefix = fmt.Sprintf("unknown=Type: %d Subtype: %d Value: ", c.Type, c.SubType)
+	}
+
+	return prefix + c.GlobalAdmin.String() + ":" + strconv.Itoa(int(c.LocalAdmin))
+}
+
+// UnmarshalBGPIPv6ExtCommunity builds a slice of IPv6 Address Specific Extended Communities
+func UnmarshalBGPIPv6ExtCommunity(b []byte) ([]IPv6ExtCommunity, error) {
+	if len(b)%20 != 0 {
+		return nil, fmt.Errorf("invalid length %d of IPv6 Address Specific Extended Community attribute", len(b))
+	}
+	exts := make([]IPv6ExtCommunity, 0, len(b)/20)
+	for p := 0; p < len(b); p += 20 {
+		ext := IPv6ExtCommunity{
+			Type:        b[p],
+			SubType:     b[p+1],
+			GlobalAdmin: make(net.IP, 16),
+			LocalAdmin:  binary.BigEndian.Uint16(b[p+18 : p+20]),
+		}
+		copy(ext.GlobalAdmin, b[p+2:p+18])
+		exts = append(exts, ext)
+	}
+
+	return exts, nil
+}
+
+// unmarshalAttrIPv6ExtCommunity returns a slice with all IPv6 Address Specific extended communities found in bgp update
+func unmarshalAttrIPv6ExtCommunity(b []byte) []string {
+	ext, err := UnmarshalBGPIPv6ExtCommunity(b)
+	if err != nil {
+		return nil
+	}
+	s := make([]string, len(ext))
+	for i, c := range ext {
+		s[i] = c.String()
+	}
+
+	return s
+}
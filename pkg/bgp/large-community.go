@@ -9,9 +9,9 @@ import (
 
 // LgCommunity defines BGP Large Commuity https://tools.ietf.org/html/rfc8092
 type LgCommunity struct {
-	GlobalAdmin uint32
-	LocalData1  uint32
-	LocalData2  uint32
+	GlobalAdmin uint32 `json:"global_admin"`
+	LocalData1  uint32 `json:"local_data1"`
+	LocalData2  uint32 `json:"local_data2"`
 }
 
 func makeLgCommunity(b []byte) (*LgCommunity, error) {
@@ -32,6 +32,9 @@ func (lg *LgCommunity) String() string {
 
 // UnmarshalBGPLgCommunity builds a slice of Large Communities
 func UnmarshalBGPLgCommunity(b []byte) ([]LgCommunity, error) {
+	if len(b)%12 != 0 {
+		return nil, fmt.Errorf("invalid length %d of Large Communities attribute", len(b))
+	}
 	lgs := make([]LgCommunity, 0)
 	for p := 0; p < len(b); {
 		lg, err := makeLgCommunity(b[p : p+12])
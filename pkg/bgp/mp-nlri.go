@@ -76,3 +76,12 @@ func NLRIMessageType(afi uint16, safi uint8) int {
 
 	return 0
 }
+
+// multipleLabels returns true if NLRI of the type may carry more than one label, when multiLabel is nil
+// the capabilities of the speakers are not known and labels are decoded up to Bottom of Stack.
+func multipleLabels(multiLabel map[int]bool, t int) bool {
+	if multiLabel == nil {
+		return true
+	}
+	return multiLabel[t]
+}
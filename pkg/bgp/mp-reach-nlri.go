@@ -26,8 +26,9 @@ type MPReachNLRI struct {
 	NLRI                 []byte
 	// When BGP update carries Prefix SID attribute 40, the processing of some AFI/SAFI NLRIs
 	// may differ from the standard processing.
-	SRv6    bool
-	addPath map[int]bool
+	SRv6       bool
+	addPath    map[int]bool
+	multiLabel map[int]bool
 }
 
 // GetAFISAFIType returns underlaying NLRI's type based on AFI/SAFI
@@ -119,7 +120,7 @@ func (mp *MPReachNLRI) GetNLRI73() (*srpolicy.NLRI73, error) {
 func (mp *MPReachNLRI) GetNLRIL3VPN() (*base.MPNLRI, error) {
 	if (mp.AddressFamilyID == 1 || mp.AddressFamilyID == 2) && mp.SubAddressFamilyID == 128 {
 		pathID := mp.addPath[NLRIMessageType(mp.AddressFamilyID, mp.SubAddressFamilyID)]
-		nlri, err := l3vpn.UnmarshalL3VPNNLRI(mp.NLRI, pathID, mp.SRv6)
+		nlri, err := l3vpn.UnmarshalL3VPNNLRI(mp.NLRI, pathID, multipleLabels(mp.multiLabel, mp.GetAFISAFIType()), mp.SRv6)
 		if err != nil {
 			return nil, err
 		}
@@ -163,7 +164,7 @@ func (mp *MPReachNLRI) GetNLRIUnicast() (*base.MPNLRI, error) {
 func (mp *MPReachNLRI) GetNLRILU() (*base.MPNLRI, error) {
 	if (mp.AddressFamilyID == 1 || mp.AddressFamilyID == 2) && mp.SubAddressFamilyID == 4 {
 		pathID := mp.addPath[NLRIMessageType(mp.AddressFamilyID, mp.SubAddressFamilyID)]
-		nlri, err := unicast.UnmarshalLUNLRI(mp.NLRI, pathID)
+		nlri, err := unicast.UnmarshalLUNLRI(mp.NLRI, pathID, multipleLabels(mp.multiLabel, mp.GetAFISAFIType()))
 		if err != nil {
 			return nil, err
 		}
@@ -184,17 +185,23 @@ func (mp *MPReachNLRI) GetFlowspecNLRI() (*flowspec.NLRI, error) {
 	return nil, fmt.Errorf("not found")
 }
 
-// UnmarshalMPReachNLRI builds MP Reach NLRI attributes
-func UnmarshalMPReachNLRI(b []byte, srv6 bool, addPath map[int]bool) (MPNLRI, error) {
+// UnmarshalMPReachNLRI builds MP Reach NLRI attributes, NLRI of the returned object references the slice.
+// addPath lists NLRI types carrying Path Identifier, multiLabel lists NLRI types which may carry more than
+// one label, when multiLabel is nil, labels of all NLRI types are decoded up to Bottom of Stack.
+func UnmarshalMPReachNLRI(b []byte, srv6 bool, addPath, multiLabel map[int]bool) (MPNLRI, error) {
 	if glog.V(6) {
-		glog.Infof("MPReachNLRI Raw: %s SRv6 flag: %t add path: %+v", tools.MessageHex(b), srv6, addPath)
+		glog.Infof("MPReachNLRI Raw: %s SRv6 flag: %t add path: %+v multiple labels: %+v", tools.MessageHex(b), srv6, addPath, multiLabel)
 	}
 	if len(b) == 0 {
 		return nil, fmt.Errorf("NLRI length is 0")
 	}
 	mp := MPReachNLRI{
-		addPath: addPath,
-		SRv6:    srv6,
+		addPath:    addPath,
+		multiLabel: multiLabel,
+		SRv6:       srv6,
+	}
+	if len(b) < 5 {
+		return nil, fmt.Errorf("invalid length %d of MP_REACH_NLRI", len(b))
 	}
 	p := 0
 	mp.AddressFamilyID = binary.BigEndian.Uint16(b[p : p+2])
@@ -203,13 +210,15 @@ func UnmarshalMPReachNLRI(b []byte, srv6 bool, addPath map[int]bool) (MPNLRI, er
 	p++
 	mp.NextHopAddressLength = uint8(b[p])
 	p++
+	if p+int(mp.NextHopAddressLength)+1 > len(b) {
+		return nil, fmt.Errorf("invalid next hop length %d of MP_REACH_NLRI", mp.NextHopAddressLength)
+	}
 	mp.NextHopAddress = make([]byte, mp.NextHopAddressLength)
 	copy(mp.NextHopAddress, b[p:p+int(mp.NextHopAddressLength)])
 	p += int(mp.NextHopAddressLength)
 	// Skip reserved byte
 	p++
-	mp.NLRI = make([]byte, len(b[p:]))
-	copy(mp.NLRI, b[p:])
+	mp.NLRI = b[p:]
 
 	return &mp, nil
 }
@@ -46,7 +46,7 @@ func TestUnmarshalMPReachNLRI(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual, err := UnmarshalMPReachNLRI(tt.input, tt.srv6, tt.addPath)
+			actual, err := UnmarshalMPReachNLRI(tt.input, tt.srv6, tt.addPath, nil)
 			if err != nil {
 				t.Fatalf("failed to unmarshal MP Reach NLRI with error: %+v", err)
 			}
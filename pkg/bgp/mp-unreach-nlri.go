@@ -21,6 +21,7 @@ type MPUnReachNLRI struct {
 	SubAddressFamilyID uint8
 	WithdrawnRoutes    []byte
 	addPath            map[int]bool
+	multiLabel         map[int]bool
 }
 
 // GetAFISAFIType returns underlaying NLRI's type based on AFI/SAFI
@@ -76,7 +77,7 @@ func (mp *MPUnReachNLRI) GetNLRI73() (*srpolicy.NLRI73, error) {
 func (mp *MPUnReachNLRI) GetNLRIL3VPN() (*base.MPNLRI, error) {
 	if mp.AddressFamilyID == 1 && mp.SubAddressFamilyID == 128 {
 		pathID := mp.addPath[NLRIMessageType(mp.AddressFamilyID, mp.SubAddressFamilyID)]
-		nlri, err := l3vpn.UnmarshalL3VPNNLRI(mp.WithdrawnRoutes, pathID)
+		nlri, err := l3vpn.UnmarshalL3VPNNLRI(mp.WithdrawnRoutes, pathID, multipleLabels(mp.multiLabel, mp.GetAFISAFIType()))
 		if err != nil {
 			return nil, err
 		}
@@ -120,7 +121,7 @@ func (mp *MPUnReachNLRI) GetNLRIUnicast() (*base.MPNLRI, error) {
 func (mp *MPUnReachNLRI) GetNLRILU() (*base.MPNLRI, error) {
 	if (mp.AddressFamilyID == 1 || mp.AddressFamilyID == 2) && mp.SubAddressFamilyID == 4 {
 		pathID := mp.addPath[NLRIMessageType(mp.AddressFamilyID, mp.SubAddressFamilyID)]
-		nlri, err := unicast.UnmarshalLUNLRI(mp.WithdrawnRoutes, pathID)
+		nlri, err := unicast.UnmarshalLUNLRI(mp.WithdrawnRoutes, pathID, multipleLabels(mp.multiLabel, mp.GetAFISAFIType()))
 		if err != nil {
 			return nil, err
 		}
@@ -141,8 +142,9 @@ func (mp *MPUnReachNLRI) GetFlowspecNLRI() (*flowspec.NLRI, error) {
 	return nil, fmt.Errorf("not found")
 }
 
-// UnmarshalMPUnReachNLRI builds MP Reach NLRI attributes
-func UnmarshalMPUnReachNLRI(b []byte, addPath map[int]bool) (MPNLRI, error) {
+// UnmarshalMPUnReachNLRI builds MP Unreach NLRI attributes, Withdrawn Routes of the returned object references the slice.
+// addPath and multiLabel are used the same way as by UnmarshalMPReachNLRI.
+func UnmarshalMPUnReachNLRI(b []byte, addPath, multiLabel map[int]bool) (MPNLRI, error) {
 	if glog.V(6) {
 		glog.Infof("MPUnReachNLRI Raw: %s", tools.MessageHex(b))
 	}
@@ -150,15 +152,18 @@ func UnmarshalMPUnReachNLRI(b []byte, addPath map[int]bool) (MPNLRI, error) {
 		return nil, fmt.Errorf("NLRI length is 0")
 	}
 	mp := MPUnReachNLRI{
-		addPath: addPath,
+		addPath:    addPath,
+		multiLabel: multiLabel,
+	}
+	if len(b) < 3 {
+		return nil, fmt.Errorf("invalid length %d of MP_UNREACH_NLRI", len(b))
 	}
 	p := 0
 	mp.AddressFamilyID = binary.BigEndian.Uint16(b[p : p+2])
 	p += 2
 	mp.SubAddressFamilyID = uint8(b[p])
 	p++
-	mp.WithdrawnRoutes = make([]byte, len(b[p:]))
-	copy(mp.WithdrawnRoutes, b[p:])
+	mp.WithdrawnRoutes = b[p:]
 
 	return &mp, nil
 }
@@ -0,0 +1,89 @@
+package bgp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+)
+
+// PMSI Tunnel Types
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#pmsi-tunnel-types
+const (
+	PMSITunnelNoInfo              = 0
+	PMSITunnelRSVPTEP2MP          = 1
+	PMSITunnelMLDPP2MP            = 2
+	PMSITunnelPIMSSM              = 3
+	PMSITunnelPIMSM               = 4
+	PMSITunnelBIDIRPIM            = 5
+	PMSITunnelIngressReplication  = 6
+	PMSITunnelMLDPMP2MP           = 7
+	PMSITunnelTransport           = 8
+	PMSITunnelAssistedReplication = 9
+	PMSITunnelBIER                = 0x0b
+	PMSITunnelSRMPLSP2MP          = 0x0c
+)
+
+var pmsiTunnelTypes = map[uint8]string{
+	PMSITunnelNoInfo:              "No tunnel information present",
+	PMSITunnelRSVPTEP2MP:          "RSVP-TE P2MP LSP",
+	PMSITunnelMLDPP2MP:            "mLDP P2MP LSP",
+	PMSITunnelPIMSSM:              "PIM-SSM Tree",
+	PMSITunnelPIMSM:               "PIM-SM Tree",
+	PMSITunnelBIDIRPIM:            "BIDIR-PIM Tree",
+	PMSITunnelIngressReplication:  "Ingress Replication",
+	PMSITunnelMLDPMP2MP:           "mLDP MP2MP LSP",
+	PMSITunnelTransport:           "Transport Tunnel",
+	PMSITunnelAssistedReplication: "Assisted Replication Tunnel",
+	PMSITunnelBIER:                "BIER",
+	PMSITunnelSRMPLSP2MP:          "SR MPLS P2MP Tree",
+}
+
+// PMSITunnel defines PMSI Tunnel attribute, RFC 6514 section 5. Label carries 20 bits MPLS label, VNI carries
+// all 24 bits of the field which are used as VNI by EVPN VXLAN encapsulations, RFC 8365.
+type PMSITunnel struct {
+	LeafInfoRequired bool   `json:"leaf_info_required,omitempty"`
+	TunnelType       uint8  `json:"tunnel_type"`
+	TunnelTypeText   string `json:"tunnel_type_text,omitempty"`
+	Label            uint32 `json:"label,omitempty"`
+	VNI              uint32 `json:"vni,omitempty"`
+	TunnelID         string `json:"tunnel_id,omitempty"`
+}
+
+// UnmarshalPMSITunnel builds PMSI Tunnel attribute object
+func UnmarshalPMSITunnel(b []byte) (*PMSITunnel, error) {
+	if len(b) < 5 {
+		return nil, fmt.Errorf("invalid length %d of PMSI Tunnel attribute", len(b))
+	}
+	pmsi := &PMSITunnel{
+		LeafInfoRequired: b[0]&0x01 == 0x01,
+		TunnelType:       b[1],
+		TunnelTypeText:   pmsiTunnelTypes[b[1]],
+	}
+	field := uint32(b[2])<<16 | uint32(b[3])<<8 | uint32(b[4])
+	pmsi.Label = field >> 4
+	pmsi.VNI = field
+	pmsi.TunnelID = pmsiTunnelID(pmsi.TunnelType, b[5:])
+
+	return pmsi, nil
+}
+
+// pmsiTunnelID returns the string representation of Tunnel Identifier, addresses of PIM trees are returned
+// as sender and group addresses, addresses of replication tunnels as the address of the endpoint
+// and identifiers of other types as hex.
+func pmsiTunnelID(t uint8, b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch t {
+	case PMSITunnelPIMSSM, PMSITunnelPIMSM, PMSITunnelBIDIRPIM:
+		if len(b) == 8 || len(b) == 32 {
+			return net.IP(b[:len(b)/2]).String() + "," + net.IP(b[len(b)/2:]).String()
+		}
+	case PMSITunnelIngressReplication, PMSITunnelAssistedReplication:
+		if len(b) == 4 || len(b) == 16 {
+			return net.IP(b).String()
+		}
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -56,4 +56,8 @@ const (
 	FlowspecV4Msg = 164
 	// FlowspecV6Msg defines BMP Route Monitoring message carrying Flowspec NLRI
 	FlowspecV6Msg = 166
+	// RouterMsg defines a message generated from BMP Initiation and Termination messages
+	RouterMsg = 17
+	// BMPRawMsg defines a raw BMP message prepended with OpenBMP binary header
+	BMPRawMsg = 18
 )
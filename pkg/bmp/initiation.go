@@ -22,6 +22,9 @@ func UnmarshalInitiationMessage(b []byte) (*InitiationMessage, error) {
 		TLV: make([]InformationalTLV, 0),
 	}
 	for i := 0; i < len(b); {
+		if i+4 > len(b) {
+			return nil, fmt.Errorf("not enough bytes to unmarshal initiation tlv")
+		}
 		// Extracting TLV type 2 bytes
 		t := int16(binary.BigEndian.Uint16(b[i : i+2]))
 		switch t {
@@ -1,8 +1,15 @@
 package bmp
 
+import "time"
+
 // Message defines a message used to transfer BMP messages for further processing
 // for BMP messages which do not carry PerPeerHeader, it will be set to nil.
 type Message struct {
 	PeerHeader *PerPeerHeader
 	Payload    interface{}
+	// Done, when not nil, must be called by the consumer once it is done with the message,
+	// after Done is called, the Payload may no longer reference valid data.
+	Done func()
+	// Received is the time the message was received from the router, zero when not known
+	Received time.Time
 }
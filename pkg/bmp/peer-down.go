@@ -1,12 +1,48 @@
 package bmp
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bgp"
 	"github.com/sbezverk/tools"
 )
 
+// Peer Down reasons per rfc7854 and rfc9069
+const (
+	// PeerDownLocalNotification indicates that the local system closed the session, BGP Notification follows
+	PeerDownLocalNotification = 1
+	// PeerDownLocalNoNotification indicates that the local system closed the session, FSM event code follows
+	PeerDownLocalNoNotification = 2
+	// PeerDownRemoteNotification indicates that the remote system closed the session, BGP Notification follows
+	PeerDownRemoteNotification = 3
+	// PeerDownRemoteNoData indicates that the remote system closed the session without a notification
+	PeerDownRemoteNoData = 4
+	// PeerDownDeconfigured indicates that the peer was de-configured
+	PeerDownDeconfigured = 5
+	// PeerDownLocalTLV indicates that the local system closed the session, Information TLVs follow,
+	// it is used when Loc-RIB instance is de-configured.
+	PeerDownLocalTLV = 6
+)
+
+var peerDownReasons = map[uint8]string{
+	PeerDownLocalNotification:   "Local system closed the session with a notification",
+	PeerDownLocalNoNotification: "Local system closed the session without a notification",
+	PeerDownRemoteNotification:  "Remote system closed the session with a notification",
+	PeerDownRemoteNoData:        "Remote system closed the session without a notification",
+	PeerDownDeconfigured:        "Peer de-configured",
+	PeerDownLocalTLV:            "Local system closed the session",
+}
+
+// PeerDownReasonString returns the description of Peer Down reason
+func PeerDownReasonString(reason uint8) string {
+	if s, ok := peerDownReasons[reason]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown reason %d", reason)
+}
+
 // PeerDownMessage defines BMPPeerDownMessage per rfc7854
 type PeerDownMessage struct {
 	Reason uint8
@@ -18,16 +54,74 @@ func UnmarshalPeerDownMessage(b []byte) (*PeerDownMessage, error) {
 	if glog.V(6) {
 		glog.Infof("BMP Peer Down Message Raw: %s", tools.MessageHex(b))
 	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("not enough bytes to unmarshal Peer Down message")
+	}
 	pdw := &PeerDownMessage{
 		Data: make([]byte, len(b)-1),
 	}
 	p := 0
 	pdw.Reason = b[p]
 	p++
-	if pdw.Reason < 1 || pdw.Reason > 5 {
+	if pdw.Reason < PeerDownLocalNotification || pdw.Reason > PeerDownLocalTLV {
 		return nil, fmt.Errorf("invalid reason code %d in Peer Down message", pdw.Reason)
 	}
 	copy(pdw.Data, b[p:])
 
 	return pdw, nil
 }
+
+// Notification returns BGP Notification message carried by Peer Down messages with reasons 1 and 3,
+// nil is returned for other reasons.
+func (pdw *PeerDownMessage) Notification() (*bgp.NotificationMessage, error) {
+	if pdw.Reason != PeerDownLocalNotification && pdw.Reason != PeerDownRemoteNotification {
+		return nil, nil
+	}
+	if len(pdw.Data) < bgp.BGPMinNotificationMessageLength {
+		return nil, fmt.Errorf("invalid length %d of BGP Notification in Peer Down message", len(pdw.Data))
+	}
+	// Skipping 16 bytes of the marker
+	return bgp.UnmarshalBGPNotificationMessage(pdw.Data[16:])
+}
+
+// FSMEvent returns FSM event code carried by Peer Down messages with reason 2,
+// the second value is false if the message does not carry it.
+func (pdw *PeerDownMessage) FSMEvent() (uint16, bool) {
+	if pdw.Reason != PeerDownLocalNoNotification || len(pdw.Data) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(pdw.Data), true
+}
+
+// Information returns Information TLVs carried by Peer Down messages with reason 6
+func (pdw *PeerDownMessage) Information() ([]InformationalTLV, error) {
+	if pdw.Reason != PeerDownLocalTLV {
+		return nil, nil
+	}
+	for i := 0; i < len(pdw.Data); {
+		if i+4 > len(pdw.Data) {
+			return nil, fmt.Errorf("not enough bytes to unmarshal Peer Down information tlv")
+		}
+		l := int(binary.BigEndian.Uint16(pdw.Data[i+2 : i+4]))
+		if i+4+l > len(pdw.Data) {
+			return nil, fmt.Errorf("invalid Peer Down information tlv length %d", l)
+		}
+		i += 4 + l
+	}
+
+	return UnmarshalTLV(pdw.Data)
+}
+
+// GetTableName returns the value of VRF/Table Name TLV, or empty string if the message does not carry it
+func (pdw *PeerDownMessage) GetTableName() string {
+	tlvs, err := pdw.Information()
+	if err != nil {
+		return ""
+	}
+	for _, tlv := range tlvs {
+		if tlv.InformationType == PeerUpInfoVRFTableName {
+			return string(tlv.Information)
+		}
+	}
+	return ""
+}
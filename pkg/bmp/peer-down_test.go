@@ -32,3 +32,85 @@ func TestPeerDownMsg(t *testing.T) {
 		})
 	}
 }
+
+func TestPeerDownReasonData(t *testing.T) {
+	marker := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	tests := []struct {
+		name         string
+		input        []byte
+		fail         bool
+		notification bool
+		notifyFail   bool
+		fsmEvent     uint16
+		hasFSMEvent  bool
+		table        string
+	}{
+		{
+			name:         "remote notification",
+			input:        append(append([]byte{3}, marker...), 0x00, 0x15, 0x03, 0x06, 0x02),
+			notification: true,
+		},
+		{
+			name:       "local notification truncated",
+			input:      append([]byte{1}, marker[:10]...),
+			notifyFail: true,
+		},
+		{
+			name:        "local fsm event",
+			input:       []byte{2, 0x00, 0x12},
+			fsmEvent:    18,
+			hasFSMEvent: true,
+		},
+		{
+			name:  "remote no data",
+			input: []byte{4},
+		},
+		{
+			name:  "peer de-configured",
+			input: []byte{5},
+		},
+		{
+			name:  "loc-rib de-configured",
+			input: []byte{6, 0x00, 0x03, 0x00, 0x04, 'b', 'l', 'u', 'e'},
+			table: "blue",
+		},
+		{
+			name:  "invalid reason",
+			input: []byte{7},
+			fail:  true,
+		},
+		{
+			name:  "empty",
+			input: []byte{},
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdw, err := UnmarshalPeerDownMessage(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("supposed to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			n, err := pdw.Notification()
+			if (err != nil) != tt.notifyFail {
+				t.Fatalf("unexpected notification error: %+v", err)
+			}
+			if (n != nil) != tt.notification {
+				t.Errorf("expected notification %t got %+v", tt.notification, n)
+			}
+			e, ok := pdw.FSMEvent()
+			if ok != tt.hasFSMEvent || e != tt.fsmEvent {
+				t.Errorf("expected fsm event %d/%t got %d/%t", tt.fsmEvent, tt.hasFSMEvent, e, ok)
+			}
+			if s := pdw.GetTableName(); s != tt.table {
+				t.Errorf("expected table name %q got %q", tt.table, s)
+			}
+		})
+	}
+}
@@ -9,6 +9,14 @@ import (
 	"github.com/sbezverk/tools"
 )
 
+// Peer Up Information TLV types
+const (
+	// PeerUpInfoString defines free-form string TLV per rfc7854
+	PeerUpInfoString = 0
+	// PeerUpInfoVRFTableName defines VRF/Table Name TLV per rfc9069
+	PeerUpInfoVRFTableName = 3
+)
+
 // PeerUpMessage defines BMPPeerUpMessage per rfc7854
 type PeerUpMessage struct {
 	LocalAddress     []byte
@@ -27,6 +35,16 @@ func (pum *PeerUpMessage) GetLocalAddressString() string {
 	return net.IP(pum.LocalAddress[12:]).To4().String()
 }
 
+// GetTableName returns the value of VRF/Table Name TLV, or empty string if the message does not carry it
+func (pum *PeerUpMessage) GetTableName() string {
+	for _, tlv := range pum.Information {
+		if tlv.InformationType == PeerUpInfoVRFTableName {
+			return string(tlv.Information)
+		}
+	}
+	return ""
+}
+
 // UnmarshalPeerUpMessage processes Peer Up message and returns BMPPeerUpMessage object
 func UnmarshalPeerUpMessage(b []byte, isIPv6 bool) (*PeerUpMessage, error) {
 	if glog.V(6) {
@@ -131,6 +131,16 @@ func (p *PerPeerHeader) IsAdjRIBInPost() (bool, error) {
 	return false, ErrInvFlagRequestForPeerType
 }
 
+// IsLegacyASPath returns true if PeerType is 0,1 or 2 and A flag is set indicating 2 bytes AS_PATH format,
+// otherwise it returns error
+func (p *PerPeerHeader) IsLegacyASPath() (bool, error) {
+	if p.PeerType != PeerType3 {
+		return p.flagA, nil
+	}
+
+	return false, ErrInvFlagRequestForPeerType
+}
+
 // IsLocRIBFiltered returns true if PeerType is 3 and F flag is set, otherwise it returns error
 func (p *PerPeerHeader) IsLocRIBFiltered() (bool, error) {
 	if p.PeerType == PeerType3 {
@@ -0,0 +1,89 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/tools"
+)
+
+// Route Mirroring TLV types per rfc7854
+const (
+	// RouteMirrorBGPMessageTLV defines BGP Message TLV carrying a mirrored BGP PDU
+	RouteMirrorBGPMessageTLV = 0
+	// RouteMirrorInformationTLV defines Information TLV carrying a 2 bytes code
+	RouteMirrorInformationTLV = 1
+)
+
+// Route Mirroring Information codes per rfc7854
+const (
+	// RouteMirrorErroredPDU indicates that the mirrored PDU was found to be in error
+	RouteMirrorErroredPDU = 0
+	// RouteMirrorMessagesLost indicates that one or more messages were lost and not mirrored
+	RouteMirrorMessagesLost = 1
+)
+
+// RouteMirror defines BMP Route Mirroring message per rfc7854
+type RouteMirror struct {
+	TLV []InformationalTLV
+}
+
+// UnmarshalRouteMirrorMessage builds BMP Route Mirroring object, BGP Message TLVs reference the slice.
+func UnmarshalRouteMirrorMessage(b []byte) (*RouteMirror, error) {
+	if glog.V(6) {
+		glog.Infof("BMP Route Mirroring Message Raw: %s", tools.MessageHex(b))
+	}
+	rm := &RouteMirror{
+		TLV: make([]InformationalTLV, 0),
+	}
+	for i := 0; i < len(b); {
+		if i+4 > len(b) {
+			return nil, fmt.Errorf("not enough bytes to unmarshal route mirroring tlv")
+		}
+		t := int16(binary.BigEndian.Uint16(b[i : i+2]))
+		l := int16(binary.BigEndian.Uint16(b[i+2 : i+4]))
+		if l < 0 || int(l) > len(b)-(i+4) {
+			return nil, fmt.Errorf("invalid route mirroring tlv length %d", l)
+		}
+		switch t {
+		case RouteMirrorBGPMessageTLV:
+		case RouteMirrorInformationTLV:
+			if l != 2 {
+				return nil, fmt.Errorf("invalid route mirroring information tlv length %d, expected 2", l)
+			}
+		default:
+			return nil, fmt.Errorf("invalid route mirroring tlv type, expected between 0 and 1 found %d", t)
+		}
+		rm.TLV = append(rm.TLV, InformationalTLV{
+			InformationType:   t,
+			InformationLength: l,
+			Information:       b[i+4 : i+4+int(l)],
+		})
+		i += 4 + int(l)
+	}
+
+	return rm, nil
+}
+
+// BGPMessages returns BGP PDUs carried by BGP Message TLVs
+func (rm *RouteMirror) BGPMessages() [][]byte {
+	msgs := make([][]byte, 0)
+	for _, tlv := range rm.TLV {
+		if tlv.InformationType == RouteMirrorBGPMessageTLV {
+			msgs = append(msgs, tlv.Information)
+		}
+	}
+	return msgs
+}
+
+// InformationCodes returns codes carried by Information TLVs
+func (rm *RouteMirror) InformationCodes() []uint16 {
+	codes := make([]uint16, 0)
+	for _, tlv := range rm.TLV {
+		if tlv.InformationType == RouteMirrorInformationTLV {
+			codes = append(codes, binary.BigEndian.Uint16(tlv.Information))
+		}
+	}
+	return codes
+}
@@ -0,0 +1,65 @@
+package bmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouteMirrorMsg(t *testing.T) {
+	keepalive := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x13, 0x04}
+	tests := []struct {
+		name     string
+		input    []byte
+		fail     bool
+		messages [][]byte
+		codes    []uint16
+	}{
+		{
+			name:     "errored pdu",
+			input:    append([]byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x13}, keepalive...),
+			messages: [][]byte{keepalive},
+			codes:    []uint16{RouteMirrorErroredPDU},
+		},
+		{
+			name:     "messages lost",
+			input:    []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x01},
+			messages: [][]byte{},
+			codes:    []uint16{RouteMirrorMessagesLost},
+		},
+		{
+			name:  "invalid information length",
+			input: []byte{0x00, 0x01, 0x00, 0x01, 0x00},
+			fail:  true,
+		},
+		{
+			name:  "invalid tlv type",
+			input: []byte{0x00, 0x05, 0x00, 0x00},
+			fail:  true,
+		},
+		{
+			name:  "truncated bgp message",
+			input: []byte{0x00, 0x00, 0x00, 0x13, 0xFF},
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm, err := UnmarshalRouteMirrorMessage(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("failed but supposed to succeed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			if !reflect.DeepEqual(tt.messages, rm.BGPMessages()) {
+				t.Errorf("expected bgp messages %+v got %+v", tt.messages, rm.BGPMessages())
+			}
+			if !reflect.DeepEqual(tt.codes, rm.InformationCodes()) {
+				t.Errorf("expected information codes %+v got %+v", tt.codes, rm.InformationCodes())
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package bmp
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/golang/glog"
@@ -8,39 +9,282 @@ import (
 	"github.com/sbezverk/tools"
 )
 
+// Route Monitoring TLV types per draft-ietf-grow-bmp-tlv and draft-ietf-grow-bmp-path-marking-tlv
+const (
+	// RouteMonitorGroupTLV lists 2 bytes indexes of NLRIs forming the group identified by TLV's index
+	RouteMonitorGroupTLV = 1
+	// RouteMonitorStatelessParsingTLV carries AFI/SAFI and capabilities required to parse the BGP PDU
+	RouteMonitorStatelessParsingTLV = 2
+	// RouteMonitorPathMarkingTLV carries 4 bytes of path status and optional 2 bytes of reason code
+	RouteMonitorPathMarkingTLV = 3
+)
+
+const (
+	// routeMonitorTLVHeaderLength defines the length of Type, Length and Index fields of Route Monitoring TLV
+	routeMonitorTLVHeaderLength = 6
+	// routeMonitorTLVEnterprise is E bit of the type, enterprise TLVs carry 4 bytes of PEN before the value
+	routeMonitorTLVEnterprise = 0x8000
+	// RouteMonitorTLVGroupIndex is G bit of the index, when set the index refers to a group and not to NLRI
+	RouteMonitorTLVGroupIndex = 0x8000
+	// bgpMarkerLength defines the length of BGP message marker
+	bgpMarkerLength = 16
+)
+
+// Stateless Parsing TLV flags
+const (
+	StatelessParsingAddPath        = 0x80
+	StatelessParsingMultipleLabels = 0x40
+)
+
+// Path Marking TLV path status bits
+const (
+	PathStatusInvalid          = 0x00000001
+	PathStatusBest             = 0x00000002
+	PathStatusNonSelected      = 0x00000004
+	PathStatusPrimary          = 0x00000008
+	PathStatusBackup           = 0x00000010
+	PathStatusNonInstalled     = 0x00000020
+	PathStatusBestExternal     = 0x00000040
+	PathStatusAddPath          = 0x00000080
+	PathStatusFilteredInbound  = 0x00000100
+	PathStatusFilteredOutbound = 0x00000200
+	PathStatusStale            = 0x00000400
+	PathStatusSuppressed       = 0x00000800
+)
+
+var pathStatusNames = []struct {
+	bit  uint32
+	name string
+}{
+	{PathStatusInvalid, "invalid"},
+	{PathStatusBest, "best"},
+	{PathStatusNonSelected, "non-selected"},
+	{PathStatusPrimary, "primary"},
+	{PathStatusBackup, "backup"},
+	{PathStatusNonInstalled, "non-installed"},
+	{PathStatusBestExternal, "best-external"},
+	{PathStatusAddPath, "add-path"},
+	{PathStatusFilteredInbound, "filtered-inbound"},
+	{PathStatusFilteredOutbound, "filtered-outbound"},
+	{PathStatusStale, "stale"},
+	{PathStatusSuppressed, "suppressed"},
+}
+
+// RouteMonitorTLV defines BMP TLV carried by Route Monitoring message
+type RouteMonitorTLV struct {
+	Type       uint16
+	Enterprise bool
+	PEN        uint32
+	Index      uint16
+	Value      []byte
+}
+
+// PathMarking defines the value of Path Marking TLV
+type PathMarking struct {
+	Status    uint32
+	Reason    uint16
+	HasReason bool
+}
+
+// StatusStrings returns the names of status bits set in the path status
+func (pm *PathMarking) StatusStrings() []string {
+	s := make([]string, 0)
+	known := uint32(0)
+	for _, n := range pathStatusNames {
+		known |= n.bit
+		if pm.Status&n.bit != 0 {
+			s = append(s, n.name)
+		}
+	}
+	if u := pm.Status &^ known; u != 0 {
+		s = append(s, fmt.Sprintf("unknown-0x%08x", u))
+	}
+	if pm.Status == 0 {
+		s = append(s, "unknown")
+	}
+
+	return s
+}
+
+// StatelessParsing defines the value of Stateless Parsing TLV
+type StatelessParsing struct {
+	AFI            uint16
+	SAFI           uint8
+	AddPath        bool
+	MultipleLabels bool
+}
+
 // RouteMonitor defines a structure of BMP Route Monitoring message
 type RouteMonitor struct {
 	Update *bgp.Update
+	TLV    []RouteMonitorTLV
 }
 
-// UnmarshalBMPRouteMonitorMessage builds BMP Route Monitor object
+// UnmarshalBMPRouteMonitorMessage builds BMP Route Monitor object, BMP TLVs preceding or following
+// the BGP PDU are collected in TLV slice.
 func UnmarshalBMPRouteMonitorMessage(b []byte) (*RouteMonitor, error) {
 	if glog.V(6) {
 		glog.Infof("BMP Route Monitor Message Raw: %s length: %d", tools.MessageHex(b), len(b))
 	}
 	rm := RouteMonitor{}
+	pdu := false
+	for p := 0; p < len(b); {
+		if !pdu && isBGPMarker(b[p:]) {
+			l, err := rm.unmarshalPDU(b[p:])
+			if err != nil {
+				return nil, err
+			}
+			pdu = true
+			p += l
+			continue
+		}
+		tlv, l, err := unmarshalRouteMonitorTLV(b[p:])
+		if err != nil {
+			return nil, err
+		}
+		rm.TLV = append(rm.TLV, tlv)
+		p += l
+	}
+	if !pdu {
+		return nil, fmt.Errorf("malformed route monitor message")
+	}
+
+	return &rm, nil
+}
+
+func isBGPMarker(b []byte) bool {
+	if len(b) < bgpMarkerLength {
+		return false
+	}
+	for _, m := range b[:bgpMarkerLength] {
+		if m != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// unmarshalPDU processes BGP PDU found at the beginning of the slice and returns its length
+func (rm *RouteMonitor) unmarshalPDU(b []byte) (int, error) {
 	// 16 bytes marker + 2 bytes update length + 1 byte of type
 	if len(b) < 19 {
-		return nil, fmt.Errorf("malformed route monitor message")
+		return 0, fmt.Errorf("malformed route monitor message")
 	}
 	p := 0
 	// Skip 16 bytes of a marker
-	p += 16
-	// Skip 2 bytes of the update length
+	p += bgpMarkerLength
+	l := int(binary.BigEndian.Uint16(b[p : p+2]))
 	p += 2
+	if l < 19 || l > len(b) {
+		return 0, fmt.Errorf("invalid length %d of BGP message in route monitor message", l)
+	}
 	// Getting update type, currently only type 2 is processed
 	t := b[p]
 	p++
 	switch t {
 	case 2:
 		// Update type
-		u, err := bgp.UnmarshalBGPUpdate(b[p:])
+		u, err := bgp.UnmarshalBGPUpdate(b[p:l])
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
 		rm.Update = u
 	default:
 	}
 
-	return &rm, nil
+	return l, nil
+}
+
+func unmarshalRouteMonitorTLV(b []byte) (RouteMonitorTLV, int, error) {
+	tlv := RouteMonitorTLV{}
+	if len(b) < routeMonitorTLVHeaderLength {
+		return tlv, 0, fmt.Errorf("not enough bytes to unmarshal route monitor tlv")
+	}
+	t := binary.BigEndian.Uint16(b[0:2])
+	l := int(binary.BigEndian.Uint16(b[2:4]))
+	tlv.Index = binary.BigEndian.Uint16(b[4:6])
+	tlv.Type = t &^ routeMonitorTLVEnterprise
+	tlv.Enterprise = t&routeMonitorTLVEnterprise != 0
+	p := routeMonitorTLVHeaderLength
+	if p+l > len(b) {
+		return tlv, 0, fmt.Errorf("invalid route monitor tlv length %d", l)
+	}
+	v := b[p : p+l]
+	if tlv.Enterprise {
+		if len(v) < 4 {
+			return tlv, 0, fmt.Errorf("invalid route monitor enterprise tlv length %d", l)
+		}
+		tlv.PEN = binary.BigEndian.Uint32(v[0:4])
+		v = v[4:]
+	}
+	tlv.Value = v
+
+	return tlv, p + l, nil
+}
+
+// inGroup returns true if the group identified by the index of Group TLV lists the NLRI index
+func (rm *RouteMonitor) inGroup(group uint16, index int) bool {
+	for _, tlv := range rm.TLV {
+		if tlv.Enterprise || tlv.Type != RouteMonitorGroupTLV || tlv.Index != group {
+			continue
+		}
+		for p := 0; p+2 <= len(tlv.Value); p += 2 {
+			if int(binary.BigEndian.Uint16(tlv.Value[p:p+2])) == index {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PathMarking returns Path Marking of NLRI with the index, the index is the position of NLRI in the BGP Update
+// starting from 0, Path Marking TLV refers either to NLRI or to a group listing the NLRI. Nil is returned
+// if the message does not carry Path Marking for NLRI.
+func (rm *RouteMonitor) PathMarking(index int) *PathMarking {
+	for _, tlv := range rm.TLV {
+		if tlv.Enterprise || tlv.Type != RouteMonitorPathMarkingTLV {
+			continue
+		}
+		if tlv.Index&RouteMonitorTLVGroupIndex != 0 {
+			if !rm.inGroup(tlv.Index, index) {
+				continue
+			}
+		} else if int(tlv.Index) != index {
+			continue
+		}
+		if len(tlv.Value) != 4 && len(tlv.Value) != 6 {
+			glog.Warningf("invalid path marking tlv length %d", len(tlv.Value))
+			continue
+		}
+		pm := &PathMarking{
+			Status: binary.BigEndian.Uint32(tlv.Value[0:4]),
+		}
+		if len(tlv.Value) == 6 {
+			pm.Reason = binary.BigEndian.Uint16(tlv.Value[4:6])
+			pm.HasReason = true
+		}
+		return pm
+	}
+	return nil
+}
+
+// StatelessParsing returns the values of Stateless Parsing TLVs carried by the message
+func (rm *RouteMonitor) StatelessParsing() []StatelessParsing {
+	sp := make([]StatelessParsing, 0)
+	for _, tlv := range rm.TLV {
+		if tlv.Enterprise || tlv.Type != RouteMonitorStatelessParsingTLV {
+			continue
+		}
+		if len(tlv.Value) != 4 {
+			glog.Warningf("invalid stateless parsing tlv length %d", len(tlv.Value))
+			continue
+		}
+		sp = append(sp, StatelessParsing{
+			AFI:            binary.BigEndian.Uint16(tlv.Value[0:2]),
+			SAFI:           tlv.Value[2],
+			AddPath:        tlv.Value[3]&StatelessParsingAddPath != 0,
+			MultipleLabels: tlv.Value[3]&StatelessParsingMultipleLabels != 0,
+		})
+	}
+	return sp
 }
@@ -0,0 +1,135 @@
+package bmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testUpdatePDU is BGP Update with IPv4 NLRIs 10.0.0.0/8 and 10.1.0.0/16
+var testUpdatePDU = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0x00, 0x2a, 0x02,
+	0x00, 0x00, 0x00, 0x0e,
+	0x40, 0x01, 0x01, 0x00,
+	0x40, 0x02, 0x00,
+	0x40, 0x03, 0x04, 0xc0, 0x00, 0x02, 0x01,
+	0x08, 0x0a, 0x10, 0x0a, 0x01,
+}
+
+func TestUnmarshalBMPRouteMonitorMessage(t *testing.T) {
+	pathMarking := []byte{0x00, 0x03, 0x00, 0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x12, 0x00, 0x07}
+	groupMarking := []byte{0x00, 0x03, 0x00, 0x04, 0x80, 0x01, 0x00, 0x00, 0x00, 0x02}
+	group := []byte{0x00, 0x01, 0x00, 0x04, 0x80, 0x01, 0x00, 0x00, 0x00, 0x01}
+	stateless := []byte{0x00, 0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x01, 0x80}
+	enterprise := []byte{0x80, 0x03, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x09, 0x00, 0x02}
+	tests := []struct {
+		name      string
+		input     []byte
+		fail      bool
+		tlvs      int
+		marking   []*PathMarking
+		stateless []StatelessParsing
+	}{
+		{
+			name:    "pdu only",
+			input:   testUpdatePDU,
+			marking: []*PathMarking{nil, nil},
+		},
+		{
+			name:    "tlv following pdu",
+			input:   append(append([]byte{}, testUpdatePDU...), pathMarking...),
+			tlvs:    1,
+			marking: []*PathMarking{nil, {Status: PathStatusBest | PathStatusBackup, Reason: 7, HasReason: true}},
+		},
+		{
+			name:    "group tlvs preceding pdu",
+			input:   append(append(append([]byte{}, group...), groupMarking...), testUpdatePDU...),
+			tlvs:    2,
+			marking: []*PathMarking{{Status: PathStatusBest}, {Status: PathStatusBest}},
+		},
+		{
+			name:      "stateless parsing and enterprise tlv",
+			input:     append(append(append([]byte{}, stateless...), testUpdatePDU...), enterprise...),
+			tlvs:      2,
+			marking:   []*PathMarking{nil, nil},
+			stateless: []StatelessParsing{{AFI: 1, SAFI: 1, AddPath: true}},
+		},
+		{
+			name:  "truncated tlv",
+			input: append(append([]byte{}, testUpdatePDU...), pathMarking[:8]...),
+			fail:  true,
+		},
+		{
+			name:  "no pdu",
+			input: pathMarking,
+			fail:  true,
+		},
+		{
+			name:  "pdu length exceeds message",
+			input: testUpdatePDU[:30],
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm, err := UnmarshalBMPRouteMonitorMessage(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("supposed to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			if rm.Update == nil || !reflect.DeepEqual(rm.Update.NLRI, []byte{0x08, 0x0a, 0x10, 0x0a, 0x01}) {
+				t.Fatalf("bgp update was not decoded correctly: %+v", rm.Update)
+			}
+			if len(rm.TLV) != tt.tlvs {
+				t.Errorf("expected %d tlvs got %d", tt.tlvs, len(rm.TLV))
+			}
+			for i, expect := range tt.marking {
+				if pm := rm.PathMarking(i); !reflect.DeepEqual(pm, expect) {
+					t.Errorf("expected path marking of nlri %d %+v got %+v", i, expect, pm)
+				}
+			}
+			if sp := rm.StatelessParsing(); len(sp) != 0 || len(tt.stateless) != 0 {
+				if !reflect.DeepEqual(sp, tt.stateless) {
+					t.Errorf("expected stateless parsing %+v got %+v", tt.stateless, sp)
+				}
+			}
+		})
+	}
+}
+
+func TestPathMarkingStatusStrings(t *testing.T) {
+	tests := []struct {
+		name   string
+		status uint32
+		expect []string
+	}{
+		{
+			name:   "unknown",
+			status: 0,
+			expect: []string{"unknown"},
+		},
+		{
+			name:   "best and primary",
+			status: PathStatusBest | PathStatusPrimary,
+			expect: []string{"best", "primary"},
+		},
+		{
+			name:   "invalid and unassigned bit",
+			status: PathStatusInvalid | 0x00010000,
+			expect: []string{"invalid", "unknown-0x00010000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := &PathMarking{Status: tt.status}
+			if s := pm.StatusStrings(); !reflect.DeepEqual(s, tt.expect) {
+				t.Errorf("expected %v got %v", tt.expect, s)
+			}
+		})
+	}
+}
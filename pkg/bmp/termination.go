@@ -0,0 +1,95 @@
+package bmp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/tools"
+)
+
+// Termination Message Reason codes per rfc7854
+const (
+	// TerminationReasonAdminClose defines Session administratively closed reason
+	TerminationReasonAdminClose = 0
+	// TerminationReasonUnspecified defines Unspecified reason
+	TerminationReasonUnspecified = 1
+	// TerminationReasonOutOfResources defines Out of resources reason
+	TerminationReasonOutOfResources = 2
+	// TerminationReasonRedundantConnection defines Redundant connection reason
+	TerminationReasonRedundantConnection = 3
+	// TerminationReasonPermAdminClose defines Session permanently administratively closed reason
+	TerminationReasonPermAdminClose = 4
+)
+
+var terminationReasons = map[uint16]string{
+	TerminationReasonAdminClose:          "Session administratively closed",
+	TerminationReasonUnspecified:         "Unspecified reason",
+	TerminationReasonOutOfResources:      "Out of resources",
+	TerminationReasonRedundantConnection: "Redundant connection",
+	TerminationReasonPermAdminClose:      "Session permanently administratively closed",
+}
+
+// TerminationReasonString returns the description of Termination Message reason code
+func TerminationReasonString(reason uint16) string {
+	if s, ok := terminationReasons[reason]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown reason %d", reason)
+}
+
+// TerminationMessage defines BMP Termination Message per rfc7854
+type TerminationMessage struct {
+	TLV []InformationalTLV
+}
+
+// UnmarshalTerminationMessage processes Termination Message and returns TerminationMessage object
+func UnmarshalTerminationMessage(b []byte) (*TerminationMessage, error) {
+	if glog.V(6) {
+		glog.Infof("BMP Termination Message Raw: %s", tools.MessageHex(b))
+	}
+	tm := &TerminationMessage{
+		TLV: make([]InformationalTLV, 0),
+	}
+	for i := 0; i < len(b); {
+		if i+4 > len(b) {
+			return nil, fmt.Errorf("not enough bytes to unmarshal termination tlv")
+		}
+		// Extracting TLV type 2 bytes
+		t := int16(binary.BigEndian.Uint16(b[i : i+2]))
+		// Extracting TLV length
+		l := int16(binary.BigEndian.Uint16(b[i+2 : i+4]))
+		if l < 0 || int(l) > len(b)-(i+4) {
+			return nil, fmt.Errorf("invalid tlv length %d", l)
+		}
+		switch t {
+		case 0:
+		case 1:
+			if l != 2 {
+				return nil, fmt.Errorf("invalid reason tlv length %d, expected 2", l)
+			}
+		default:
+			return nil, fmt.Errorf("invalid tlv type, expected between 0 and 1 found %d", t)
+		}
+		v := b[i+4 : i+4+int(l)]
+		tm.TLV = append(tm.TLV, InformationalTLV{
+			InformationType:   t,
+			InformationLength: l,
+			Information:       v,
+		})
+		i += 4 + int(l)
+	}
+
+	return tm, nil
+}
+
+// Reason returns the reason code carried by Termination Message, ok is false when the message
+// does not carry the Reason TLV.
+func (tm *TerminationMessage) Reason() (reason uint16, ok bool) {
+	for _, tlv := range tm.TLV {
+		if tlv.InformationType == 1 && len(tlv.Information) == 2 {
+			return binary.BigEndian.Uint16(tlv.Information), true
+		}
+	}
+	return 0, false
+}
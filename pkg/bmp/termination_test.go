@@ -0,0 +1,71 @@
+package bmp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerminationMsg(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		fail   bool
+		expect *TerminationMessage
+		reason uint16
+		ok     bool
+	}{
+		{
+			name:  "string and reason",
+			input: []byte{0x00, 0x00, 0x00, 0x03, 'b', 'y', 'e', 0x00, 0x01, 0x00, 0x02, 0x00, 0x03},
+			expect: &TerminationMessage{
+				TLV: []InformationalTLV{
+					{InformationType: 0, InformationLength: 3, Information: []byte("bye")},
+					{InformationType: 1, InformationLength: 2, Information: []byte{0x00, 0x03}},
+				},
+			},
+			reason: TerminationReasonRedundantConnection,
+			ok:     true,
+		},
+		{
+			name:   "no tlvs",
+			input:  []byte{},
+			expect: &TerminationMessage{TLV: []InformationalTLV{}},
+		},
+		{
+			name:  "invalid reason length",
+			input: []byte{0x00, 0x01, 0x00, 0x01, 0x00},
+			fail:  true,
+		},
+		{
+			name:  "invalid tlv type",
+			input: []byte{0x00, 0x02, 0x00, 0x00},
+			fail:  true,
+		},
+		{
+			name:  "truncated tlv",
+			input: []byte{0x00, 0x00, 0x00, 0x05, 'a'},
+			fail:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := UnmarshalTerminationMessage(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("failed but supposed to succeed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+			if !reflect.DeepEqual(tt.expect, tm) {
+				t.Fatalf("expected %+v does not match unmarshaled %+v", tt.expect, tm)
+			}
+			reason, ok := tm.Reason()
+			if reason != tt.reason || ok != tt.ok {
+				t.Fatalf("expected reason %d %t got %d %t", tt.reason, tt.ok, reason, ok)
+			}
+		})
+	}
+}
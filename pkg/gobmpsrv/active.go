@@ -0,0 +1,118 @@
+package gobmpsrv
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultActiveBackoffMin defines the default initial delay between connection attempts to an active router
+	DefaultActiveBackoffMin = time.Second
+	// DefaultActiveBackoffMax defines the default maximum delay between connection attempts to an active router
+	DefaultActiveBackoffMax = time.Minute
+	// activeDialTimeout defines the timeout of a connection attempt to an active router
+	activeDialTimeout = 10 * time.Second
+)
+
+// ClientStatus describes a BMP session, either accepted from a router (passive) or initiated
+// by the collector to a configured router (active), per RFC 7854 section 3.2.
+type ClientStatus struct {
+	Address   string    `json:"address"`
+	Active    bool      `json:"active"`
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+	LastError string    `json:"last_error,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
+}
+
+// activeTarget keeps the state of a router the collector connects to.
+type activeTarget struct {
+	sync.Mutex
+	address string
+	// session is the remote address of the current BMP session with the router
+	session   string
+	connected bool
+	since     time.Time
+	lastError string
+	attempts  int
+}
+
+func (t *activeTarget) setConnected(session string) {
+	t.Lock()
+	defer t.Unlock()
+	t.session = session
+	t.connected = true
+	t.since = time.Now()
+	t.attempts = 0
+}
+
+func (t *activeTarget) setDisconnected(err error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.connected {
+		t.since = time.Now()
+	}
+	t.session = ""
+	t.connected = false
+	if err != nil {
+		t.lastError = err.Error()
+		t.attempts++
+	}
+}
+
+func (t *activeTarget) status() ClientStatus {
+	t.Lock()
+	defer t.Unlock()
+	return ClientStatus{
+		Address:   t.address,
+		Active:    true,
+		Connected: t.connected,
+		Since:     t.since,
+		LastError: t.lastError,
+		Attempts:  t.attempts,
+	}
+}
+
+// activeWorker keeps BMP session with the router established, the session is re-established
+// with exponential backoff when the connection attempt fails or the session goes down.
+func (srv *bmpServer) activeWorker(t *activeTarget) {
+	dialer := net.Dialer{Timeout: activeDialTimeout}
+	backoff := srv.backoffMin
+	for {
+		conn, err := dialer.Dial("tcp", t.address)
+		if err != nil {
+			glog.Errorf("fail to connect to router %s with error: %+v, will retry in %s", t.address, err, backoff)
+			t.setDisconnected(err)
+		} else {
+			glog.Infof("connected to router %s", t.address)
+			backoff = srv.backoffMin
+			t.setConnected(conn.RemoteAddr().String())
+			done := make(chan struct{})
+			go func() {
+				// Closing the connection on server stop to unblock bmpWorker
+				select {
+				case <-srv.stop:
+					_ = conn.Close()
+				case <-done:
+				}
+			}()
+			srv.bmpWorker(conn)
+			close(done)
+			t.setDisconnected(nil)
+			glog.Infof("BMP session with router %s is down, will reconnect in %s", t.address, backoff)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-srv.stop:
+			return
+		}
+		if err != nil {
+			if backoff *= 2; backoff > srv.backoffMax {
+				backoff = srv.backoffMax
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package gobmpsrv
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func TestActiveRouter(t *testing.T) {
+	router, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start router listener: %+v", err)
+	}
+	defer router.Close()
+	pub := &recorder{}
+	srv, err := NewBMPServer(0, 0, false, pub, true, false, &Config{
+		ActiveRouters:    []string{router.Addr().String()},
+		ActiveBackoffMin: 10 * time.Millisecond,
+		ActiveBackoffMax: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create BMP server: %+v", err)
+	}
+	srv.Start()
+	defer srv.Stop()
+
+	stream := routerStream(2, 10)
+	// The router closes the session after sending the stream, the collector is expected to reconnect
+	for i := 0; i < 2; i++ {
+		conn, err := router.Accept()
+		if err != nil {
+			t.Fatalf("failed to accept collector connection: %+v", err)
+		}
+		clients := srv.GetClients()
+		if len(clients) != 1 || !clients[0].Active || clients[0].Address != router.Addr().String() {
+			t.Errorf("unexpected clients %+v", clients)
+		}
+		if _, err := conn.Write(stream); err != nil {
+			t.Fatalf("failed to write BMP stream: %+v", err)
+		}
+		_ = conn.Close()
+	}
+	// 2 peers up, 20 routes and 2 peers down per session
+	expect := 2 * (2 + 20 + 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pub.Lock()
+		n := len(pub.msgs)
+		pub.Unlock()
+		if n >= expect {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d messages got %d", expect, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pub.Lock()
+	defer pub.Unlock()
+	peers := 0
+	for _, m := range pub.msgs {
+		if m.t == bmp.PeerStateChangeMsg {
+			peers++
+		}
+	}
+	if peers != 8 {
+		t.Errorf("expected 8 peer messages got %d", peers)
+	}
+}
@@ -0,0 +1,221 @@
+package gobmpsrv
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitAction defines what happens to a BMP session exceeding its message or byte rate limit
+type RateLimitAction int
+
+const (
+	// RateLimitBackpressure stops reading from the router until the rate falls under the limit,
+	// the router is slowed down by TCP flow control.
+	RateLimitBackpressure RateLimitAction = iota
+	// RateLimitDisconnect closes the BMP session of the router exceeding the limit.
+	RateLimitDisconnect
+)
+
+// ParseRateLimitAction converts a string "backpressure" or "disconnect" into RateLimitAction
+func ParseRateLimitAction(s string) (RateLimitAction, error) {
+	switch strings.ToLower(s) {
+	case "backpressure":
+		return RateLimitBackpressure, nil
+	case "disconnect":
+		return RateLimitDisconnect, nil
+	}
+
+	return 0, fmt.Errorf("invalid rate limit action %q, expected \"backpressure\" or \"disconnect\"", s)
+}
+
+// AdmissionStats reports the number of BMP sessions rejected or closed by admission control
+type AdmissionStats struct {
+	// Denied counts connections from addresses matching the deny list or not matching the allow list
+	Denied uint64 `json:"denied"`
+	// SessionLimit counts connections rejected because the maximum number of sessions was reached
+	SessionLimit uint64 `json:"session_limit"`
+	// RateLimited counts sessions disconnected for exceeding the message or byte rate limit
+	RateLimited uint64 `json:"rate_limited"`
+	// Throttled counts the times reading from a session was paused for exceeding the rate limit
+	Throttled uint64 `json:"throttled"`
+}
+
+// admission keeps the state of admission control of passive BMP sessions
+type admission struct {
+	allow       []*net.IPNet
+	deny        []*net.IPNet
+	maxSessions int
+	sync.Mutex
+	sessions     int
+	denied       atomic.Uint64
+	sessionLimit atomic.Uint64
+	rateLimited  atomic.Uint64
+	throttled    atomic.Uint64
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+func newAdmission(allow, deny []string, maxSessions int) (*admission, error) {
+	a := &admission{maxSessions: maxSessions}
+	var err error
+	if a.allow, err = parseCIDRs(allow); err != nil {
+		return nil, err
+	}
+	if a.deny, err = parseCIDRs(deny); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// admit checks if a session from addr is allowed, the deny list takes precedence over the allow list,
+// an empty allow list allows all addresses. When the session is admitted, release must be called
+// when the session is closed.
+func (a *admission) admit(addr net.Addr) error {
+	if err := a.allowed(addr); err != nil {
+		return err
+	}
+	return a.acquire()
+}
+
+// allowed checks addr against the allow and deny lists
+func (a *admission) allowed(addr net.Addr) error {
+	var ip net.IP
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		ip = tcp.IP
+	} else if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		ip = net.ParseIP(host)
+	}
+	if len(a.allow) != 0 || len(a.deny) != 0 {
+		if ip == nil || contains(a.deny, ip) || (len(a.allow) != 0 && !contains(a.allow, ip)) {
+			a.denied.Add(1)
+			return fmt.Errorf("address %s is not allowed", addr.String())
+		}
+	}
+
+	return nil
+}
+
+// acquire counts a new session unless the maximum number of sessions is reached, release must be called
+// when the session is closed.
+func (a *admission) acquire() error {
+	a.Lock()
+	defer a.Unlock()
+	if a.maxSessions > 0 && a.sessions >= a.maxSessions {
+		a.sessionLimit.Add(1)
+		return fmt.Errorf("maximum number of sessions %d is reached", a.maxSessions)
+	}
+	a.sessions++
+
+	return nil
+}
+
+func (a *admission) release() {
+	a.Lock()
+	defer a.Unlock()
+	a.sessions--
+}
+
+func (a *admission) stats() AdmissionStats {
+	return AdmissionStats{
+		Denied:       a.denied.Load(),
+		SessionLimit: a.sessionLimit.Load(),
+		RateLimited:  a.rateLimited.Load(),
+		Throttled:    a.throttled.Load(),
+	}
+}
+
+// tokenBucket limits the rate of events to rate per second allowing bursts of up to burst events
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	// Bursts of up to one second worth of events are allowed
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   now,
+	}
+}
+
+// take removes n tokens from the bucket and returns the time to wait until the bucket is not
+// in debt, a nil bucket never limits.
+func (b *tokenBucket) take(n int, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter limits the message and the byte rate of a single BMP session
+type rateLimiter struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+func newRateLimiter(messageRate, byteRate int) *rateLimiter {
+	if messageRate <= 0 && byteRate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &rateLimiter{
+		messages: newTokenBucket(messageRate, now),
+		bytes:    newTokenBucket(byteRate, now),
+	}
+}
+
+// limit accounts a message of length l and returns the time to wait before reading the next message
+func (r *rateLimiter) limit(l int) time.Duration {
+	if r == nil {
+		return 0
+	}
+	now := time.Now()
+	wait := r.messages.take(1, now)
+	if w := r.bytes.take(l, now); w > wait {
+		wait = w
+	}
+
+	return wait
+}
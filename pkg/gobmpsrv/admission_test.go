@@ -0,0 +1,167 @@
+package gobmpsrv
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAdmit(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		maxSessions int
+		clients     []string
+		admitted    []bool
+		stats       AdmissionStats
+	}{
+		{
+			name:     "no restrictions",
+			clients:  []string{"192.0.2.1", "2001:db8::1"},
+			admitted: []bool{true, true},
+		},
+		{
+			name:     "allow list",
+			allow:    []string{"192.0.2.0/24", "2001:db8::/32"},
+			clients:  []string{"192.0.2.1", "198.51.100.1", "2001:db8::1"},
+			admitted: []bool{true, false, true},
+			stats:    AdmissionStats{Denied: 1},
+		},
+		{
+			name:     "deny list takes precedence",
+			allow:    []string{"192.0.2.0/24"},
+			deny:     []string{"192.0.2.128/25"},
+			clients:  []string{"192.0.2.1", "192.0.2.200"},
+			admitted: []bool{true, false},
+			stats:    AdmissionStats{Denied: 1},
+		},
+		{
+			name:     "deny list only",
+			deny:     []string{"198.51.100.0/24"},
+			clients:  []string{"192.0.2.1", "198.51.100.1"},
+			admitted: []bool{true, false},
+			stats:    AdmissionStats{Denied: 1},
+		},
+		{
+			name:        "maximum sessions",
+			maxSessions: 2,
+			clients:     []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+			admitted:    []bool{true, true, false},
+			stats:       AdmissionStats{SessionLimit: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAdmission(tt.allow, tt.deny, tt.maxSessions)
+			if err != nil {
+				t.Fatalf("failed to create admission: %+v", err)
+			}
+			for i, c := range tt.clients {
+				err := a.admit(&net.TCPAddr{IP: net.ParseIP(c), Port: 50000})
+				if (err == nil) != tt.admitted[i] {
+					t.Errorf("client %s expected admitted %t, got error: %+v", c, tt.admitted[i], err)
+				}
+			}
+			if s := a.stats(); s != tt.stats {
+				t.Errorf("expected stats %+v got %+v", tt.stats, s)
+			}
+		})
+	}
+}
+
+func TestAdmitReleasedSession(t *testing.T) {
+	a, _ := newAdmission(nil, nil, 1)
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}
+	if err := a.admit(addr); err != nil {
+		t.Fatalf("expected first session to be admitted: %+v", err)
+	}
+	if err := a.admit(addr); err == nil {
+		t.Fatalf("expected second session to be rejected")
+	}
+	a.release()
+	if err := a.admit(addr); err != nil {
+		t.Fatalf("expected session to be admitted after release: %+v", err)
+	}
+}
+
+func TestInvalidCIDR(t *testing.T) {
+	if _, err := NewBMPServer(0, 0, false, &recorder{}, true, false, &Config{AllowedClients: []string{"192.0.2.1"}}); err == nil {
+		t.Fatalf("expected invalid CIDR to fail")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10, now)
+	for i := 0; i < 10; i++ {
+		if w := b.take(1, now); w != 0 {
+			t.Fatalf("expected burst of 10 events, event %d waits %s", i, w)
+		}
+	}
+	if w := b.take(1, now); w != 100*time.Millisecond {
+		t.Errorf("expected to wait 100ms got %s", w)
+	}
+	// After a second the bucket is refilled, the debt of a single event is paid
+	if w := b.take(1, now.Add(time.Second)); w != 0 {
+		t.Errorf("expected no wait got %s", w)
+	}
+	var unlimited *tokenBucket
+	if w := unlimited.take(1000, now); w != 0 {
+		t.Errorf("expected nil bucket not to limit, got %s", w)
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	// 1 router, 1 peer up, 48 routes and 1 peer down messages
+	stream := routerStream(1, 48)
+	tests := []struct {
+		name   string
+		config *Config
+		all    bool
+		stats  AdmissionStats
+	}{
+		{
+			name:   "no limit",
+			config: &Config{},
+			all:    true,
+		},
+		{
+			name:   "backpressure",
+			config: &Config{MessageRate: 40},
+			all:    true,
+		},
+		{
+			name:   "disconnect on message rate",
+			config: &Config{MessageRate: 10, RateLimitAction: RateLimitDisconnect},
+			stats:  AdmissionStats{RateLimited: 1},
+		},
+		{
+			name:   "disconnect on byte rate",
+			config: &Config{ByteRate: len(stream) / 2, RateLimitAction: RateLimitDisconnect},
+			stats:  AdmissionStats{RateLimited: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recorder{}
+			srv := newTestServer(pub, tt.config)
+			_ = runSession(srv, 1, stream)
+			n := len(pub.msgs)
+			if tt.all && n != 51 {
+				t.Errorf("expected all 51 messages to be published, got %d", n)
+			}
+			if !tt.all && n >= 51 {
+				t.Errorf("expected session to be disconnected, got %d messages", n)
+			}
+			s := srv.GetAdmissionStats()
+			if tt.config.MessageRate != 0 && tt.all && s.Throttled == 0 {
+				t.Errorf("expected session to be throttled")
+			}
+			s.Throttled = 0
+			if s != tt.stats {
+				t.Errorf("expected stats %+v got %+v", tt.stats, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package gobmpsrv
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// ShardBy defines how BMP messages are distributed between the workers of the pool
+type ShardBy int
+
+const (
+	// ShardByPeer sends all messages of a peer to the same worker, messages of different
+	// peers of the same router can be processed in parallel.
+	ShardByPeer ShardBy = iota
+	// ShardByRouter sends all messages of a router to the same worker.
+	ShardByRouter
+)
+
+const (
+	// DefaultQueueSize defines the default length of worker and producer queues
+	DefaultQueueSize = 1024
+	// DefaultKeepAlive defines the default period of TCP keepalive probes on BMP sessions
+	DefaultKeepAlive = 30 * time.Second
+)
+
+// ParseShardBy converts a string "peer" or "router" into ShardBy
+func ParseShardBy(s string) (ShardBy, error) {
+	switch strings.ToLower(s) {
+	case "peer":
+		return ShardByPeer, nil
+	case "router":
+		return ShardByRouter, nil
+	}
+
+	return 0, fmt.Errorf("invalid shard-by value %q, expected \"peer\" or \"router\"", s)
+}
+
+// Config defines optional parameters of BMP Server
+type Config struct {
+	// Workers defines the number of workers parsing BMP messages, when 0 GOMAXPROCS is used
+	Workers int
+	// QueueSize defines the length of each worker's queue, of each BMP session's producer queue and the number
+	// of messages of a BMP session being parsed or waiting for the producer, when 0 DefaultQueueSize is used
+	QueueSize int
+	// ShardBy defines how messages are distributed between workers
+	ShardBy ShardBy
+	// TableTopics when true publishes route messages of Loc-RIB instances to topics of their tables,
+	// named after the message's topic and the VRF/Table name, when supported by the publisher.
+	TableTopics bool
+	// TypedCommunities when true publishes decoded communities, extended communities and large communities
+	// in base attributes of route messages, in addition to their string forms.
+	TypedCommunities bool
+	// RawTopic when true publishes every BMP message received from routers prepended with OpenBMP binary header
+	// as message type bmp.BMPRawMsg, keyed by the router hash.
+	RawTopic bool
+	// CollectorAdminID defines the Collector Admin ID of OpenBMP binary header of raw messages, when empty
+	// the host name is used.
+	CollectorAdminID string
+	// RouterNames defines names of routers keyed by router IP address, the name of the router is published
+	// instead of sysName advertised in BMP Initiation message.
+	RouterNames map[string]string
+	// ActiveRouters defines a list of routers in host:port format the collector connects to,
+	// for routers supporting only passive BMP mode.
+	ActiveRouters []string
+	// ActiveBackoffMin and ActiveBackoffMax define the range of delays between connection attempts
+	// to active routers and to intercept destinations, when 0 DefaultActiveBackoffMin and DefaultActiveBackoffMax are used.
+	ActiveBackoffMin time.Duration
+	ActiveBackoffMax time.Duration
+	// TLS when not nil enables TLS on the BMP listener, when TLS.ClientAuth requires verified client
+	// certificates, the subject of the router's certificate is used as the router identity.
+	TLS *tls.Config
+	// InterceptTLS when not nil enables TLS on connections to intercept destinations.
+	InterceptTLS *tls.Config
+	// InterceptDestinations defines a list of BMP stations in host:port format receiving copies of messages
+	// in intercept mode, when empty the destination port on the local host is used.
+	InterceptDestinations []string
+	// InterceptBufferSize defines the maximum number of messages buffered per intercept destination while
+	// the destination is slow or disconnected, when 0 DefaultInterceptBufferSize is used.
+	InterceptBufferSize int
+	// ProxyFilter when not nil selects messages re-emitted to intercept destinations, messages published
+	// by the collector are not filtered.
+	ProxyFilter *Filter
+	// RelayTo when not empty defines the address of the core collector in host:port format, BMP sessions
+	// are relayed to the core collector instead of being parsed and published.
+	RelayTo string
+	// RelayTLS when not nil enables TLS on the connection to the core collector.
+	RelayTLS *tls.Config
+	// RelayBufferSize defines the maximum number of messages buffered while the core collector is slow
+	// or not reachable, when 0 DefaultRelayBufferSize is used.
+	RelayBufferSize int
+	// RelayPort when not 0 defines the port the core collector accepts connections from edge collectors on.
+	// Messages of each relayed session are queued up to QueueSize messages, a session which is not keeping up
+	// drops messages and is restarted.
+	RelayPort int
+	// RelayListenerTLS defines TLS of the relay listener, it is required when RelayPort is set and must
+	// require verified client certificates, see NewServerTLSConfig, as edge collectors publish messages
+	// on behalf of any router.
+	RelayListenerTLS *tls.Config
+	// AllowedClients and DeniedClients define lists of CIDRs routers are allowed or denied to open
+	// BMP sessions from, the deny list takes precedence, an empty allow list allows all routers.
+	// The lists apply to addresses of edge collectors opening relay connections and relayed sessions count
+	// towards MaxSessions, rate limits of relayed sessions are applied by the edge collector.
+	AllowedClients []string
+	DeniedClients  []string
+	// MaxSessions defines the maximum number of concurrent BMP sessions accepted from routers,
+	// when 0 the number is not limited.
+	MaxSessions int
+	// MessageRate and ByteRate define the maximum rate of messages and bytes per second received
+	// from a single router, when 0 the rate is not limited.
+	MessageRate int
+	ByteRate    int
+	// RateLimitAction defines what happens to a router exceeding MessageRate or ByteRate
+	RateLimitAction RateLimitAction
+	// KeepAlive defines the period of TCP keepalive probes on BMP sessions, when 0 DefaultKeepAlive is used,
+	// when negative keepalive settings of the connection are not changed.
+	KeepAlive time.Duration
+	// IdleTimeout defines the time after which a BMP session without any message received is closed,
+	// when 0 the session never times out.
+	IdleTimeout time.Duration
+}
+
+func (c *Config) workers() int {
+	if c == nil || c.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return c.Workers
+}
+
+func (c *Config) queueSize() int {
+	if c == nil || c.QueueSize <= 0 {
+		return DefaultQueueSize
+	}
+	return c.QueueSize
+}
+
+func (c *Config) shardBy() ShardBy {
+	if c == nil {
+		return ShardByPeer
+	}
+	return c.ShardBy
+}
+
+func (c *Config) activeRouters() []string {
+	if c == nil {
+		return nil
+	}
+	return c.ActiveRouters
+}
+
+func (c *Config) activeBackoff() (time.Duration, time.Duration) {
+	min, max := DefaultActiveBackoffMin, DefaultActiveBackoffMax
+	if c != nil && c.ActiveBackoffMin > 0 {
+		min = c.ActiveBackoffMin
+	}
+	if c != nil && c.ActiveBackoffMax > 0 {
+		max = c.ActiveBackoffMax
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+func (c *Config) tls() *tls.Config {
+	if c == nil {
+		return nil
+	}
+	return c.TLS
+}
+
+func (c *Config) interceptTLS() *tls.Config {
+	if c == nil {
+		return nil
+	}
+	return c.InterceptTLS
+}
+
+func (c *Config) admission() (*admission, error) {
+	if c == nil {
+		return newAdmission(nil, nil, 0)
+	}
+	return newAdmission(c.AllowedClients, c.DeniedClients, c.MaxSessions)
+}
+
+func (c *Config) rateLimits() (int, int, RateLimitAction) {
+	if c == nil {
+		return 0, 0, RateLimitBackpressure
+	}
+	return c.MessageRate, c.ByteRate, c.RateLimitAction
+}
+
+func (c *Config) timeouts() (time.Duration, time.Duration) {
+	if c == nil {
+		return DefaultKeepAlive, 0
+	}
+	keepAlive := c.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	return keepAlive, c.IdleTimeout
+}
+
+func (c *Config) intercept(dPort int) ([]string, int) {
+	destinations := []string{fmt.Sprintf(":%d", dPort)}
+	size := DefaultInterceptBufferSize
+	if c == nil {
+		return destinations, size
+	}
+	if len(c.InterceptDestinations) != 0 {
+		destinations = c.InterceptDestinations
+	}
+	if c.InterceptBufferSize > 0 {
+		size = c.InterceptBufferSize
+	}
+	return destinations, size
+}
+
+func (c *Config) proxyFilter() *Filter {
+	if c == nil {
+		return nil
+	}
+	return c.ProxyFilter
+}
+
+func (c *Config) tableTopics() bool {
+	if c == nil {
+		return false
+	}
+	return c.TableTopics
+}
+
+func (c *Config) typedCommunities() bool {
+	if c == nil {
+		return false
+	}
+	return c.TypedCommunities
+}
+
+func (c *Config) routerNames() map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.RouterNames
+}
+
+func (c *Config) raw() (bool, string) {
+	if c == nil || !c.RawTopic {
+		return false, ""
+	}
+	if c.CollectorAdminID != "" {
+		return true, c.CollectorAdminID
+	}
+	id, err := os.Hostname()
+	if err != nil {
+		glog.Warningf("failed to get host name for collector admin id with error: %+v", err)
+		id = "gobmp"
+	}
+	return true, id
+}
+
+func (c *Config) relay() (string, *tls.Config, int, int) {
+	if c == nil {
+		return "", nil, DefaultRelayBufferSize, 0
+	}
+	size := c.RelayBufferSize
+	if size <= 0 {
+		size = DefaultRelayBufferSize
+	}
+	return c.RelayTo, c.RelayTLS, size, c.RelayPort
+}
+
+func (c *Config) relayListenerTLS() *tls.Config {
+	if c == nil {
+		return nil
+	}
+	return c.RelayListenerTLS
+}
@@ -0,0 +1,191 @@
+package gobmpsrv
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/parser"
+)
+
+const (
+	// peerKeyOffset defines the offset of Peer Distinguisher in a BMP message carrying Per-Peer header
+	peerKeyOffset = bmp.CommonHeaderLength + 2
+	// peerKeyLength defines the length of Peer Distinguisher and Peer Address fields of Per-Peer header
+	peerKeyLength = 8 + 16
+)
+
+// task is a unit of work processed by a worker of the pool
+type task struct {
+	msg  *messageBuffer
+	disp *dispatcher
+}
+
+// workerPool is a set of workers shared by all BMP sessions, each worker has a bounded queue
+// and processes it sequentially. Workers live as long as the BMP server.
+type workerPool struct {
+	queues  []chan task
+	shardBy ShardBy
+}
+
+func newWorkerPool(workers, queueSize int, shardBy ShardBy) *workerPool {
+	wp := &workerPool{
+		queues:  make([]chan task, workers),
+		shardBy: shardBy,
+	}
+	for i := range wp.queues {
+		wp.queues[i] = make(chan task, queueSize)
+		go wp.worker(wp.queues[i])
+	}
+
+	return wp
+}
+
+func (wp *workerPool) worker(queue chan task) {
+	for t := range queue {
+		// Decoded messages are handed to the session's outbox which never blocks, a slow session
+		// does not hold up other sessions served by the worker.
+		parser.ParseFunc(t.msg.b, t.disp.emitter(t.msg.ts), t.msg.release)
+		t.disp.done()
+	}
+}
+
+// dispatcher distributes raw BMP messages received over a single BMP session between the workers
+// of the pool. All messages of a peer (or of a router when sharding by router) are sent to the same
+// worker and each worker processes its queue sequentially, as a result the order of messages of any
+// given peer is preserved, while messages of different peers are parsed in parallel. Messages which
+// do not carry Per-Peer header (Initiation, Termination) act as barriers, they are processed only
+// after all previously received messages are handed to the producer.
+//
+// Workers pass decoded messages to the session's outbox, the session's forwarder moves them to the
+// producer queue. The number of messages dispatched and not yet taken by the forwarder is limited,
+// when the limit is reached, dispatch blocks the session's reader and slows down only that router.
+type dispatcher struct {
+	pool          *workerPool
+	router        []byte
+	producerQueue chan bmp.Message
+	inflight      sync.WaitGroup
+	limit         int
+	mu            sync.Mutex
+	cond          *sync.Cond
+	// pending is the number of dispatched messages being parsed by workers
+	pending int
+	outbox  []bmp.Message
+	closed  bool
+	stopped chan struct{}
+}
+
+func newDispatcher(pool *workerPool, router string, producerQueue chan bmp.Message, limit int) *dispatcher {
+	d := &dispatcher{
+		pool:          pool,
+		router:        []byte(router),
+		producerQueue: producerQueue,
+		limit:         limit,
+		stopped:       make(chan struct{}),
+	}
+	if d.limit <= 0 {
+		d.limit = DefaultQueueSize
+	}
+	d.cond = sync.NewCond(&d.mu)
+	go d.forward()
+
+	return d
+}
+
+// dispatch sends BMP message to the worker serving message's peer or router, the ownership
+// of the message buffer is passed along with the message.
+func (d *dispatcher) dispatch(mb *messageBuffer) {
+	key, ok := peerKey(mb.b)
+	if !ok {
+		// The message is not associated with any peer, waiting for all previously received
+		// messages to reach the outbox before passing it along.
+		d.inflight.Wait()
+		parser.ParseFunc(mb.b, d.emitter(mb.ts), mb.release)
+		return
+	}
+	d.mu.Lock()
+	for d.pending+len(d.outbox) >= d.limit {
+		d.cond.Wait()
+	}
+	d.pending++
+	d.mu.Unlock()
+	h := fnv.New32a()
+	_, _ = h.Write(d.router)
+	if d.pool.shardBy == ShardByPeer {
+		_, _ = h.Write(key)
+	}
+	d.inflight.Add(1)
+	d.pool.queues[h.Sum32()%uint32(len(d.pool.queues))] <- task{msg: mb, disp: d}
+}
+
+// push appends a decoded message to the outbox, it never blocks
+func (d *dispatcher) push(m bmp.Message) {
+	d.mu.Lock()
+	d.outbox = append(d.outbox, m)
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// emitter returns the function pushing decoded messages of the BMP message received at ts
+func (d *dispatcher) emitter(ts time.Time) func(bmp.Message) {
+	return func(m bmp.Message) {
+		m.Received = ts
+		d.push(m)
+	}
+}
+
+// done is called by the worker when a dispatched message is parsed
+func (d *dispatcher) done() {
+	d.mu.Lock()
+	d.pending--
+	d.mu.Unlock()
+	d.cond.Broadcast()
+	d.inflight.Done()
+}
+
+// forward moves messages from the outbox to the producer queue until the dispatcher is drained
+func (d *dispatcher) forward() {
+	defer close(d.stopped)
+	for {
+		d.mu.Lock()
+		for len(d.outbox) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if len(d.outbox) == 0 {
+			d.mu.Unlock()
+			return
+		}
+		m := d.outbox[0]
+		d.outbox[0] = bmp.Message{}
+		d.outbox = d.outbox[1:]
+		d.mu.Unlock()
+		d.cond.Broadcast()
+		d.producerQueue <- m
+	}
+}
+
+// drain waits until all dispatched messages are handed to the producer and stops the forwarder,
+// no message may be dispatched after drain is called.
+func (d *dispatcher) drain() {
+	d.inflight.Wait()
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.cond.Broadcast()
+	<-d.stopped
+}
+
+// peerKey returns Peer Distinguisher and Peer Address of a BMP message carrying Per-Peer header,
+// if the message type does not carry Per-Peer header, false is returned.
+func peerKey(b []byte) ([]byte, bool) {
+	if len(b) < bmp.CommonHeaderLength+bmp.PerPeerHeaderLength {
+		return nil, false
+	}
+	switch b[5] {
+	case bmp.RouteMonitorMsg, bmp.StatsReportMsg, bmp.PeerDownMsg, bmp.PeerUpMsg, bmp.RouteMirrorMsg:
+		return b[peerKeyOffset : peerKeyOffset+peerKeyLength], true
+	}
+
+	return nil, false
+}
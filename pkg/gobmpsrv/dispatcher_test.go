@@ -0,0 +1,67 @@
+package gobmpsrv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func messageBufferOf(b []byte) *messageBuffer {
+	mb := getMessageBuffer(len(b))
+	copy(mb.b, b)
+	return mb
+}
+
+// TestDispatcherSlowSession checks that a session whose producer does not keep up does not
+// hold up other sessions served by the same worker.
+func TestDispatcherSlowSession(t *testing.T) {
+	pool := newWorkerPool(1, 4, ShardByRouter)
+	slowQueue := make(chan bmp.Message)
+	slow := newDispatcher(pool, "192.0.2.1:50000", slowQueue, 8)
+	fastQueue := make(chan bmp.Message, 1)
+	fast := newDispatcher(pool, "192.0.2.2:50000", fastQueue, 8)
+
+	slowDone := make(chan struct{})
+	go func() {
+		// The reader of the slow session is blocked once its limit is reached
+		for i := 0; i < 64; i++ {
+			slow.dispatch(messageBufferOf(routeMonitorMessage(1, i)))
+		}
+		close(slowDone)
+	}()
+	// Letting the slow session fill its outbox and the worker's queue
+	time.Sleep(100 * time.Millisecond)
+	go fast.dispatch(messageBufferOf(routeMonitorMessage(2, 0)))
+	select {
+	case m := <-fastQueue:
+		if _, ok := m.Payload.(*bmp.RouteMonitor); !ok {
+			t.Fatalf("expected Route Monitor message got %T", m.Payload)
+		}
+		if m.Done != nil {
+			m.Done()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message of the fast session is held up by the slow session")
+	}
+	fast.drain()
+
+	// All messages of the slow session are delivered in order once its producer catches up
+	received := make(chan int)
+	go func() {
+		n := 0
+		for m := range slowQueue {
+			n++
+			if m.Done != nil {
+				m.Done()
+			}
+		}
+		received <- n
+	}()
+	<-slowDone
+	slow.drain()
+	close(slowQueue)
+	if n := <-received; n != 64 {
+		t.Fatalf("expected 64 messages of the slow session got %d", n)
+	}
+}
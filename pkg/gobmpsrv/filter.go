@@ -0,0 +1,311 @@
+package gobmpsrv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// Offsets of Per Peer Header fields from the start of a BMP message
+const (
+	peerTypeOffset  = bmp.CommonHeaderLength
+	peerFlagsOffset = bmp.CommonHeaderLength + 1
+	peerAddrOffset  = bmp.CommonHeaderLength + 10
+	peerASOffset    = bmp.CommonHeaderLength + 26
+	// bgpMessageOffset is the offset of BGP message carried by Route Monitoring and Peer Up messages
+	bgpMessageOffset = bmp.CommonHeaderLength + bmp.PerPeerHeaderLength
+)
+
+// AFISAFI defines a pair of Address Family Identifier and Subsequent Address Family Identifier
+type AFISAFI struct {
+	AFI  uint16
+	SAFI uint8
+}
+
+// FilterRule matches BMP messages, a message matches the rule when it matches all non empty criteria,
+// a criterion matches when the message matches any of its values.
+type FilterRule struct {
+	// Messages defines BMP message types
+	Messages []uint8
+	// Peers defines prefixes of peer addresses
+	Peers []*net.IPNet
+	// PeerASNs defines peer AS numbers
+	PeerASNs []uint32
+	// PeerTypes defines peer types, 0 global, 1 RD instance, 2 local instance, 3 Loc-RIB instance
+	PeerTypes []uint8
+	// AFISAFIs defines AFI/SAFI of BGP Update carried by Route Monitoring messages, other messages
+	// match the criterion of Keep rules and do not match the criterion of Drop rules, so that Peer Up,
+	// Peer Down and Stats Report messages of kept peers are kept.
+	AFISAFIs []AFISAFI
+}
+
+// Filter selects BMP messages re-emitted to intercept destinations in proxy mode. Initiation and
+// Termination messages are always kept. Other messages are kept when they match any of Keep rules,
+// or when there are no Keep rules, and do not match any of Drop rules.
+type Filter struct {
+	Keep []FilterRule
+	Drop []FilterRule
+}
+
+var messageNames = map[string]uint8{
+	"route-monitoring": bmp.RouteMonitorMsg,
+	"stats":            bmp.StatsReportMsg,
+	"peer-down":        bmp.PeerDownMsg,
+	"peer-up":          bmp.PeerUpMsg,
+	"route-mirroring":  bmp.RouteMirrorMsg,
+}
+
+var peerTypeNames = map[string]uint8{
+	"global":  0,
+	"rd":      1,
+	"local":   2,
+	"loc-rib": 3,
+}
+
+// ParseFilter parses the filter specification, a list of rules separated by ";". A rule starts with
+// "keep" or "drop" followed by space separated criteria in key=value[,value] format, supported keys:
+//
+//	msg      route-monitoring, stats, peer-down, peer-up or route-mirroring
+//	peer     peer address or prefix
+//	as       peer AS number
+//	type     peer type number, or global, rd, local or loc-rib
+//	afi-safi AFI/SAFI numbers, for example 1/1 or 2/128
+//
+// For example "keep peer=192.0.2.0/24 afi-safi=1/1; drop msg=stats,route-mirroring".
+func ParseFilter(spec string) (*Filter, error) {
+	f := &Filter{}
+	for _, r := range strings.Split(spec, ";") {
+		fields := strings.Fields(r)
+		if len(fields) == 0 {
+			continue
+		}
+		rule, err := parseFilterRule(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter rule %q: %w", strings.TrimSpace(r), err)
+		}
+		switch strings.ToLower(fields[0]) {
+		case "keep":
+			f.Keep = append(f.Keep, rule)
+		case "drop":
+			f.Drop = append(f.Drop, rule)
+		default:
+			return nil, fmt.Errorf("invalid filter rule %q: expected \"keep\" or \"drop\"", strings.TrimSpace(r))
+		}
+	}
+
+	return f, nil
+}
+
+func parseFilterRule(criteria []string) (FilterRule, error) {
+	rule := FilterRule{}
+	if len(criteria) == 0 {
+		return rule, fmt.Errorf("no criteria")
+	}
+	for _, c := range criteria {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return rule, fmt.Errorf("invalid criterion %q, expected key=value", c)
+		}
+		for _, v := range strings.Split(kv[1], ",") {
+			if err := rule.add(strings.ToLower(kv[0]), strings.ToLower(v)); err != nil {
+				return rule, err
+			}
+		}
+	}
+
+	return rule, nil
+}
+
+func (r *FilterRule) add(key, value string) error {
+	switch key {
+	case "msg":
+		t, ok := messageNames[value]
+		if !ok {
+			return fmt.Errorf("invalid message type %q", value)
+		}
+		r.Messages = append(r.Messages, t)
+	case "peer":
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(value)
+		if err != nil {
+			return fmt.Errorf("invalid peer %q", value)
+		}
+		r.Peers = append(r.Peers, n)
+	case "as":
+		as, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid AS %q", value)
+		}
+		r.PeerASNs = append(r.PeerASNs, uint32(as))
+	case "type":
+		t, ok := peerTypeNames[value]
+		if !ok {
+			n, err := strconv.ParseUint(value, 10, 8)
+			if err != nil {
+				return fmt.Errorf("invalid peer type %q", value)
+			}
+			t = uint8(n)
+		}
+		r.PeerTypes = append(r.PeerTypes, t)
+	case "afi-safi":
+		p := strings.SplitN(value, "/", 2)
+		if len(p) != 2 {
+			return fmt.Errorf("invalid AFI/SAFI %q", value)
+		}
+		afi, err := strconv.ParseUint(p[0], 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid AFI/SAFI %q", value)
+		}
+		safi, err := strconv.ParseUint(p[1], 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid AFI/SAFI %q", value)
+		}
+		r.AFISAFIs = append(r.AFISAFIs, AFISAFI{AFI: uint16(afi), SAFI: uint8(safi)})
+	default:
+		return fmt.Errorf("invalid criterion %q", key)
+	}
+
+	return nil
+}
+
+// Pass returns true when the BMP message b passes the filter, a nil filter passes all messages.
+func (f *Filter) Pass(b []byte) bool {
+	if f == nil || len(b) < bmp.CommonHeaderLength {
+		return true
+	}
+	if _, ok := peerKey(b); !ok {
+		// Messages without Per Peer Header are always kept
+		return true
+	}
+	if len(f.Keep) != 0 && !matchAny(f.Keep, b, true) {
+		return false
+	}
+	return !matchAny(f.Drop, b, false)
+}
+
+func matchAny(rules []FilterRule, b []byte, keep bool) bool {
+	for i := range rules {
+		if rules[i].match(b, keep) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FilterRule) match(b []byte, keep bool) bool {
+	if len(r.Messages) != 0 && !containsUint8(r.Messages, b[5]) {
+		return false
+	}
+	if len(r.PeerTypes) != 0 && !containsUint8(r.PeerTypes, b[peerTypeOffset]) {
+		return false
+	}
+	if len(r.Peers) != 0 {
+		addr := b[peerAddrOffset : peerAddrOffset+16]
+		ip := net.IP(addr)
+		if b[peerFlagsOffset]&0x80 == 0 {
+			ip = net.IP(addr[12:16])
+		}
+		if !contains(r.Peers, ip) {
+			return false
+		}
+	}
+	if len(r.PeerASNs) != 0 {
+		as := binary.BigEndian.Uint32(b[peerASOffset : peerASOffset+4])
+		found := false
+		for _, a := range r.PeerASNs {
+			if a == as {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.AFISAFIs) != 0 {
+		if b[5] != bmp.RouteMonitorMsg {
+			return keep
+		}
+		found := false
+		for _, as := range updateAFISAFIs(b[bgpMessageOffset:]) {
+			for _, m := range r.AFISAFIs {
+				if as == m {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsUint8(l []uint8, v uint8) bool {
+	for _, e := range l {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// updateAFISAFIs returns AFI/SAFIs of routes carried by BGP Update message b, IPv4 unicast for
+// Withdrawn Routes and NLRI, and AFI/SAFI of MP_REACH_NLRI and MP_UNREACH_NLRI attributes.
+func updateAFISAFIs(b []byte) []AFISAFI {
+	// BGP header is 16 bytes of marker, 2 bytes of length and 1 byte of type
+	const bgpHeaderLength = 19
+	if len(b) < bgpHeaderLength+4 || b[18] != 2 {
+		return nil
+	}
+	l := int(binary.BigEndian.Uint16(b[16:18]))
+	if l > len(b) || l < bgpHeaderLength+4 {
+		return nil
+	}
+	b = b[bgpHeaderLength:l]
+	withdrawn := int(binary.BigEndian.Uint16(b[0:2]))
+	if 2+withdrawn+2 > len(b) {
+		return nil
+	}
+	attrs := int(binary.BigEndian.Uint16(b[2+withdrawn : 2+withdrawn+2]))
+	p := 2 + withdrawn + 2
+	if p+attrs > len(b) {
+		return nil
+	}
+	nlri := len(b) - p - attrs
+	var afisafis []AFISAFI
+	// Update without Withdrawn Routes, Path Attributes and NLRI is IPv4 unicast End-of-RIB
+	if withdrawn != 0 || nlri != 0 || attrs == 0 {
+		afisafis = append(afisafis, AFISAFI{AFI: 1, SAFI: 1})
+	}
+	for a := b[p : p+attrs]; len(a) >= 3; {
+		hl, vl := 3, int(a[2])
+		if a[0]&0x10 != 0 {
+			if len(a) < 4 {
+				break
+			}
+			hl, vl = 4, int(binary.BigEndian.Uint16(a[2:4]))
+		}
+		if hl+vl > len(a) {
+			break
+		}
+		if (a[1] == 14 || a[1] == 15) && vl >= 3 {
+			v := a[hl : hl+vl]
+			afisafis = append(afisafis, AFISAFI{AFI: binary.BigEndian.Uint16(v[0:2]), SAFI: v[2]})
+		}
+		a = a[hl+vl:]
+	}
+
+	return afisafis
+}
@@ -0,0 +1,161 @@
+package gobmpsrv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// mpRouteMonitorMessage builds Route Monitoring message carrying MP_REACH_NLRI of IPv6 unicast
+func mpRouteMonitorMessage(peer int) []byte {
+	mpReach := []byte{0, 2, 1, 16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 64, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 1}
+	attrs := []byte{0x40, 1, 1, 0, 0x80, 14, byte(len(mpReach))}
+	attrs = append(attrs, mpReach...)
+	update := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 2, 0, 0, 0, byte(len(attrs))}
+	update = append(update, attrs...)
+	binary.BigEndian.PutUint16(update[16:18], uint16(len(update)))
+	return bmpMessage(bmp.RouteMonitorMsg, perPeerHeader(peer), update)
+}
+
+func withPeerAS(b []byte, as uint32) []byte {
+	m := append([]byte{}, b...)
+	binary.BigEndian.PutUint32(m[peerASOffset:peerASOffset+4], as)
+	return m
+}
+
+func TestFilter(t *testing.T) {
+	stats := bmpMessage(bmp.StatsReportMsg, perPeerHeader(0), []byte{0, 0, 0, 0})
+	mirroring := bmpMessage(bmp.RouteMirrorMsg, perPeerHeader(0), []byte{0, 1, 0, 2, 0, 0})
+	tests := []struct {
+		name string
+		spec string
+		msgs [][]byte
+		pass []bool
+	}{
+		{
+			name: "empty filter",
+			spec: "",
+			msgs: [][]byte{initiationMessage(), peerUpMessage(0), routeMonitorMessage(0, 0), stats, mirroring},
+			pass: []bool{true, true, true, true, true},
+		},
+		{
+			name: "drop stats and route mirroring",
+			spec: "drop msg=stats,route-mirroring",
+			msgs: [][]byte{initiationMessage(), peerUpMessage(0), routeMonitorMessage(0, 0), stats, mirroring},
+			pass: []bool{true, true, true, false, false},
+		},
+		{
+			name: "keep peer",
+			spec: "keep peer=10.0.0.1",
+			msgs: [][]byte{initiationMessage(), peerUpMessage(0), peerUpMessage(1), routeMonitorMessage(1, 0), peerDownMessage(0)},
+			pass: []bool{true, false, true, true, false},
+		},
+		{
+			name: "keep prefix drop peer",
+			spec: "keep peer=10.0.0.0/24; drop peer=10.0.0.2",
+			msgs: [][]byte{routeMonitorMessage(1, 0), routeMonitorMessage(2, 0), routeMonitorMessage(256, 0)},
+			pass: []bool{true, false, false},
+		},
+		{
+			name: "keep peer AS",
+			spec: "keep as=65002,65003",
+			msgs: [][]byte{peerUpMessage(0), withPeerAS(peerUpMessage(0), 65002), withPeerAS(routeMonitorMessage(0, 0), 65003)},
+			pass: []bool{false, true, true},
+		},
+		{
+			name: "drop peer type",
+			spec: "drop type=loc-rib",
+			msgs: [][]byte{routeMonitorMessage(0, 0), func() []byte {
+				m := routeMonitorMessage(0, 0)
+				m[peerTypeOffset] = 3
+				return m
+			}()},
+			pass: []bool{true, false},
+		},
+		{
+			name: "keep afi-safi",
+			spec: "keep afi-safi=2/1",
+			msgs: [][]byte{peerUpMessage(0), routeMonitorMessage(0, 0), mpRouteMonitorMessage(0), peerDownMessage(0)},
+			pass: []bool{true, false, true, true},
+		},
+		{
+			name: "drop afi-safi of a peer",
+			spec: "drop peer=10.0.0.0 afi-safi=1/1",
+			msgs: [][]byte{peerUpMessage(0), routeMonitorMessage(0, 0), mpRouteMonitorMessage(0), routeMonitorMessage(1, 0)},
+			pass: []bool{true, false, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.spec)
+			if err != nil {
+				t.Fatalf("failed to parse filter: %+v", err)
+			}
+			for i, m := range tt.msgs {
+				if pass := f.Pass(m); pass != tt.pass[i] {
+					t.Errorf("message %d expected pass %t got %t", i, tt.pass[i], pass)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []string{
+		"keep",
+		"allow peer=10.0.0.1",
+		"keep peer=10.0.0.300",
+		"drop msg=keepalive",
+		"drop as=abc",
+		"drop afi-safi=1",
+		"drop type=vrf",
+		"drop color=red",
+	}
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := ParseFilter(spec); err == nil {
+				t.Errorf("expected filter %q to fail", spec)
+			}
+		})
+	}
+}
+
+func TestProxyFilter(t *testing.T) {
+	dest, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start destination listener: %+v", err)
+	}
+	defer dest.Close()
+	filter, _ := ParseFilter("keep peer=10.0.0.1")
+	pub := &recorder{}
+	srv := newTestServer(pub, &Config{
+		InterceptDestinations: []string{dest.Addr().String()},
+		ProxyFilter:           filter,
+	})
+	srv.intercept = true
+	received := acceptStream(dest)
+	if err := runSession(srv, 1, routerStream(2, 5)); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	// The filter applies only to messages re-emitted to the destination
+	if len(pub.msgs) != 1+2+10+2 {
+		t.Errorf("expected %d messages to be published got %d", 1+2+10+2, len(pub.msgs))
+	}
+	expect := append(initiationMessage(), peerUpMessage(1)...)
+	for r := 0; r < 5; r++ {
+		expect = append(expect, routeMonitorMessage(1, r)...)
+	}
+	expect = append(expect, peerDownMessage(1)...)
+	select {
+	case b := <-received:
+		if !bytes.Equal(b, expect) {
+			t.Errorf("destination received %d bytes, expected %d", len(b), len(expect))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("destination did not receive filtered messages")
+	}
+}
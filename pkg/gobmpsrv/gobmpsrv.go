@@ -1,15 +1,17 @@
 package gobmpsrv
 
 import (
+	"crypto/md5"
+	"crypto/tls"
 	"fmt"
-	"io"
 	"net"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/sbezverk/gobmp/pkg/bmp"
 	"github.com/sbezverk/gobmp/pkg/message"
-	"github.com/sbezverk/gobmp/pkg/parser"
 	"github.com/sbezverk/gobmp/pkg/pub"
 	"github.com/sbezverk/gobmp/pkg/store"
 )
@@ -19,16 +21,20 @@ type BMPServer interface {
 	Start()
 	Stop()
 	GetStore() *store.Store
+	GetClients() []ClientStatus
+	GetAdmissionStats() AdmissionStats
 }
 
 // Per-client info
 type clientInfo struct {
 	store *store.Store
+	since time.Time
 }
 
 func newClientInfo() *clientInfo {
 	return &clientInfo{
 		store: store.NewStore(),
+		since: time.Now(),
 	}
 }
 
@@ -68,7 +74,15 @@ func newClientsInfo() *clientsInfo {
 }
 
 type bmpServer struct {
-	splitAF         bool
+	splitAF     bool
+	tableTopics bool
+	// typedCommunities when true publishes decoded communities in base attributes
+	typedCommunities bool
+	// rawTopic when true publishes raw BMP messages with OpenBMP binary header
+	rawTopic         bool
+	collectorAdminID string
+	// routerNames defines names of routers keyed by router IP address
+	routerNames     map[string]string
 	intercept       bool
 	storeData       bool
 	publisher       pub.Publisher
@@ -77,12 +91,46 @@ type bmpServer struct {
 	incoming        net.Listener
 	stop            chan struct{}
 	clientsInfo     *clientsInfo
+	pool            *workerPool
+	queueSize       int
+	activeTargets   []*activeTarget
+	backoffMin      time.Duration
+	backoffMax      time.Duration
+	interceptTLS    *tls.Config
+	// interceptDestinations is the list of BMP stations in host:port format receiving copies of messages
+	interceptDestinations []string
+	interceptBufferSize   int
+	proxyFilter           *Filter
+	admission             *admission
+	messageRate           int
+	byteRate              int
+	rateLimitAction       RateLimitAction
+	keepAlive             time.Duration
+	idleTimeout           time.Duration
+	// relay when not nil relays BMP sessions to the core collector instead of processing them
+	relay *relayClient
+	// relayIncoming accepts connections from edge collectors
+	relayIncoming net.Listener
+	// relayed keeps the last relayed session of each router
+	relayed relayedRouters
 }
 
 func (srv *bmpServer) Start() {
 	// Starting bmp server server
-	glog.Infof("Starting gobmp server on %s, intercept mode: %t, store-data: %t\n", srv.incoming.Addr().String(), srv.intercept, srv.storeData)
+	glog.Infof("Starting gobmp server on %s, intercept mode: %t, store-data: %t, workers: %d\n", srv.incoming.Addr().String(), srv.intercept, srv.storeData, len(srv.pool.queues))
 	go srv.server()
+	if srv.relay != nil {
+		glog.Infof("Relaying BMP sessions to %s", srv.relay.address)
+		go srv.relay.run()
+	}
+	if srv.relayIncoming != nil {
+		glog.Infof("Accepting relayed BMP sessions on %s", srv.relayIncoming.Addr().String())
+		go srv.relayServer(srv.relayIncoming)
+	}
+	for _, t := range srv.activeTargets {
+		glog.Infof("Starting active BMP session to router %s", t.address)
+		go srv.activeWorker(t)
+	}
 }
 
 func (srv *bmpServer) Stop() {
@@ -91,17 +139,34 @@ func (srv *bmpServer) Stop() {
 		srv.publisher.Stop()
 	}
 	close(srv.stop)
+	_ = srv.incoming.Close()
+	if srv.relayIncoming != nil {
+		_ = srv.relayIncoming.Close()
+	}
 }
 
 func (srv *bmpServer) server() {
 	for {
 		client, err := srv.incoming.Accept()
 		if err != nil {
+			select {
+			case <-srv.stop:
+				return
+			default:
+			}
 			glog.Errorf("fail to accept client connection with error: %+v", err)
 			continue
 		}
+		if err := srv.admission.admit(client.RemoteAddr()); err != nil {
+			glog.Warningf("rejecting client %+v: %+v, rejected so far: %+v", client.RemoteAddr(), err, srv.admission.stats())
+			_ = client.Close()
+			continue
+		}
 		glog.V(5).Infof("client %+v accepted, calling bmpWorker", client.RemoteAddr())
-		go srv.bmpWorker(client)
+		go func() {
+			srv.bmpWorker(client)
+			srv.admission.release()
+		}()
 	}
 }
 
@@ -116,110 +181,311 @@ func (srv *bmpServer) GetStore() *store.Store {
 	return nil
 }
 
+// GetAdmissionStats returns the number of BMP sessions rejected or limited by admission control
+func (srv *bmpServer) GetAdmissionStats() AdmissionStats {
+	return srv.admission.stats()
+}
+
+// GetClients returns the status of BMP sessions accepted from routers and of configured active routers
+func (srv *bmpServer) GetClients() []ClientStatus {
+	clients := make([]ClientStatus, 0, len(srv.activeTargets))
+	active := make(map[string]bool)
+	for _, t := range srv.activeTargets {
+		t.Lock()
+		if t.session != "" {
+			active[t.session] = true
+		}
+		t.Unlock()
+		clients = append(clients, t.status())
+	}
+	srv.clientsInfo.mutex.RLock()
+	defer srv.clientsInfo.mutex.RUnlock()
+	passive := make([]ClientStatus, 0, len(srv.clientsInfo.info))
+	for addr, info := range srv.clientsInfo.info {
+		if active[addr] {
+			continue
+		}
+		passive = append(passive, ClientStatus{
+			Address:   addr,
+			Connected: true,
+			Since:     info.since,
+		})
+	}
+	sort.Slice(passive, func(i, j int) bool { return passive[i].Address < passive[j].Address })
+
+	return append(clients, passive...)
+}
+
 func (srv *bmpServer) bmpWorker(client net.Conn) {
 	defer func() {
 		_ = client.Close()
 	}()
-	// Create new client info (keyed by client remote address)
-	newClientInfo := newClientInfo()
-	if err := srv.clientsInfo.Add(client.RemoteAddr().String(), *newClientInfo); err != nil {
-		glog.Errorf("Failed to add client (already added) %s, %+v: %+v", client.RemoteAddr().String(), *newClientInfo, err)
+	// For TLS clients, the handshake is completed before any message is processed
+	identity, err := routerIdentity(client)
+	if err != nil {
+		glog.Errorf("failed to establish TLS session with client %s with error: %+v", client.RemoteAddr().String(), err)
+		return
 	}
-
-	var msgQueue chan interface{}
-	var storeStop chan struct{}
-	if srv.storeData {
-		// We need a message queue to be able to store messages generated by the producer
-		msgQueue = make(chan interface{})
-		storeStop = make(chan struct{})
-		// Start a goroutine to handle the messages from producer and store them
-		go newClientInfo.store.Store(msgQueue, storeStop)
+	if identity != "" {
+		glog.Infof("client %s authenticated as %q", client.RemoteAddr().String(), identity)
 	}
-
-	var server net.Conn
-	var err error
-	if srv.intercept {
-		server, err = net.Dial("tcp", ":"+fmt.Sprintf("%d", srv.destinationPort))
-		if err != nil {
-			glog.Errorf("failed to connect to destination with error: %+v", err)
-			return
+	setKeepAlive(client, srv.keepAlive)
+	reader := newMessageReader(client)
+	next := func() (*messageBuffer, error) {
+		if srv.idleTimeout > 0 {
+			if err := client.SetReadDeadline(time.Now().Add(srv.idleTimeout)); err != nil {
+				return nil, err
+			}
 		}
-		defer func() { _ = server.Close() }()
-		glog.V(5).Infof("connection to destination server %v established, start intercepting", server.RemoteAddr())
+		return reader.readMessage()
 	}
-	var producerQueue chan bmp.Message
-	prod := message.NewProducer(srv.publisher, srv.splitAF, msgQueue)
-	prodStop := make(chan struct{})
-	producerQueue = make(chan bmp.Message)
-	// Starting messages producer per client with dedicated work queue
-	go prod.Producer(producerQueue, prodStop)
+	srv.serveSession(client.RemoteAddr().String(), identity, next, newRateLimiter(srv.messageRate, srv.byteRate))
+}
 
-	parserQueue := make(chan []byte)
-	parsStop := make(chan struct{})
-	// Starting parser per client with dedicated work queue
-	go parser.Parser(parserQueue, producerQueue, parsStop)
+// serveSession processes messages of BMP session of the router returned by next until next fails,
+// when limiter is not nil, the rate of the session is limited.
+func (srv *bmpServer) serveSession(router string, identity string, next func() (*messageBuffer, error), limiter *rateLimiter) {
+	// Create new client info (keyed by client remote address)
+	newClientInfo := newClientInfo()
+	if err := srv.clientsInfo.Add(router, *newClientInfo); err != nil {
+		glog.Errorf("Failed to add client (already added) %s, %+v: %+v", router, *newClientInfo, err)
+	}
+	var intercept func([]byte)
+	if srv.intercept {
+		// Copies of messages are sent to intercept destinations, destinations are connected in the background
+		interceptor := srv.newInterceptor(router)
+		defer interceptor.close()
+		intercept = interceptor.forward
+	}
+	var sink sessionSink
+	if srv.relay != nil {
+		// Messages are parsed and published by the core collector
+		sink = srv.relay.open(router)
+	} else {
+		sink = srv.newLocalSink(router, identity, newClientInfo)
+	}
+	// reason is the reason of the session loss published in collector generated Peer Down messages
+	reason := reasonSessionClosed
 	defer func() {
-		glog.V(5).Infof("all done with client %+v", client.RemoteAddr())
-		close(parsStop)
-		close(prodStop)
-		if storeStop != nil {
-			close(storeStop)
-		}
-		if err := srv.clientsInfo.Del(client.RemoteAddr().String()); err != nil {
-			glog.Errorf("Failed to del client %s, %+v: %+v", client.RemoteAddr().String(), *newClientInfo, err)
+		glog.V(5).Infof("all done with client %s", router)
+		sink.close(reason)
+		if err := srv.clientsInfo.Del(router); err != nil {
+			glog.Errorf("Failed to del client %s, %+v: %+v", router, *newClientInfo, err)
 		}
-
 	}()
 	for {
-		headerMsg := make([]byte, bmp.CommonHeaderLength)
-		if _, err := io.ReadAtLeast(client, headerMsg, bmp.CommonHeaderLength); err != nil {
-			glog.Errorf("fail to read from client %+v with error: %+v", client.RemoteAddr(), err)
-			return
-		}
-		// Recovering common header first
-		header, err := bmp.UnmarshalCommonHeader(headerMsg[:bmp.CommonHeaderLength])
+		mb, err := next()
 		if err != nil {
-			glog.Errorf("fail to recover BMP message Common Header with error: %+v", err)
-			continue
+			glog.Errorf("fail to read from client %s with error: %+v", router, err)
+			reason = readErrorReason(err)
+			return
 		}
-		// Allocating space for the message body
-		msg := make([]byte, int(header.MessageLength)-bmp.CommonHeaderLength)
-		if _, err := io.ReadFull(client, msg); err != nil {
-			glog.Errorf("fail to read from client %+v with error: %+v", client.RemoteAddr(), err)
+		wait := limiter.limit(len(mb.b))
+		if wait > 0 && srv.rateLimitAction == RateLimitDisconnect {
+			mb.release()
+			srv.admission.rateLimited.Add(1)
+			reason = reasonRateLimit
+			glog.Errorf("client %s exceeded rate limit, disconnecting, rejected so far: %+v", router, srv.admission.stats())
 			return
 		}
-
-		fullMsg := make([]byte, int(header.MessageLength))
-		copy(fullMsg, headerMsg)
-		copy(fullMsg[bmp.CommonHeaderLength:], msg)
-		// Sending information to the server only in intercept mode
-		if srv.intercept {
-			if _, err := server.Write(fullMsg); err != nil {
-				glog.Errorf("fail to write to server %+v with error: %+v", server.RemoteAddr(), err)
+		// Sending information to intercept destinations only in intercept mode, the message buffer
+		// must not be accessed after it is consumed.
+		if intercept != nil && srv.proxyFilter.Pass(mb.b) {
+			intercept(mb.b)
+		}
+		sink.consume(mb)
+		if wait > 0 {
+			// Not reading from the client until the rate falls under the limit slows the router down
+			srv.admission.throttled.Add(1)
+			glog.V(5).Infof("client %s exceeded rate limit, pausing for %s", router, wait)
+			select {
+			case <-time.After(wait):
+			case <-srv.stop:
 				return
 			}
 		}
-		parserQueue <- fullMsg
 	}
 }
 
-// NewBMPServer instantiates a new instance of BMP Server
-func NewBMPServer(sPort, dPort int, intercept bool, p pub.Publisher, splitAF bool, storeData bool) (BMPServer, error) {
+// sessionSink consumes messages of a BMP session
+type sessionSink interface {
+	// consume takes the ownership of the message buffer
+	consume(mb *messageBuffer)
+	// close is called when the session is lost for the reason
+	close(reason string)
+}
+
+// localSink parses and publishes messages of a BMP session
+type localSink struct {
+	srv           *bmpServer
+	prod          message.Producer
+	producerQueue chan bmp.Message
+	prodDone      chan struct{}
+	disp          *dispatcher
+	storeStop     chan struct{}
+	// raw when not nil is OpenBMP binary header of raw messages published for the session
+	raw    *pub.RawHeader
+	rawKey []byte
+}
+
+func (srv *bmpServer) newLocalSink(router string, identity string, info *clientInfo) *localSink {
+	s := &localSink{
+		srv:           srv,
+		producerQueue: make(chan bmp.Message, srv.queueSize),
+		prodDone:      make(chan struct{}),
+	}
+	var msgQueue chan interface{}
+	if srv.storeData {
+		// We need a message queue to be able to store messages generated by the producer
+		msgQueue = make(chan interface{})
+		s.storeStop = make(chan struct{})
+		// Start a goroutine to handle the messages from producer and store them
+		go info.store.Store(msgQueue, s.storeStop)
+	}
+	ip := routerHost(router)
+	s.prod = message.NewProducer(srv.publisher, srv.splitAF, srv.tableTopics, srv.typedCommunities, msgQueue, ip, srv.routerNames[ip], identity)
+	// Starting messages producer per client with dedicated work queue
+	go func() {
+		// The producer stops when producerQueue is closed and all queued messages are processed
+		s.prod.Producer(s.producerQueue, nil)
+		close(s.prodDone)
+	}()
+	// Client's messages are parsed by the pool's workers preserving per peer order
+	s.disp = newDispatcher(srv.pool, router, s.producerQueue, srv.queueSize)
+	if srv.rawTopic {
+		s.raw, s.rawKey = newRawHeader(srv.collectorAdminID, router)
+	}
+
+	return s
+}
+
+// routerHost returns the IP address of the router from the remote address of BMP session
+func routerHost(router string) string {
+	host, _, err := net.SplitHostPort(router)
+	if err != nil {
+		return router
+	}
+	return host
+}
+
+// newRawHeader returns OpenBMP binary header of raw messages of the router and the key raw messages
+// are published with, the router hash is md5 hash of the router IP.
+func newRawHeader(collectorAdminID, router string) (*pub.RawHeader, []byte) {
+	host := routerHost(router)
+	h := &pub.RawHeader{
+		CollectorHash:    md5.Sum([]byte(collectorAdminID)),
+		CollectorAdminID: collectorAdminID,
+		RouterHash:       md5.Sum([]byte(host)),
+		RouterIP:         net.ParseIP(host),
+	}
+	if h.RouterIP == nil {
+		h.RouterIP = net.IPv6zero
+	}
+
+	return h, []byte(fmt.Sprintf("%x", h.RouterHash))
+}
+
+func (s *localSink) consume(mb *messageBuffer) {
+	if s.raw != nil {
+		// The message is published before it is dispatched, the header is copied with the message
+		h := *s.raw
+		h.Timestamp = mb.ts
+		if err := s.srv.publisher.PublishMessage(bmp.BMPRawMsg, s.rawKey, pub.MarshalRawMessage(&h, mb.b)); err != nil {
+			glog.Errorf("failed to publish raw message of router %s with error: %+v", h.RouterIP, err)
+		}
+	}
+	s.disp.dispatch(mb)
+}
+
+func (s *localSink) close(reason string) {
+	// Messages already received from the client get processed before the client's
+	// pipeline is torn down.
+	s.disp.drain()
+	close(s.producerQueue)
+	<-s.prodDone
+	select {
+	case <-s.srv.stop:
+		// The publisher is stopped together with the server
+	default:
+		s.prod.ProducePeersDown(reason)
+	}
+	if s.storeStop != nil {
+		close(s.storeStop)
+	}
+}
+
+// NewBMPServer instantiates a new instance of BMP Server, optional parameters are passed in config,
+// when config is nil, defaults are used.
+func NewBMPServer(sPort, dPort int, intercept bool, p pub.Publisher, splitAF bool, storeData bool, config *Config) (BMPServer, error) {
 	incoming, err := net.Listen("tcp", fmt.Sprintf(":%d", sPort))
 	if err != nil {
 		glog.Errorf("fail to setup listener on port %d with error: %+v", sPort, err)
 		return nil, err
 	}
+	admission, err := config.admission()
+	if err != nil {
+		_ = incoming.Close()
+		return nil, err
+	}
+	if c := config.tls(); c != nil {
+		incoming = tls.NewListener(incoming, c)
+	}
 	bmp := bmpServer{
-		stop:            make(chan struct{}),
-		sourcePort:      sPort,
-		destinationPort: dPort,
-		intercept:       intercept,
-		publisher:       p,
-		incoming:        incoming,
-		splitAF:         splitAF,
-		storeData:       storeData,
-		clientsInfo:     newClientsInfo(),
+		stop:             make(chan struct{}),
+		sourcePort:       sPort,
+		destinationPort:  dPort,
+		intercept:        intercept,
+		publisher:        p,
+		incoming:         incoming,
+		splitAF:          splitAF,
+		tableTopics:      config.tableTopics(),
+		typedCommunities: config.typedCommunities(),
+		storeData:        storeData,
+		clientsInfo:      newClientsInfo(),
+		pool:             newWorkerPool(config.workers(), config.queueSize(), config.shardBy()),
+		queueSize:        config.queueSize(),
+		interceptTLS:     config.interceptTLS(),
+		admission:        admission,
+	}
+	bmp.messageRate, bmp.byteRate, bmp.rateLimitAction = config.rateLimits()
+	bmp.keepAlive, bmp.idleTimeout = config.timeouts()
+	bmp.rawTopic, bmp.collectorAdminID = config.raw()
+	bmp.routerNames = config.routerNames()
+	bmp.interceptDestinations, bmp.interceptBufferSize = config.intercept(dPort)
+	bmp.proxyFilter = config.proxyFilter()
+	bmp.backoffMin, bmp.backoffMax = config.activeBackoff()
+	relayTo, relayTLS, relayBufferSize, relayPort := config.relay()
+	if relayTo != "" {
+		if _, _, err := net.SplitHostPort(relayTo); err != nil {
+			_ = incoming.Close()
+			return nil, fmt.Errorf("invalid relay core address %q: %w", relayTo, err)
+		}
+		bmp.relay = newRelayClient(relayTo, relayTLS, relayBufferSize, bmp.backoffMin, bmp.backoffMax, bmp.stop)
+	}
+	if relayPort != 0 {
+		c := config.relayListenerTLS()
+		if c == nil || c.ClientAuth != tls.RequireAndVerifyClientCert {
+			_ = incoming.Close()
+			return nil, fmt.Errorf("relay listener requires TLS with verified client certificates")
+		}
+		if bmp.relayIncoming, err = net.Listen("tcp", fmt.Sprintf(":%d", relayPort)); err != nil {
+			_ = incoming.Close()
+			return nil, fmt.Errorf("fail to setup relay listener on port %d with error: %w", relayPort, err)
+		}
+		bmp.relayIncoming = tls.NewListener(bmp.relayIncoming, c)
+	}
+	for _, d := range bmp.interceptDestinations {
+		if _, _, err := net.SplitHostPort(d); err != nil {
+			_ = incoming.Close()
+			return nil, fmt.Errorf("invalid intercept destination address %q: %w", d, err)
+		}
+	}
+	for _, r := range config.activeRouters() {
+		if _, _, err := net.SplitHostPort(r); err != nil {
+			_ = incoming.Close()
+			return nil, fmt.Errorf("invalid active router address %q: %w", r, err)
+		}
+		bmp.activeTargets = append(bmp.activeTargets, &activeTarget{address: r})
 	}
 
 	return &bmp, nil
@@ -0,0 +1,385 @@
+package gobmpsrv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/message"
+	"github.com/sbezverk/gobmp/pkg/parser"
+	"github.com/sbezverk/gobmp/pkg/pub"
+)
+
+// recorder is a publisher storing all published messages in the order they were published.
+type recorder struct {
+	sync.Mutex
+	msgs []recorded
+}
+
+type recorded struct {
+	t   int
+	msg []byte
+}
+
+func (r *recorder) PublishMessage(t int, key []byte, msg []byte) error {
+	r.Lock()
+	defer r.Unlock()
+	r.msgs = append(r.msgs, recorded{t: t, msg: msg})
+	return nil
+}
+
+func (r *recorder) Stop() {}
+
+func bmpMessage(t byte, body ...[]byte) []byte {
+	b := make([]byte, bmp.CommonHeaderLength)
+	for _, p := range body {
+		b = append(b, p...)
+	}
+	b[0] = 3
+	binary.BigEndian.PutUint32(b[1:5], uint32(len(b)))
+	b[5] = t
+	return b
+}
+
+// perPeerHeader builds Per-Peer header of the Global Instance peer 10.0.x.y, where x.y is peer's index.
+func perPeerHeader(peer int) []byte {
+	b := make([]byte, bmp.PerPeerHeaderLength)
+	// Peer address
+	b[22], b[23] = 10, 0
+	binary.BigEndian.PutUint16(b[24:26], uint16(peer))
+	// Peer AS
+	binary.BigEndian.PutUint32(b[26:30], 65001)
+	// Peer BGP ID
+	copy(b[30:34], b[22:26])
+	return b
+}
+
+func openMessage(as uint16, id []byte) []byte {
+	b := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 29, 1, 4, 0, 0, 0, 90}
+	binary.BigEndian.PutUint16(b[20:22], as)
+	b = append(b, id...)
+	return append(b, 0)
+}
+
+func peerUpMessage(peer int) []byte {
+	ph := perPeerHeader(peer)
+	body := make([]byte, 20)
+	// Local address 10.255.255.1
+	copy(body[12:16], []byte{10, 255, 255, 1})
+	binary.BigEndian.PutUint16(body[16:18], 179)
+	binary.BigEndian.PutUint16(body[18:20], 50000)
+	body = append(body, openMessage(65000, []byte{10, 255, 255, 1})...)
+	body = append(body, openMessage(65001, ph[22:26])...)
+	return bmpMessage(bmp.PeerUpMsg, ph, body)
+}
+
+func peerDownMessage(peer int) []byte {
+	return bmpMessage(bmp.PeerDownMsg, perPeerHeader(peer), []byte{4})
+}
+
+// routeMonitorMessage builds Route Monitoring message carrying IPv4 unicast prefix 100.x.y.z/32,
+// where x.y.z is the sequence number of the message.
+func routeMonitorMessage(peer int, seq int) []byte {
+	attrs := []byte{
+		0x40, 1, 1, 0, // ORIGIN IGP
+		0x40, 2, 6, 2, 1, 0, 0, 0xfd, 0xe9, // AS_PATH 65001
+		0x40, 3, 4, 10, 0, 0, 1, // NEXT_HOP
+	}
+	nlri := []byte{32, 100, byte(seq >> 16), byte(seq >> 8), byte(seq)}
+	update := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 2, 0, 0, 0, byte(len(attrs))}
+	update = append(update, attrs...)
+	update = append(update, nlri...)
+	binary.BigEndian.PutUint16(update[16:18], uint16(len(update)))
+	return bmpMessage(bmp.RouteMonitorMsg, perPeerHeader(peer), update)
+}
+
+func initiationMessage() []byte {
+	return bmpMessage(bmp.InitiationMsg, []byte{0, 2, 0, 8, 120, 114, 118, 57, 107, 45, 114, 49})
+}
+
+func newTestServer(p pub.Publisher, config *Config) *bmpServer {
+	admission, _ := config.admission()
+	srv := &bmpServer{
+		publisher:   p,
+		splitAF:     true,
+		clientsInfo: newClientsInfo(),
+		pool:        newWorkerPool(config.workers(), config.queueSize(), config.shardBy()),
+		queueSize:   config.queueSize(),
+		admission:   admission,
+	}
+	srv.messageRate, srv.byteRate, srv.rateLimitAction = config.rateLimits()
+	srv.keepAlive, srv.idleTimeout = config.timeouts()
+	srv.backoffMin, srv.backoffMax = config.activeBackoff()
+	srv.interceptDestinations, srv.interceptBufferSize = config.intercept(0)
+	srv.proxyFilter = config.proxyFilter()
+	srv.rawTopic, srv.collectorAdminID = config.raw()
+	srv.routerNames = config.routerNames()
+
+	return srv
+}
+
+// routerStream builds a stream of BMP messages sent by a router with a number of peers, each peer
+// comes up, advertises a number of routes and goes down, messages of different peers are interleaved.
+func routerStream(peers, routes int) []byte {
+	b := initiationMessage()
+	for p := 0; p < peers; p++ {
+		b = append(b, peerUpMessage(p)...)
+	}
+	for r := 0; r < routes; r++ {
+		for p := 0; p < peers; p++ {
+			b = append(b, routeMonitorMessage(p, r)...)
+		}
+	}
+	for p := 0; p < peers; p++ {
+		b = append(b, peerDownMessage(p)...)
+	}
+	return b
+}
+
+// pipeConn is an in-memory connection with a configurable remote address.
+type pipeConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// runSession runs BMP session of a router with the server feeding it with the stream and waits for the session to complete.
+func runSession(srv *bmpServer, router int, stream []byte) error {
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		srv.bmpWorker(&pipeConn{
+			Conn:   client,
+			remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, byte(router)), Port: 50000},
+		})
+		close(done)
+	}()
+	_, err := conn.Write(stream)
+	_ = conn.Close()
+	<-done
+	return err
+}
+
+func TestPerPeerOrdering(t *testing.T) {
+	peers, routes := 32, 200
+	tests := []struct {
+		name   string
+		config *Config
+	}{
+		{
+			name:   "shard by peer",
+			config: &Config{Workers: 8, QueueSize: 16, ShardBy: ShardByPeer},
+		},
+		{
+			name:   "shard by router",
+			config: &Config{Workers: 8, QueueSize: 16, ShardBy: ShardByRouter},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recorder{}
+			if err := runSession(newTestServer(pub, tt.config), 1, routerStream(peers, routes)); err != nil {
+				t.Fatalf("failed to write BMP stream: %+v", err)
+			}
+			checkPerPeerOrdering(t, pub.msgs, peers, routes)
+		})
+	}
+}
+
+func checkPerPeerOrdering(t *testing.T, msgs []recorded, peers, routes int) {
+	// Per peer state: 0..routes-1 next expected route, routes all routes received, routes+1 peer is down
+	state := make(map[string]int)
+	for _, m := range msgs {
+		switch m.t {
+		case bmp.PeerStateChangeMsg:
+			var u message.PeerStateChange
+			if err := json.Unmarshal(m.msg, &u); err != nil {
+				t.Fatalf("failed to unmarshal peer message: %+v", err)
+			}
+			s, ok := state[u.RemoteIP]
+			switch u.Action {
+			case "add":
+				if ok {
+					t.Fatalf("peer %s: unexpected peer up in state %d", u.RemoteIP, s)
+				}
+				state[u.RemoteIP] = 0
+			default:
+				if s != routes {
+					t.Fatalf("peer %s: peer down received after %d routes out of %d", u.RemoteIP, s, routes)
+				}
+				state[u.RemoteIP] = routes + 1
+			}
+		case bmp.UnicastPrefixV4Msg:
+			var u message.UnicastPrefix
+			if err := json.Unmarshal(m.msg, &u); err != nil {
+				t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+			}
+			s, ok := state[u.PeerIP]
+			if !ok {
+				t.Fatalf("peer %s: route received before peer up", u.PeerIP)
+			}
+			ip := net.ParseIP(u.Prefix).To4()
+			if seq := int(ip[1])<<16 | int(ip[2])<<8 | int(ip[3]); seq != s {
+				t.Fatalf("peer %s: expected route %d got %d", u.PeerIP, s, seq)
+			}
+			state[u.PeerIP] = s + 1
+		}
+	}
+	if len(state) != peers {
+		t.Fatalf("expected %d peers got %d", peers, len(state))
+	}
+	for peer, s := range state {
+		if s != routes+1 {
+			t.Errorf("peer %s: incomplete sequence, last state %d", peer, s)
+		}
+	}
+}
+
+// counter is a publisher counting published messages.
+type counter struct {
+	n atomic.Int64
+}
+
+func (c *counter) PublishMessage(t int, key []byte, msg []byte) error {
+	c.n.Add(1)
+	return nil
+}
+
+func (c *counter) Stop() {}
+
+// BenchmarkParse measures parsing of a router's BMP stream by a single goroutine.
+func BenchmarkParse(b *testing.B) {
+	stream := routerStream(16, 64)
+	msgs := make([][]byte, 0)
+	for p := 0; p < len(stream); {
+		l := int(binary.BigEndian.Uint32(stream[p+1 : p+5]))
+		msgs = append(msgs, stream[p:p+l])
+		p += l
+	}
+	q := make(chan bmp.Message, 1024)
+	go func() {
+		for range q {
+		}
+	}()
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range msgs {
+			parser.Parse(m, q, nil)
+		}
+	}
+	b.StopTimer()
+	close(q)
+}
+
+// captureFile is a stream of BMP messages recorded from a router, the default capture carries Initiation
+// and Peer Up messages of an XRv9k router followed by Route Monitoring messages with BGP Updates captured
+// from routers, a different capture is passed with -args -capture=<file>.
+var captureFile = flag.String("capture", "testdata/xrv9k.bmp", "file with BMP messages recorded from a router")
+
+// readCapture returns the recorded stream and the messages it carries
+func readCapture(b *testing.B) ([]byte, [][]byte) {
+	stream, err := os.ReadFile(*captureFile)
+	if err != nil {
+		b.Fatalf("failed to read capture %s with error: %+v", *captureFile, err)
+	}
+	msgs := make([][]byte, 0)
+	for p := 0; p < len(stream); {
+		if p+bmp.CommonHeaderLength > len(stream) {
+			b.Fatalf("capture %s is truncated", *captureFile)
+		}
+		l := int(binary.BigEndian.Uint32(stream[p+1 : p+5]))
+		if l < bmp.CommonHeaderLength || p+l > len(stream) {
+			b.Fatalf("capture %s carries invalid message length %d", *captureFile, l)
+		}
+		msgs = append(msgs, stream[p:p+l])
+		p += l
+	}
+	return stream, msgs
+}
+
+// BenchmarkParseCapture measures parsing of the recorded BMP stream by a single goroutine.
+func BenchmarkParseCapture(b *testing.B) {
+	stream, msgs := readCapture(b)
+	var n int
+	emit := func(m bmp.Message) { n++ }
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, m := range msgs {
+			parser.ParseFunc(m, emit, nil)
+		}
+	}
+	b.StopTimer()
+	if n != len(msgs)*b.N {
+		b.Fatalf("expected %d decoded messages got %d", len(msgs)*b.N, n)
+	}
+}
+
+// BenchmarkPipelineCapture measures the throughput of the whole pipeline fed by BMP sessions of a number
+// of routers replaying the recorded BMP stream.
+func BenchmarkPipelineCapture(b *testing.B) {
+	routers := 16
+	stream, _ := readCapture(b)
+	c := &counter{}
+	srv := newTestServer(c, &Config{})
+	b.SetBytes(int64(len(stream) * routers))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for r := 0; r < routers; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				_ = runSession(srv, r, stream)
+			}(r)
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(c.n.Load())/b.Elapsed().Seconds(), "msgs/s")
+}
+
+// BenchmarkPipeline measures the throughput of the whole pipeline, from reading BMP sessions
+// of a number of routers to publishing produced messages, with as many workers as GOMAXPROCS.
+// Run with -cpu 1,2,4,8 to see how the throughput scales with the number of cores.
+func BenchmarkPipeline(b *testing.B) {
+	routers := 16
+	stream := routerStream(16, 64)
+	for _, shardBy := range []ShardBy{ShardByPeer, ShardByRouter} {
+		name := "peer"
+		if shardBy == ShardByRouter {
+			name = "router"
+		}
+		b.Run(name, func(b *testing.B) {
+			c := &counter{}
+			srv := newTestServer(c, &Config{ShardBy: shardBy})
+			b.SetBytes(int64(len(stream) * routers))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for r := 0; r < routers; r++ {
+					wg.Add(1)
+					go func(r int) {
+						defer wg.Done()
+						_ = runSession(srv, r, stream)
+					}(r)
+				}
+				wg.Wait()
+			}
+			b.ReportMetric(float64(c.n.Load())/b.Elapsed().Seconds(), "msgs/s")
+		})
+	}
+}
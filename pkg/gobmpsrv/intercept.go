@@ -0,0 +1,287 @@
+package gobmpsrv
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+const (
+	// DefaultInterceptBufferSize defines the default number of messages buffered per intercept destination
+	DefaultInterceptBufferSize = 10000
+	// interceptTimeout defines the timeout of connecting and writing to an intercept destination
+	interceptTimeout = 10 * time.Second
+)
+
+// replayState keeps the messages a BMP station needs to learn the state of a BMP session when
+// it connects in the middle of the session: the Initiation message and Peer Up messages of the
+// peers which are up, in the order they went up.
+type replayState struct {
+	initiation []byte
+	peers      map[string][]byte
+	order      []string
+}
+
+func newReplayState() *replayState {
+	return &replayState{
+		peers: make(map[string][]byte),
+	}
+}
+
+func (s *replayState) apply(b []byte) {
+	switch b[5] {
+	case bmp.InitiationMsg:
+		s.initiation = b
+	case bmp.TerminationMsg:
+		s.initiation = nil
+		s.peers = make(map[string][]byte)
+		s.order = nil
+	case bmp.PeerUpMsg:
+		key, ok := peerKey(b)
+		if !ok {
+			return
+		}
+		if _, ok := s.peers[string(key)]; !ok {
+			s.order = append(s.order, string(key))
+		}
+		s.peers[string(key)] = b
+	case bmp.PeerDownMsg:
+		key, ok := peerKey(b)
+		if !ok {
+			return
+		}
+		if _, ok := s.peers[string(key)]; !ok {
+			return
+		}
+		delete(s.peers, string(key))
+		for i, k := range s.order {
+			if k == string(key) {
+				s.order = append(s.order[:i:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (s *replayState) clone() *replayState {
+	c := newReplayState()
+	c.initiation = s.initiation
+	for k, v := range s.peers {
+		c.peers[k] = v
+	}
+	c.order = append(c.order, s.order...)
+	return c
+}
+
+// messages returns the messages to replay
+func (s *replayState) messages() [][]byte {
+	msgs := make([][]byte, 0, len(s.order)+1)
+	if s.initiation != nil {
+		msgs = append(msgs, s.initiation)
+	}
+	for _, k := range s.order {
+		msgs = append(msgs, s.peers[k])
+	}
+	return msgs
+}
+
+// destination is a BMP station receiving a copy of messages of a BMP session
+type destination struct {
+	address string
+	queue   chan []byte
+	// overflow is set when the queue is full and messages are lost, the destination's connection
+	// is then re-established with the state of the session replayed, protected by interceptor's mutex.
+	overflow bool
+	// state is the state of the session known to the destination, accessed only by destination's goroutine
+	state *replayState
+}
+
+// interceptor copies messages of a BMP session to intercept destinations, failures of destinations
+// never affect the BMP session with the router.
+type interceptor struct {
+	sync.Mutex
+	router       string
+	tls          *tls.Config
+	state        *replayState
+	destinations []*destination
+	backoffMin   time.Duration
+	backoffMax   time.Duration
+	done         chan struct{}
+	stop         chan struct{}
+}
+
+func (srv *bmpServer) newInterceptor(router string) *interceptor {
+	i := &interceptor{
+		router:     router,
+		tls:        srv.interceptTLS,
+		state:      newReplayState(),
+		backoffMin: srv.backoffMin,
+		backoffMax: srv.backoffMax,
+		done:       make(chan struct{}),
+		stop:       srv.stop,
+	}
+	for _, addr := range srv.interceptDestinations {
+		d := &destination{
+			address: addr,
+			queue:   make(chan []byte, srv.interceptBufferSize),
+			state:   newReplayState(),
+		}
+		i.destinations = append(i.destinations, d)
+		go i.run(d)
+	}
+
+	return i
+}
+
+// forward queues a copy of the message to every destination, it never blocks, when the queue
+// of a destination is full, the message is dropped for that destination.
+func (i *interceptor) forward(b []byte) {
+	msg := make([]byte, len(b))
+	copy(msg, b)
+	i.Lock()
+	defer i.Unlock()
+	i.state.apply(msg)
+	for _, d := range i.destinations {
+		if d.overflow {
+			continue
+		}
+		select {
+		case d.queue <- msg:
+		default:
+			d.overflow = true
+			glog.Warningf("intercept destination %s of router %s is not keeping up, buffer of %d messages is full, dropping messages", d.address, i.router, cap(d.queue))
+		}
+	}
+}
+
+// close stops intercepting once destinations sent all queued messages, destinations which are not
+// connected give up.
+func (i *interceptor) close() {
+	i.Lock()
+	defer i.Unlock()
+	for _, d := range i.destinations {
+		close(d.queue)
+	}
+	close(i.done)
+}
+
+// resync checks if destination lost messages, in which case the destination's queue is discarded,
+// and the destination's state is set to the state of the session.
+func (i *interceptor) resync(d *destination) bool {
+	i.Lock()
+	defer i.Unlock()
+	if !d.overflow {
+		return false
+	}
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-d.queue:
+			drained = !ok
+		default:
+			drained = true
+		}
+	}
+	d.state = i.state.clone()
+	d.overflow = false
+
+	return true
+}
+
+func (i *interceptor) dial(address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: interceptTimeout}
+	if i.tls != nil {
+		return tls.DialWithDialer(dialer, "tcp", address, i.tls)
+	}
+	return dialer.Dial("tcp", address)
+}
+
+// connect connects to the destination retrying with exponential backoff and replays the state of
+// the session, nil is returned when the session is over before the connection is established.
+// When the destination lost messages while disconnected, resynced is true.
+func (i *interceptor) connect(d *destination) (conn net.Conn, resynced bool) {
+	backoff := i.backoffMin
+	for {
+		if i.resync(d) {
+			resynced = true
+		}
+		conn, err := i.dial(d.address)
+		if err == nil {
+			glog.Infof("connected to intercept destination %s for router %s", d.address, i.router)
+			if err = write(conn, d.state.messages()...); err == nil {
+				return conn, resynced
+			}
+			_ = conn.Close()
+		}
+		glog.Errorf("fail to connect to intercept destination %s for router %s with error: %+v, will retry in %s", d.address, i.router, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-i.done:
+			return nil, resynced
+		case <-i.stop:
+			return nil, resynced
+		}
+		if backoff *= 2; backoff > i.backoffMax {
+			backoff = i.backoffMax
+		}
+	}
+}
+
+func write(conn net.Conn, msgs ...[]byte) error {
+	for _, b := range msgs {
+		if err := conn.SetWriteDeadline(time.Now().Add(interceptTimeout)); err != nil {
+			return err
+		}
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run sends queued messages to the destination, the connection is re-established when it fails.
+func (i *interceptor) run(d *destination) {
+	var conn net.Conn
+	var pending []byte
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	for {
+		if conn == nil {
+			var resynced bool
+			if conn, resynced = i.connect(d); conn == nil {
+				return
+			}
+			if resynced {
+				// The pending message is a part of the state replayed to the destination
+				pending = nil
+			}
+		}
+		if pending == nil {
+			if i.resync(d) {
+				glog.Warningf("intercept destination %s of router %s lost messages, reconnecting", d.address, i.router)
+				_ = conn.Close()
+				conn = nil
+				continue
+			}
+			msg, ok := <-d.queue
+			if !ok {
+				return
+			}
+			pending = msg
+		}
+		if err := write(conn, pending); err != nil {
+			glog.Errorf("fail to write to intercept destination %s for router %s with error: %+v", d.address, i.router, err)
+			_ = conn.Close()
+			conn = nil
+			continue
+		}
+		d.state.apply(pending)
+		pending = nil
+	}
+}
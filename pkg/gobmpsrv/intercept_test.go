@@ -0,0 +1,169 @@
+package gobmpsrv
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReplayState(t *testing.T) {
+	tests := []struct {
+		name   string
+		msgs   [][]byte
+		expect [][]byte
+	}{
+		{
+			name:   "initiation and peers up",
+			msgs:   [][]byte{initiationMessage(), peerUpMessage(0), routeMonitorMessage(0, 0), peerUpMessage(1)},
+			expect: [][]byte{initiationMessage(), peerUpMessage(0), peerUpMessage(1)},
+		},
+		{
+			name:   "peer down",
+			msgs:   [][]byte{initiationMessage(), peerUpMessage(0), peerUpMessage(1), peerUpMessage(2), peerDownMessage(1)},
+			expect: [][]byte{initiationMessage(), peerUpMessage(0), peerUpMessage(2)},
+		},
+		{
+			name:   "peer down without peer up",
+			msgs:   [][]byte{initiationMessage(), peerDownMessage(1)},
+			expect: [][]byte{initiationMessage()},
+		},
+		{
+			name:   "termination",
+			msgs:   [][]byte{initiationMessage(), peerUpMessage(0), bmpMessage(5)},
+			expect: [][]byte{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newReplayState()
+			for _, m := range tt.msgs {
+				s.apply(m)
+			}
+			got := s.clone().messages()
+			if len(got) != len(tt.expect) {
+				t.Fatalf("expected %d messages got %d", len(tt.expect), len(got))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.expect[i]) {
+					t.Errorf("message %d mismatch", i)
+				}
+			}
+		})
+	}
+}
+
+// acceptStream accepts a connection and returns everything received until the connection is closed
+func acceptStream(l net.Listener) chan []byte {
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+	return received
+}
+
+func receive(t *testing.T, received chan []byte) []byte {
+	select {
+	case b := <-received:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatalf("destination did not receive intercepted messages")
+	}
+	return nil
+}
+
+// unusedAddress returns a local address nothing listens on
+func unusedAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start listener: %+v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+func TestInterceptFanOut(t *testing.T) {
+	dest, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start destination listener: %+v", err)
+	}
+	defer dest.Close()
+	pub := &recorder{}
+	srv := newTestServer(pub, &Config{
+		InterceptDestinations: []string{dest.Addr().String(), unusedAddress(t)},
+		ActiveBackoffMin:      10 * time.Millisecond,
+		ActiveBackoffMax:      10 * time.Millisecond,
+	})
+	srv.intercept = true
+	received := acceptStream(dest)
+	stream := routerStream(2, 10)
+	// The destination which is down does not affect the session with the router
+	if err := runSession(srv, 1, stream); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	if len(pub.msgs) != 1+2+20+2 {
+		t.Errorf("expected %d messages to be published got %d", 1+2+20+2, len(pub.msgs))
+	}
+	if b := receive(t, received); !bytes.Equal(b, stream) {
+		t.Errorf("destination received %d bytes, expected %d", len(b), len(stream))
+	}
+}
+
+func TestInterceptReplay(t *testing.T) {
+	addr := unusedAddress(t)
+	srv := newTestServer(&recorder{}, &Config{
+		InterceptDestinations: []string{addr},
+		InterceptBufferSize:   4,
+		ActiveBackoffMin:      10 * time.Millisecond,
+		ActiveBackoffMax:      10 * time.Millisecond,
+	})
+	srv.intercept = true
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		srv.bmpWorker(&pipeConn{
+			Conn:   client,
+			remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000},
+		})
+		close(done)
+	}()
+	// While the destination is down, more messages are received than buffered
+	before := append(initiationMessage(), peerUpMessage(0)...)
+	before = append(before, peerUpMessage(1)...)
+	for r := 0; r < 10; r++ {
+		before = append(before, routeMonitorMessage(0, r)...)
+	}
+	if _, err := conn.Write(before); err != nil {
+		t.Fatalf("failed to write BMP stream: %+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	dest, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to start destination listener: %+v", err)
+	}
+	defer dest.Close()
+	received := acceptStream(dest)
+	// Waiting for the destination to be connected
+	time.Sleep(100 * time.Millisecond)
+	after := append(routeMonitorMessage(0, 10), peerDownMessage(1)...)
+	if _, err := conn.Write(after); err != nil {
+		t.Fatalf("failed to write BMP stream: %+v", err)
+	}
+	_ = conn.Close()
+	<-done
+	// The destination learns the state of the session and receives messages which follow
+	expect := append(initiationMessage(), peerUpMessage(0)...)
+	expect = append(expect, peerUpMessage(1)...)
+	expect = append(expect, after...)
+	if b := receive(t, received); !bytes.Equal(b, expect) {
+		t.Errorf("destination received %d bytes, expected %d", len(b), len(expect))
+	}
+}
@@ -0,0 +1,88 @@
+package gobmpsrv
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/pub"
+)
+
+func TestRawTopic(t *testing.T) {
+	messages := [][]byte{initiationMessage(), peerUpMessage(0), routeMonitorMessage(0, 0), routeMonitorMessage(0, 1), peerDownMessage(0)}
+	stream := bytes.Join(messages, nil)
+	tests := []struct {
+		name   string
+		config *Config
+		raw    bool
+	}{
+		{
+			name:   "raw topic disabled",
+			config: &Config{},
+		},
+		{
+			name:   "raw topic enabled",
+			config: &Config{RawTopic: true, CollectorAdminID: "collector1"},
+			raw:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &recorder{}
+			if err := runSession(newTestServer(p, tt.config), 1, stream); err != nil {
+				t.Fatalf("failed to write BMP stream: %+v", err)
+			}
+			var raws [][]byte
+			for _, m := range p.msgs {
+				if m.t == bmp.BMPRawMsg {
+					raws = append(raws, m.msg)
+				}
+			}
+			if !tt.raw {
+				if len(raws) != 0 {
+					t.Fatalf("expected no raw messages got %d", len(raws))
+				}
+				return
+			}
+			if len(raws) != len(messages) {
+				t.Fatalf("expected %d raw messages got %d", len(messages), len(raws))
+			}
+			for i, r := range raws {
+				h, m, err := pub.UnmarshalRawMessage(r)
+				if err != nil {
+					t.Fatalf("failed to unmarshal raw message with error: %+v", err)
+				}
+				if !bytes.Equal(m, messages[i]) {
+					t.Errorf("raw message %d does not match received message", i)
+				}
+				if h.CollectorAdminID != "collector1" || h.CollectorHash != md5.Sum([]byte("collector1")) {
+					t.Errorf("invalid collector of raw message: %+v", h)
+				}
+				if h.RouterIP.String() != "192.0.2.1" || h.RouterHash != md5.Sum([]byte("192.0.2.1")) {
+					t.Errorf("invalid router of raw message: %+v", h)
+				}
+				if h.Timestamp.IsZero() {
+					t.Errorf("raw message is missing timestamp")
+				}
+			}
+			// Parsed messages of the router are identified by the same router hash
+			for _, m := range p.msgs {
+				if m.t == bmp.BMPRawMsg {
+					continue
+				}
+				var r struct {
+					RouterHash string `json:"router_hash"`
+				}
+				if err := json.Unmarshal(m.msg, &r); err != nil {
+					t.Fatalf("failed to unmarshal message: %+v", err)
+				}
+				if r.RouterHash != fmt.Sprintf("%x", md5.Sum([]byte("192.0.2.1"))) {
+					t.Errorf("message of type %d has router hash %s different from raw messages", m.t, r.RouterHash)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package gobmpsrv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+const (
+	// readBufferSize defines the size of the buffer used to read from BMP session
+	readBufferSize = 64 * 1024
+	// minMessageBuffer defines the capacity of buffers allocated by the message pool
+	minMessageBuffer = 4096
+	// maxPooledBuffer defines the capacity above which buffers are not returned to the message pool
+	maxPooledBuffer = 64 * 1024
+	// maxMessageLength defines the maximum length of BMP message accepted from BMP session
+	maxMessageLength = 16 * 1024 * 1024
+)
+
+// messageBuffer holds a single BMP message read from BMP session.
+//
+// Ownership rules:
+//   - messageReader hands out a buffer per message, the session owns it until the message is dispatched.
+//   - The intercept writer only writes the buffer before the message is dispatched and never retains it.
+//   - Once dispatched, the buffer is owned by the parsing worker, the decoded messages may reference it
+//     without copying. The buffer is released when the producer calls bmp.Message Done of the last
+//     message decoded from it, or by the worker when no message was passed to the producer.
+//   - After the release neither the parser, nor the producer may access any slice of the buffer, anything
+//     retained beyond the processing of the message must be copied.
+type messageBuffer struct {
+	b []byte
+	// ts is the time the message was received from the router
+	ts time.Time
+}
+
+var messagePool = sync.Pool{
+	New: func() interface{} {
+		return &messageBuffer{b: make([]byte, 0, minMessageBuffer)}
+	},
+}
+
+func getMessageBuffer(l int) *messageBuffer {
+	mb := messagePool.Get().(*messageBuffer)
+	if cap(mb.b) < l {
+		mb.b = make([]byte, l)
+	}
+	mb.b = mb.b[:l]
+
+	return mb
+}
+
+// release returns the buffer back to the pool.
+func (mb *messageBuffer) release() {
+	if cap(mb.b) > maxPooledBuffer {
+		// Do not keep large buffers, large messages are rare
+		return
+	}
+	mb.b = mb.b[:0]
+	messagePool.Put(mb)
+}
+
+// messageReader reads BMP messages from a stream, each message is returned in a single buffer
+// obtained from the message pool.
+type messageReader struct {
+	r *bufio.Reader
+}
+
+func newMessageReader(r io.Reader) *messageReader {
+	return &messageReader{
+		r: bufio.NewReaderSize(r, readBufferSize),
+	}
+}
+
+// readMessage returns the next BMP message including its Common Header, the caller owns the returned buffer.
+func (mr *messageReader) readMessage() (*messageBuffer, error) {
+	h, err := mr.r.Peek(bmp.CommonHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+	if h[0] != 3 {
+		return nil, fmt.Errorf("invalid version in common header, expected 3 found %d", h[0])
+	}
+	l := binary.BigEndian.Uint32(h[1:5])
+	if l < bmp.CommonHeaderLength || l > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length %d in common header", l)
+	}
+	mb := getMessageBuffer(int(l))
+	if _, err := io.ReadFull(mr.r, mb.b); err != nil {
+		mb.release()
+		return nil, err
+	}
+	mb.ts = time.Now()
+
+	return mb, nil
+}
@@ -0,0 +1,125 @@
+package gobmpsrv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func TestReadMessage(t *testing.T) {
+	stream := routerStream(2, 2)
+	tests := []struct {
+		name   string
+		input  []byte
+		expect int
+		fail   bool
+	}{
+		{
+			name:   "valid stream",
+			input:  stream,
+			expect: 9,
+		},
+		{
+			name:   "truncated message",
+			input:  stream[:len(stream)-1],
+			expect: 8,
+			fail:   true,
+		},
+		{
+			name:   "invalid version",
+			input:  []byte{33, 0, 0, 0, 6, 4},
+			expect: 0,
+			fail:   true,
+		},
+		{
+			name:   "invalid length",
+			input:  []byte{3, 0, 0, 0, 5, 4},
+			expect: 0,
+			fail:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newMessageReader(bytes.NewReader(tt.input))
+			p := 0
+			n := 0
+			var err error
+			for {
+				var mb *messageBuffer
+				if mb, err = r.readMessage(); err != nil {
+					break
+				}
+				l := int(binary.BigEndian.Uint32(tt.input[p+1 : p+5]))
+				if !bytes.Equal(mb.b, tt.input[p:p+l]) {
+					t.Fatalf("message %d does not match the stream", n)
+				}
+				p += l
+				n++
+				mb.release()
+			}
+			if n != tt.expect {
+				t.Errorf("expected %d messages got %d", tt.expect, n)
+			}
+			if tt.fail && err == io.EOF {
+				t.Errorf("expected to fail but succeeded")
+			}
+			if !tt.fail && err != io.EOF {
+				t.Errorf("expected to succeed but failed with error: %+v", err)
+			}
+		})
+	}
+}
+
+// legacyReadMessage reads BMP message the way bmpWorker used to, allocating header, body and
+// the full message buffers for every message.
+func legacyReadMessage(r io.Reader) ([]byte, error) {
+	headerMsg := make([]byte, bmp.CommonHeaderLength)
+	if _, err := io.ReadAtLeast(r, headerMsg, bmp.CommonHeaderLength); err != nil {
+		return nil, err
+	}
+	header, err := bmp.UnmarshalCommonHeader(headerMsg)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, int(header.MessageLength)-bmp.CommonHeaderLength)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	fullMsg := make([]byte, int(header.MessageLength))
+	copy(fullMsg, headerMsg)
+	copy(fullMsg[bmp.CommonHeaderLength:], msg)
+	return fullMsg, nil
+}
+
+func BenchmarkReadMessage(b *testing.B) {
+	stream := routerStream(16, 64)
+	b.Run("pooled", func(b *testing.B) {
+		b.SetBytes(int64(len(stream)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := newMessageReader(bytes.NewReader(stream))
+			for {
+				mb, err := r.readMessage()
+				if err != nil {
+					break
+				}
+				mb.release()
+			}
+		}
+	})
+	b.Run("legacy", func(b *testing.B) {
+		b.SetBytes(int64(len(stream)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := bytes.NewReader(stream)
+			for {
+				if _, err := legacyReadMessage(r); err != nil {
+					break
+				}
+			}
+		}
+	})
+}
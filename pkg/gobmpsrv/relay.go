@@ -0,0 +1,589 @@
+package gobmpsrv
+
+import (
+	"bufio"
+	"compress/flate"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// Relay protocol carries BMP sessions of many routers from an edge collector to a core collector over
+// a single connection. The connection starts with relayMagic followed by a stream compressed with
+// DEFLATE, the stream is a sequence of frames:
+//
+//	open:  type (1), session (4), router address length (2), router address
+//	data:  type (1), session (4), receive time in ns since Unix epoch (8), length (4), BMP message
+//	close: type (1), session (4)
+const (
+	relayOpenFrame  = 1
+	relayDataFrame  = 2
+	relayCloseFrame = 3
+	// DefaultRelayBufferSize defines the default number of messages buffered by the edge collector
+	DefaultRelayBufferSize = 100000
+)
+
+var relayMagic = []byte("GOBMPRELAY1\n")
+
+// relaySession is a BMP session of a router relayed to the core collector
+type relaySession struct {
+	sync.Mutex
+	id     uint32
+	router string
+	client *relayClient
+	// state is the state of the session as received from the router
+	state *replayState
+	// delivered is the state of the session as sent to the core, accessed only by the writer
+	delivered *replayState
+	// epoch is incremented when the session lost messages, queued frames of previous epochs are discarded
+	epoch    uint64
+	overflow bool
+	closed   bool
+	// opened is the generation of the connection the session was opened on, accessed only by the writer
+	opened uint64
+}
+
+type relayFrame struct {
+	session *relaySession
+	epoch   uint64
+	ts      time.Time
+	b       []byte
+	close   bool
+}
+
+// relayClient relays BMP sessions of an edge collector to the core collector, the connection is
+// re-established in the background, while the core is not reachable messages are buffered within
+// a bounded limit.
+type relayClient struct {
+	address    string
+	tls        *tls.Config
+	backoffMin time.Duration
+	backoffMax time.Duration
+	queue      chan relayFrame
+	stop       chan struct{}
+	sessionsMu sync.Mutex
+	sessions   map[uint32]*relaySession
+	nextID     uint32
+	// dirty is set when any session lost messages or is closed
+	dirty atomic.Bool
+	// dial connects to the core
+	dial func() (net.Conn, error)
+}
+
+func newRelayClient(address string, config *tls.Config, bufferSize int, backoffMin, backoffMax time.Duration, stop chan struct{}) *relayClient {
+	c := &relayClient{
+		address:    address,
+		tls:        config,
+		backoffMin: backoffMin,
+		backoffMax: backoffMax,
+		queue:      make(chan relayFrame, bufferSize),
+		stop:       stop,
+		sessions:   make(map[uint32]*relaySession),
+	}
+	c.dial = c.dialCore
+
+	return c
+}
+
+// open registers a new BMP session of the router
+func (c *relayClient) open(router string) *relaySession {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	c.nextID++
+	s := &relaySession{
+		id:        c.nextID,
+		router:    router,
+		client:    c,
+		state:     newReplayState(),
+		delivered: newReplayState(),
+	}
+	c.sessions[s.id] = s
+	// The writer opens the session on the core
+	c.dirty.Store(true)
+
+	return s
+}
+
+// send queues a copy of the message received at ts, it never blocks, when the buffer is full
+// the session drops messages until the writer resynchronizes it with the core.
+func (s *relaySession) send(b []byte, ts time.Time) {
+	msg := make([]byte, len(b))
+	copy(msg, b)
+	s.Lock()
+	defer s.Unlock()
+	s.state.apply(msg)
+	if s.overflow {
+		return
+	}
+	select {
+	case s.client.queue <- relayFrame{session: s, epoch: s.epoch, ts: ts, b: msg}:
+	default:
+		s.overflow = true
+		s.client.dirty.Store(true)
+		glog.Warningf("relay to %s is not keeping up, buffer of %d messages is full, dropping messages of router %s", s.client.address, cap(s.client.queue), s.router)
+	}
+}
+
+// consume queues the message, the time it was received from the router is relayed with it
+func (s *relaySession) consume(mb *messageBuffer) {
+	s.send(mb.b, mb.ts)
+	mb.release()
+}
+
+// close queues closing of the session after its queued messages, the core collector publishes
+// Peer Down messages of the session.
+func (s *relaySession) close(_ string) {
+	s.Lock()
+	defer s.Unlock()
+	select {
+	case s.client.queue <- relayFrame{session: s, close: true}:
+	default:
+		// Queued messages are discarded, the session is closed by the writer
+		s.closed = true
+		s.client.dirty.Store(true)
+	}
+}
+
+// relayWriter writes frames to the connection with the core
+type relayWriter struct {
+	conn net.Conn
+	fw   *flate.Writer
+	w    *bufio.Writer
+	gen  uint64
+}
+
+func (w *relayWriter) frame(t byte, id uint32, fields ...[]byte) error {
+	h := [5]byte{t}
+	binary.BigEndian.PutUint32(h[1:], id)
+	if _, err := w.w.Write(h[:]); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if _, err := w.w.Write(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *relayWriter) open(s *relaySession) error {
+	l := [2]byte{}
+	binary.BigEndian.PutUint16(l[:], uint16(len(s.router)))
+	if err := w.frame(relayOpenFrame, s.id, l[:], []byte(s.router)); err != nil {
+		return err
+	}
+	// The core learns the state of the session
+	for _, m := range s.delivered.messages() {
+		if err := w.data(s, time.Now(), m); err != nil {
+			return err
+		}
+	}
+	s.opened = w.gen
+	return nil
+}
+
+func (w *relayWriter) data(s *relaySession, ts time.Time, b []byte) error {
+	h := [12]byte{}
+	binary.BigEndian.PutUint64(h[0:8], uint64(ts.UnixNano()))
+	binary.BigEndian.PutUint32(h[8:12], uint32(len(b)))
+	return w.frame(relayDataFrame, s.id, h[:], b)
+}
+
+func (w *relayWriter) flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return w.fw.Flush()
+}
+
+func (c *relayClient) dialCore() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: interceptTimeout}
+	if c.tls != nil {
+		return tls.DialWithDialer(dialer, "tcp", c.address, c.tls)
+	}
+	return dialer.Dial("tcp", c.address)
+}
+
+// connect connects to the core retrying with exponential backoff, nil is returned when the server stops.
+func (c *relayClient) connect(gen uint64) *relayWriter {
+	backoff := c.backoffMin
+	for {
+		conn, err := c.dial()
+		if err == nil {
+			if _, err = conn.Write(relayMagic); err == nil {
+				glog.Infof("connected to relay core %s", c.address)
+				fw, _ := flate.NewWriter(conn, flate.BestSpeed)
+				return &relayWriter{conn: conn, fw: fw, w: bufio.NewWriterSize(fw, 64*1024), gen: gen}
+			}
+			_ = conn.Close()
+		}
+		glog.Errorf("fail to connect to relay core %s with error: %+v, will retry in %s", c.address, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-c.stop:
+			return nil
+		}
+		if backoff *= 2; backoff > c.backoffMax {
+			backoff = c.backoffMax
+		}
+	}
+}
+
+// sync opens new sessions, closes closed sessions and resynchronizes sessions which lost messages
+func (c *relayClient) sync(w *relayWriter) error {
+	if !c.dirty.Swap(false) {
+		return nil
+	}
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for id, s := range c.sessions {
+		s.Lock()
+		closed, overflow := s.closed, s.overflow
+		if overflow {
+			s.epoch++
+			s.overflow = false
+			s.delivered = s.state.clone()
+		}
+		s.Unlock()
+		switch {
+		case closed:
+			delete(c.sessions, id)
+			if s.opened == w.gen {
+				if err := w.frame(relayCloseFrame, s.id); err != nil {
+					return err
+				}
+			}
+		case overflow && s.opened == w.gen:
+			// The core starts the session over with the state replayed
+			if err := w.frame(relayCloseFrame, s.id); err != nil {
+				return err
+			}
+			if err := w.open(s); err != nil {
+				return err
+			}
+		case s.opened != w.gen:
+			if err := w.open(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *relayClient) run() {
+	var w *relayWriter
+	var gen uint64
+	var pending *relayFrame
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if w == nil {
+			gen++
+			if w = c.connect(gen); w == nil {
+				return
+			}
+			// All sessions are opened on the new connection
+			c.dirty.Store(true)
+		}
+		err := w.conn.SetWriteDeadline(time.Now().Add(interceptTimeout))
+		if err == nil {
+			err = c.sync(w)
+		}
+		if err == nil && pending == nil {
+			select {
+			case f := <-c.queue:
+				pending = &f
+			case <-ticker.C:
+			case <-c.stop:
+				_ = w.conn.Close()
+				return
+			}
+		}
+		if err == nil && pending != nil {
+			if err = c.write(w, pending); err == nil {
+				pending = nil
+			}
+		}
+		if err == nil && len(c.queue) == 0 {
+			err = w.flush()
+		}
+		if err != nil {
+			glog.Errorf("fail to write to relay core %s with error: %+v", c.address, err)
+			_ = w.conn.Close()
+			w = nil
+		}
+	}
+}
+
+// write writes a frame unless its session lost messages
+func (c *relayClient) write(w *relayWriter, f *relayFrame) error {
+	s := f.session
+	if f.close {
+		c.sessionsMu.Lock()
+		delete(c.sessions, s.id)
+		c.sessionsMu.Unlock()
+		if s.opened == w.gen {
+			return w.frame(relayCloseFrame, s.id)
+		}
+		return nil
+	}
+	s.Lock()
+	stale := s.closed || s.overflow || s.epoch != f.epoch
+	s.Unlock()
+	if stale {
+		return nil
+	}
+	if s.opened != w.gen {
+		// The session's state replayed on a new connection includes messages delivered before
+		if err := w.open(s); err != nil {
+			return err
+		}
+	}
+	if err := w.data(s, f.ts, f.b); err != nil {
+		return err
+	}
+	s.delivered.apply(f.b)
+	return nil
+}
+
+// relayServer accepts connections from edge collectors, relayed sessions are processed the same way
+// as sessions of routers connected directly. Addresses of edge collectors are checked against the allow
+// and deny lists, relayed sessions count towards the maximum number of sessions.
+func (srv *bmpServer) relayServer(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-srv.stop:
+				return
+			default:
+			}
+			glog.Errorf("fail to accept relay connection with error: %+v", err)
+			continue
+		}
+		if err := srv.admission.allowed(conn.RemoteAddr()); err != nil {
+			glog.Warningf("rejecting relay connection from %+v: %+v, rejected so far: %+v", conn.RemoteAddr(), err, srv.admission.stats())
+			_ = conn.Close()
+			continue
+		}
+		glog.Infof("relay connection from %+v accepted", conn.RemoteAddr())
+		go srv.relayWorker(conn)
+	}
+}
+
+// relayedSession is a BMP session relayed to the core collector, messages of the session are queued
+// so that a session which is not keeping up does not hold up other sessions relayed over the same
+// connection.
+type relayedSession struct {
+	router string
+	queue  chan *messageBuffer
+	// done is closed when all messages of the session are processed
+	done chan struct{}
+	// state is the state of the session as received from the edge collector, replayed when the session
+	// is restarted after it lost messages
+	state    *replayState
+	overflow bool
+}
+
+// relayedRouters keeps the last relayed session of each router, across relay connections
+type relayedRouters struct {
+	sync.Mutex
+	last map[string]*relayedSession
+}
+
+// chain registers the session as the last session of its router and returns the previous one, if any
+func (r *relayedRouters) chain(s *relayedSession) *relayedSession {
+	r.Lock()
+	defer r.Unlock()
+	if r.last == nil {
+		r.last = make(map[string]*relayedSession)
+	}
+	prev := r.last[s.router]
+	r.last[s.router] = s
+	return prev
+}
+
+// remove removes the session unless a later session of its router is registered
+func (r *relayedRouters) remove(s *relayedSession) {
+	r.Lock()
+	defer r.Unlock()
+	if r.last[s.router] == s {
+		delete(r.last, s.router)
+	}
+}
+
+// openRelayed starts processing of the relayed session of the router with the replayed messages queued,
+// state is the state of the restarted session or nil for a new session. The session is processed after
+// the previous session of the router, if any, is done, whether it was relayed over the same connection
+// or not.
+func (srv *bmpServer) openRelayed(router string, state *replayState, replay [][]byte) *relayedSession {
+	s := &relayedSession{
+		router: router,
+		queue:  make(chan *messageBuffer, srv.queueSize+len(replay)),
+		done:   make(chan struct{}),
+		state:  state,
+	}
+	if s.state == nil {
+		s.state = newReplayState()
+	}
+	prev := srv.relayed.chain(s)
+	for _, b := range replay {
+		mb := getMessageBuffer(len(b))
+		copy(mb.b, b)
+		mb.ts = time.Now()
+		s.queue <- mb
+	}
+	next := func() (*messageBuffer, error) {
+		mb, ok := <-s.queue
+		if !ok {
+			return nil, io.EOF
+		}
+		return mb, nil
+	}
+	go func() {
+		defer close(s.done)
+		if prev != nil {
+			// Peer Down messages of the previous session are published before Peer Up messages of this one
+			<-prev.done
+		}
+		// Admission control and rate limits are applied by the edge collector the router is connected to
+		srv.serveSession(router, "", next, nil)
+		srv.relayed.remove(s)
+	}()
+
+	return s
+}
+
+// deliver queues the message of the session, it never blocks, when the queue is full the session drops
+// messages until the queue is drained, the session is then restarted with its state replayed.
+func (srv *bmpServer) deliver(s *relayedSession, mb *messageBuffer) *relayedSession {
+	switch mb.b[5] {
+	case bmp.InitiationMsg, bmp.PeerUpMsg:
+		// The state retains the message, a copy is kept as the buffer is released once processed
+		msg := make([]byte, len(mb.b))
+		copy(msg, mb.b)
+		s.state.apply(msg)
+	default:
+		s.state.apply(mb.b)
+	}
+	if s.overflow {
+		if len(s.queue) != 0 {
+			mb.release()
+			return s
+		}
+		glog.Warningf("restarting relayed session of router %s after it lost messages", s.router)
+		close(s.queue)
+		// The current message is a part of the replayed state or lost with the others
+		mb.release()
+		return srv.openRelayed(s.router, s.state, s.state.messages())
+	}
+	select {
+	case s.queue <- mb:
+	default:
+		s.overflow = true
+		mb.release()
+		glog.Warningf("relayed session of router %s is not keeping up, queue of %d messages is full, dropping messages", s.router, cap(s.queue))
+	}
+	return s
+}
+
+func (srv *bmpServer) relayWorker(conn net.Conn) {
+	sessions := make(map[uint32]*relayedSession)
+	defer func() {
+		_ = conn.Close()
+		// Sessions relayed over the connection are lost
+		for _, s := range sessions {
+			close(s.queue)
+			srv.admission.release()
+		}
+	}()
+	if err := srv.readRelay(conn, sessions); err != nil && !errors.Is(err, io.EOF) {
+		glog.Errorf("relay connection from %+v failed with error: %+v", conn.RemoteAddr(), err)
+	}
+}
+
+func (srv *bmpServer) readRelay(conn net.Conn, sessions map[uint32]*relayedSession) error {
+	r := bufio.NewReaderSize(conn, 64*1024)
+	magic := make([]byte, len(relayMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic) != string(relayMagic) {
+		return fmt.Errorf("invalid relay protocol header")
+	}
+	fr := bufio.NewReaderSize(flate.NewReader(r), 64*1024)
+	h := make([]byte, 17)
+	for {
+		if _, err := io.ReadFull(fr, h[:5]); err != nil {
+			return err
+		}
+		id := binary.BigEndian.Uint32(h[1:5])
+		switch h[0] {
+		case relayOpenFrame:
+			if _, err := io.ReadFull(fr, h[5:7]); err != nil {
+				return err
+			}
+			addr := make([]byte, binary.BigEndian.Uint16(h[5:7]))
+			if _, err := io.ReadFull(fr, addr); err != nil {
+				return err
+			}
+			if s, ok := sessions[id]; ok {
+				close(s.queue)
+				delete(sessions, id)
+				srv.admission.release()
+			}
+			if err := srv.admission.acquire(); err != nil {
+				// Messages of the session are discarded
+				glog.Warningf("rejecting relayed session %d of router %s from %+v: %+v, rejected so far: %+v", id, string(addr), conn.RemoteAddr(), err, srv.admission.stats())
+				continue
+			}
+			glog.Infof("relayed session %d of router %s from %+v", id, string(addr), conn.RemoteAddr())
+			sessions[id] = srv.openRelayed(string(addr), nil, nil)
+		case relayDataFrame:
+			if _, err := io.ReadFull(fr, h[5:17]); err != nil {
+				return err
+			}
+			l := binary.BigEndian.Uint32(h[13:17])
+			if l < bmp.CommonHeaderLength || l > maxMessageLength {
+				return fmt.Errorf("invalid relayed message length %d", l)
+			}
+			mb := getMessageBuffer(int(l))
+			if _, err := io.ReadFull(fr, mb.b); err != nil {
+				mb.release()
+				return err
+			}
+			if mb.b[0] != 3 || binary.BigEndian.Uint32(mb.b[1:5]) != l {
+				mb.release()
+				return fmt.Errorf("invalid common header of relayed message of session %d", id)
+			}
+			s, ok := sessions[id]
+			if !ok {
+				mb.release()
+				continue
+			}
+			// The message is processed as received by the edge collector
+			mb.ts = time.Unix(0, int64(binary.BigEndian.Uint64(h[5:13])))
+			if glog.V(6) {
+				glog.Infof("relayed message of session %d received by the edge %s ago", id, time.Since(mb.ts))
+			}
+			sessions[id] = srv.deliver(s, mb)
+		case relayCloseFrame:
+			if s, ok := sessions[id]; ok {
+				close(s.queue)
+				delete(sessions, id)
+				srv.admission.release()
+			}
+		default:
+			return fmt.Errorf("invalid relay frame type %d", h[0])
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package gobmpsrv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/message"
+)
+
+// newTestRelay returns edge and core collectors, the edge relays sessions to the core listening on addr
+func newTestRelay(t *testing.T, addr string, core *recorder) (*bmpServer, *bmpServer, func()) {
+	stop := make(chan struct{})
+	edge := newTestServer(nil, &Config{})
+	edge.stop = stop
+	edge.relay = newRelayClient(addr, nil, DefaultRelayBufferSize, 10*time.Millisecond, 10*time.Millisecond, stop)
+	go edge.relay.run()
+	srv := newTestServer(core, &Config{})
+	srv.stop = stop
+	return edge, srv, func() { close(stop) }
+}
+
+func TestRelay(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start relay listener: %+v", err)
+	}
+	defer l.Close()
+	pub := &recorder{}
+	edge, core, stop := newTestRelay(t, l.Addr().String(), pub)
+	defer stop()
+	go core.relayServer(l)
+
+	// Router 1 session is lost without Peer Down messages, router 2 session is complete
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		edge.bmpWorker(&pipeConn{Conn: client, remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000}})
+		close(done)
+	}()
+	stream := initiationMessage()
+	for p := 0; p < 2; p++ {
+		stream = append(stream, peerUpMessage(p)...)
+		stream = append(stream, routeMonitorMessage(p, 0)...)
+	}
+	if _, err := conn.Write(stream); err != nil {
+		t.Fatalf("failed to write BMP stream: %+v", err)
+	}
+	if err := runSession(edge, 2, routerStream(2, 10)); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	// The core processes the relayed session as if the router connected directly
+	waitFor(t, func() bool {
+		for _, c := range core.GetClients() {
+			if c.Address == "192.0.2.1:50000" {
+				return true
+			}
+		}
+		return false
+	})
+	_ = conn.Close()
+	<-done
+	// Router 1: 1 router, 2 peers up, 2 routes and 2 collector generated peers down, router 2: 1 router, 2 peers up,
+	// 20 routes and 2 peers down
+	expect := 7 + 25
+	waitFor(t, func() bool {
+		pub.Lock()
+		defer pub.Unlock()
+		return len(pub.msgs) >= expect
+	})
+	pub.Lock()
+	defer pub.Unlock()
+	if len(pub.msgs) != expect {
+		t.Fatalf("expected %d messages got %d", expect, len(pub.msgs))
+	}
+	generated := 0
+	for _, m := range pub.msgs {
+		if m.t != bmp.PeerStateChangeMsg {
+			continue
+		}
+		var p message.PeerStateChange
+		if err := json.Unmarshal(m.msg, &p); err != nil {
+			t.Fatalf("failed to unmarshal peer message: %+v", err)
+		}
+		if p.CollectorGenerated {
+			generated++
+		}
+	}
+	if generated != 2 {
+		t.Errorf("expected 2 collector generated peer down messages got %d", generated)
+	}
+}
+
+// gatedRecorder holds up publishing of messages containing router until gate is closed
+type gatedRecorder struct {
+	recorder
+	router []byte
+	gate   chan struct{}
+}
+
+func (r *gatedRecorder) PublishMessage(t int, key []byte, msg []byte) error {
+	if bytes.Contains(msg, r.router) {
+		<-r.gate
+	}
+	return r.recorder.PublishMessage(t, key, msg)
+}
+
+func TestRelaySlowSession(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start relay listener: %+v", err)
+	}
+	defer l.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	edge := newTestServer(nil, &Config{})
+	edge.stop = stop
+	edge.relay = newRelayClient(l.Addr().String(), nil, DefaultRelayBufferSize, 10*time.Millisecond, 10*time.Millisecond, stop)
+	go edge.relay.run()
+	pub := &gatedRecorder{router: []byte(`"192.0.2.1"`), gate: make(chan struct{})}
+	defer close(pub.gate)
+	core := newTestServer(pub, &Config{QueueSize: 32})
+	core.stop = stop
+	go core.relayServer(l)
+
+	// Messages of router 1 are not published, router 2 relayed over the same connection is not held up
+	if err := runSession(edge, 1, routerStream(2, 200)); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	if err := runSession(edge, 2, routerStream(2, 10)); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	// Router 2: 1 router, 2 peers up, 20 routes and 2 peers down
+	waitFor(t, func() bool {
+		pub.Lock()
+		defer pub.Unlock()
+		return len(pub.msgs) >= 25
+	})
+}
+
+// delayedRecorder delays publishing of messages containing delay
+type delayedRecorder struct {
+	recorder
+	delay []byte
+}
+
+func (r *delayedRecorder) PublishMessage(t int, key []byte, msg []byte) error {
+	if bytes.Contains(msg, r.delay) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	return r.recorder.PublishMessage(t, key, msg)
+}
+
+func TestRelayRestartedSession(t *testing.T) {
+	// Publishing of collector generated Peer Down messages is delayed, they still precede Peer Up messages
+	// of the restarted session
+	pub := &delayedRecorder{delay: []byte(`"collector_generated":true`)}
+	core := newTestServer(pub, &Config{})
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		core.relayWorker(conn)
+		close(done)
+	}()
+	if _, err := client.Write(relayMagic); err != nil {
+		t.Fatalf("failed to write relay protocol header: %+v", err)
+	}
+	fw, _ := flate.NewWriter(client, flate.BestSpeed)
+	w := &relayWriter{conn: client, fw: fw, w: bufio.NewWriter(fw), gen: 1}
+	router := "192.0.2.1:50000"
+	s := &relaySession{id: 1, router: router, delivered: newReplayState()}
+	if err := w.open(s); err != nil {
+		t.Fatalf("failed to open relayed session: %+v", err)
+	}
+	for _, m := range [][]byte{initiationMessage(), peerUpMessage(0)} {
+		if err := w.data(s, time.Now(), m); err != nil {
+			t.Fatalf("failed to relay message: %+v", err)
+		}
+		s.delivered.apply(m)
+	}
+	// The edge restarts the session with its state replayed, as it does after the session lost messages
+	if err := w.frame(relayCloseFrame, s.id); err != nil {
+		t.Fatalf("failed to close relayed session: %+v", err)
+	}
+	if err := w.open(s); err != nil {
+		t.Fatalf("failed to open relayed session: %+v", err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatalf("failed to flush relay connection: %+v", err)
+	}
+	// Session 1: 1 router, 1 peer up and 1 collector generated peer down, session 2: 1 router and 1 peer up
+	expect := 5
+	waitFor(t, func() bool {
+		pub.Lock()
+		defer pub.Unlock()
+		return len(pub.msgs) >= expect
+	})
+	registered := 0
+	for _, c := range core.GetClients() {
+		if c.Address == router {
+			registered++
+		}
+	}
+	if registered != 1 {
+		t.Errorf("expected router %s registered once got %d", router, registered)
+	}
+	_ = client.Close()
+	<-done
+	pub.Lock()
+	defer pub.Unlock()
+	states := make([]string, 0)
+	for _, m := range pub.msgs {
+		if m.t != bmp.PeerStateChangeMsg {
+			continue
+		}
+		var p message.PeerStateChange
+		if err := json.Unmarshal(m.msg, &p); err != nil {
+			t.Fatalf("failed to unmarshal peer message: %+v", err)
+		}
+		states = append(states, p.Action)
+	}
+	if len(states) < 3 || states[0] != "add" || states[1] != "down" || states[2] != "add" {
+		t.Errorf("expected peer up, down and up of the restarted session got %+v", states)
+	}
+}
+
+func TestRelayCoreDown(t *testing.T) {
+	addr := unusedAddress(t)
+	pub := &recorder{}
+	edge, core, stop := newTestRelay(t, addr, pub)
+	defer stop()
+	// Messages are buffered by the edge while the core is not reachable
+	if err := runSession(edge, 1, routerStream(2, 10)); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	listening := time.Now()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to start relay listener: %+v", err)
+	}
+	defer l.Close()
+	go core.relayServer(l)
+	waitFor(t, func() bool {
+		pub.Lock()
+		defer pub.Unlock()
+		return len(pub.msgs) >= 25
+	})
+	// Messages are published with the time they were received by the edge
+	pub.Lock()
+	defer pub.Unlock()
+	for _, m := range pub.msgs {
+		if m.t != bmp.RouterMsg {
+			continue
+		}
+		var r message.Router
+		if err := json.Unmarshal(m.msg, &r); err != nil {
+			t.Fatalf("failed to unmarshal router message: %+v", err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, r.Timestamp)
+		if err != nil {
+			t.Fatalf("failed to parse timestamp of router message: %+v", err)
+		}
+		if !ts.Before(listening) {
+			t.Errorf("expected router message received before the core was started at %s, got %s", listening, ts)
+		}
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	config := &Config{
+		RelayTo:          unusedAddress(t),
+		ActiveBackoffMin: 100 * time.Millisecond,
+		ActiveBackoffMax: 200 * time.Millisecond,
+	}
+	s, err := NewBMPServer(0, 0, false, nil, false, false, config)
+	if err != nil {
+		t.Fatalf("failed to create BMP server: %+v", err)
+	}
+	srv := s.(*bmpServer)
+	defer srv.Stop()
+	var mu sync.Mutex
+	attempts := make([]time.Time, 0)
+	dial := srv.relay.dial
+	srv.relay.dial = func() (net.Conn, error) {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		mu.Unlock()
+		return dial()
+	}
+	go srv.relay.run()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(attempts) >= 3
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 1; i < len(attempts); i++ {
+		if d := attempts[i].Sub(attempts[i-1]); d < config.ActiveBackoffMin {
+			t.Fatalf("attempt %d to connect to the core followed the previous one after %s, expected at least %s", i, d, config.ActiveBackoffMin)
+		}
+	}
+}
+
+func TestRelayAdmission(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		expect int
+		stats  AdmissionStats
+	}{
+		{
+			name:   "edge denied",
+			config: &Config{DeniedClients: []string{"127.0.0.0/8"}},
+			stats:  AdmissionStats{Denied: 1},
+		},
+		{
+			// Router 1: 1 router, 1 peer up and 1 collector generated peer down, the session of router 2
+			// is rejected
+			name:   "maximum sessions",
+			config: &Config{MaxSessions: 1},
+			expect: 3,
+			stats:  AdmissionStats{SessionLimit: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to start relay listener: %+v", err)
+			}
+			defer l.Close()
+			pub := &recorder{}
+			edge, _, stop := newTestRelay(t, l.Addr().String(), nil)
+			defer stop()
+			core := newTestServer(pub, tt.config)
+			core.stop = make(chan struct{})
+			defer close(core.stop)
+			go core.relayServer(l)
+
+			// Session of router 1 stays open while router 2 connects
+			client, conn := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				edge.bmpWorker(&pipeConn{Conn: client, remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000}})
+				close(done)
+			}()
+			if _, err := conn.Write(append(initiationMessage(), peerUpMessage(0)...)); err != nil {
+				t.Fatalf("failed to write BMP stream: %+v", err)
+			}
+			if err := runSession(edge, 2, routerStream(2, 10)); err != nil {
+				t.Fatalf("failed to run BMP session: %+v", err)
+			}
+			// The edge keeps reconnecting when the connection is rejected
+			waitFor(t, func() bool {
+				s := core.admission.stats()
+				return s.Denied >= tt.stats.Denied && s.SessionLimit >= tt.stats.SessionLimit
+			})
+			_ = conn.Close()
+			<-done
+			if tt.expect != 0 {
+				waitFor(t, func() bool {
+					pub.Lock()
+					defer pub.Unlock()
+					return len(pub.msgs) >= tt.expect
+				})
+			}
+			// Messages of rejected sessions are not published
+			time.Sleep(100 * time.Millisecond)
+			pub.Lock()
+			defer pub.Unlock()
+			if len(pub.msgs) != tt.expect {
+				t.Errorf("expected %d messages got %d", tt.expect, len(pub.msgs))
+			}
+		})
+	}
+}
+
+func TestRelayListenerTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert, serverKey := pki.issue("localhost", x509.ExtKeyUsageServerAuth)
+	edgeCert, edgeKey := pki.issue("edge1", x509.ExtKeyUsageClientAuth)
+	serverTLS, err := NewServerTLSConfig(serverCert, serverKey, "")
+	if err != nil {
+		t.Fatalf("failed to build server TLS config: %+v", err)
+	}
+	mutualTLS, err := NewServerTLSConfig(serverCert, serverKey, pki.caFile())
+	if err != nil {
+		t.Fatalf("failed to build server TLS config: %+v", err)
+	}
+	addr := unusedAddress(t)
+	_, port, _ := net.SplitHostPort(addr)
+	relayPort, _ := strconv.Atoi(port)
+	tests := []struct {
+		name string
+		tls  *tls.Config
+		fail bool
+	}{
+		{
+			name: "without tls",
+			fail: true,
+		},
+		{
+			name: "tls without client authentication",
+			tls:  serverTLS,
+			fail: true,
+		},
+		{
+			name: "mutual tls",
+			tls:  mutualTLS,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recorder{}
+			s, err := NewBMPServer(0, 0, false, pub, true, false, &Config{RelayPort: relayPort, RelayListenerTLS: tt.tls})
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("failed to create BMP server: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				s.Stop()
+				t.Fatal("expected BMP server without mutual TLS of the relay listener to fail")
+			}
+			core := s.(*bmpServer)
+			core.Start()
+			defer core.Stop()
+			clientTLS, err := NewClientTLSConfig(pki.caFile(), edgeCert, edgeKey, "localhost")
+			if err != nil {
+				t.Fatalf("failed to build client TLS config: %+v", err)
+			}
+			stop := make(chan struct{})
+			defer close(stop)
+			edge := newTestServer(nil, &Config{})
+			edge.stop = stop
+			edge.relay = newRelayClient(addr, clientTLS, DefaultRelayBufferSize, 10*time.Millisecond, 10*time.Millisecond, stop)
+			go edge.relay.run()
+			if err := runSession(edge, 1, routerStream(2, 10)); err != nil {
+				t.Fatalf("failed to run BMP session: %+v", err)
+			}
+			waitFor(t, func() bool {
+				pub.Lock()
+				defer pub.Unlock()
+				return len(pub.msgs) >= 25
+			})
+		})
+	}
+}
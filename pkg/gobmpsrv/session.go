@@ -0,0 +1,53 @@
+package gobmpsrv
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Reasons published in collector generated Peer Down messages when a BMP session is lost
+const (
+	reasonSessionClosed = "BMP session closed by the router"
+	reasonIdleTimeout   = "BMP session idle timeout"
+	reasonSessionError  = "BMP session error"
+	reasonRateLimit     = "BMP session closed by the collector, rate limit exceeded"
+)
+
+// setKeepAlive enables TCP keepalive with the period on the client connection, TLS connections
+// are unwrapped to the underlying TCP connection.
+func setKeepAlive(client net.Conn, period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	conn := client
+	if c, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = c.NetConn()
+	}
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if err := tcp.SetKeepAlive(true); err != nil {
+		glog.Warningf("failed to enable TCP keepalive for client %+v with error: %+v", client.RemoteAddr(), err)
+		return
+	}
+	if err := tcp.SetKeepAlivePeriod(period); err != nil {
+		glog.Warningf("failed to set TCP keepalive period for client %+v with error: %+v", client.RemoteAddr(), err)
+	}
+}
+
+// readErrorReason converts an error reading from the client into the reason of the session loss
+func readErrorReason(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, io.EOF):
+		return reasonSessionClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return reasonIdleTimeout
+	}
+	return reasonSessionError + ": " + err.Error()
+}
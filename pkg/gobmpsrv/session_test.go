@@ -0,0 +1,92 @@
+package gobmpsrv
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/message"
+)
+
+func TestCollectorGeneratedPeerDown(t *testing.T) {
+	// Peers 0 and 1 go up, only peer 1 goes down before the session is lost
+	stream := initiationMessage()
+	stream = append(stream, peerUpMessage(0)...)
+	stream = append(stream, peerUpMessage(1)...)
+	stream = append(stream, routeMonitorMessage(0, 0)...)
+	stream = append(stream, peerDownMessage(1)...)
+	tests := []struct {
+		name   string
+		config *Config
+		close  bool
+		reason string
+	}{
+		{
+			name:   "session closed by router",
+			config: &Config{},
+			close:  true,
+			reason: reasonSessionClosed,
+		},
+		{
+			name:   "idle timeout",
+			config: &Config{IdleTimeout: 50 * time.Millisecond},
+			reason: reasonIdleTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &recorder{}
+			srv := newTestServer(pub, tt.config)
+			client, conn := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				srv.bmpWorker(&pipeConn{
+					Conn:   client,
+					remote: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 50000},
+				})
+				close(done)
+			}()
+			if _, err := conn.Write(stream); err != nil {
+				t.Fatalf("failed to write BMP stream: %+v", err)
+			}
+			if tt.close {
+				_ = conn.Close()
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("BMP session is not closed")
+			}
+			_ = conn.Close()
+
+			var downs []message.PeerStateChange
+			for _, m := range pub.msgs {
+				if m.t != bmp.PeerStateChangeMsg {
+					continue
+				}
+				var p message.PeerStateChange
+				if err := json.Unmarshal(m.msg, &p); err != nil {
+					t.Fatalf("failed to unmarshal peer message: %+v", err)
+				}
+				if p.Action == "down" {
+					downs = append(downs, p)
+				}
+			}
+			if len(downs) != 2 {
+				t.Fatalf("expected 2 peer down messages got %d", len(downs))
+			}
+			if downs[0].CollectorGenerated || downs[0].RemoteIP != "10.0.0.1" {
+				t.Errorf("expected peer down of peer 10.0.0.1 from the router, got %+v", downs[0])
+			}
+			synthetic := downs[1]
+			if !synthetic.CollectorGenerated || synthetic.RemoteIP != "10.0.0.0" || synthetic.ErrorText != tt.reason {
+				t.Errorf("expected collector generated peer down of peer 10.0.0.0 with reason %q, got %+v", tt.reason, synthetic)
+			}
+			if synthetic.RouterIP == "" || synthetic.RouterHash == "" || synthetic.RemoteASN == 0 {
+				t.Errorf("collector generated peer down is missing router or peer information: %+v", synthetic)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package gobmpsrv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// tlsHandshakeTimeout defines the time allowed for a router to complete TLS handshake
+const tlsHandshakeTimeout = 10 * time.Second
+
+// NewServerTLSConfig builds TLS configuration of BMP listener from PEM encoded certificate and key files,
+// when clientCAFile is not empty, routers must present a certificate signed by one of the CAs found in the file.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate %s and key %s with error: %w", certFile, keyFile, err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// NewClientTLSConfig builds TLS configuration of the connection to the intercept destination, when caFile is empty
+// system CAs are used to verify destination's certificate, when certFile and keyFile are not empty, the certificate
+// is presented to the destination.
+func NewClientTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate %s and key %s with error: %w", certFile, keyFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s with error: %w", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no valid certificates found in CA file %s", file)
+	}
+
+	return pool, nil
+}
+
+// routerIdentity completes TLS handshake of a TLS client connection and returns the subject of the verified
+// client certificate, for connections without TLS or without verified client certificate, empty string is returned.
+func routerIdentity(client net.Conn) (string, error) {
+	conn, ok := client.(*tls.Conn)
+	if !ok {
+		return "", nil
+	}
+	if err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout)); err != nil {
+		return "", err
+	}
+	if err := conn.Handshake(); err != nil {
+		return "", fmt.Errorf("tls handshake failed with error: %w", err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return "", err
+	}
+	chains := conn.ConnectionState().VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return "", nil
+	}
+
+	return chains[0][0].Subject.String(), nil
+}
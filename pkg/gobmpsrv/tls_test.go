@@ -0,0 +1,255 @@
+package gobmpsrv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/message"
+)
+
+// testPKI keeps a locally generated CA and writes certificates signed by it into dir
+type testPKI struct {
+	t      *testing.T
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %+v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gobmp test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %+v", err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %+v", err)
+	}
+	p := &testPKI{t: t, dir: t.TempDir(), ca: ca, caKey: key, serial: 1}
+	p.write("ca.pem", "CERTIFICATE", der)
+
+	return p
+}
+
+func (p *testPKI) write(name, blockType string, b []byte) string {
+	file := filepath.Join(p.dir, name)
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: b}), 0600); err != nil {
+		p.t.Fatalf("failed to write %s: %+v", file, err)
+	}
+	return file
+}
+
+// issue writes a certificate with the given common name and its key, and returns the files' names
+func (p *testPKI) issue(cn string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatalf("failed to generate key: %+v", err)
+	}
+	p.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if usage == x509.ExtKeyUsageServerAuth {
+		tmpl.DNSNames = []string{"localhost"}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		p.t.Fatalf("failed to create certificate: %+v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		p.t.Fatalf("failed to marshal key: %+v", err)
+	}
+
+	return p.write(cn+".pem", "CERTIFICATE", der), p.write(cn+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (p *testPKI) caFile() string {
+	return filepath.Join(p.dir, "ca.pem")
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition is not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTLSListener(t *testing.T) {
+	pki := newTestPKI(t)
+	serverCert, serverKey := pki.issue("localhost", x509.ExtKeyUsageServerAuth)
+	routerCert, routerKey := pki.issue("router1", x509.ExtKeyUsageClientAuth)
+	stream := routerStream(2, 10)
+	tests := []struct {
+		name     string
+		clientCA string
+		cert     string
+		key      string
+		identity string
+		messages int
+		rejected bool
+	}{
+		{
+			name:     "tls without client authentication",
+			messages: 1 + 2 + 20 + 2,
+		},
+		{
+			name:     "mutual tls",
+			clientCA: pki.caFile(),
+			cert:     routerCert,
+			key:      routerKey,
+			identity: "CN=router1",
+			messages: 1 + 2 + 20 + 2,
+		},
+		{
+			name:     "mutual tls without client certificate",
+			clientCA: pki.caFile(),
+			rejected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConfig, err := NewServerTLSConfig(serverCert, serverKey, tt.clientCA)
+			if err != nil {
+				t.Fatalf("failed to build server TLS config: %+v", err)
+			}
+			pub := &recorder{}
+			s, err := NewBMPServer(0, 0, false, pub, true, false, &Config{TLS: serverConfig})
+			if err != nil {
+				t.Fatalf("failed to create BMP server: %+v", err)
+			}
+			srv := s.(*bmpServer)
+			srv.Start()
+			defer srv.Stop()
+
+			clientConfig, err := NewClientTLSConfig(pki.caFile(), tt.cert, tt.key, "localhost")
+			if err != nil {
+				t.Fatalf("failed to build client TLS config: %+v", err)
+			}
+			conn, err := tls.Dial("tcp", srv.incoming.Addr().String(), clientConfig)
+			if err != nil {
+				t.Fatalf("failed to connect: %+v", err)
+			}
+			_, _ = conn.Write(stream)
+			if tt.rejected {
+				// The collector aborts the session, reading returns the alert sent by the collector
+				if _, err := io.ReadAll(conn); err == nil {
+					t.Errorf("expected the session to be rejected")
+				}
+				_ = conn.Close()
+				waitFor(t, func() bool { return len(srv.GetClients()) == 0 })
+			} else {
+				_ = conn.Close()
+				waitFor(t, func() bool {
+					pub.Lock()
+					defer pub.Unlock()
+					return len(pub.msgs) >= tt.messages
+				})
+			}
+			pub.Lock()
+			defer pub.Unlock()
+			if len(pub.msgs) != tt.messages {
+				t.Fatalf("expected %d messages got %d", tt.messages, len(pub.msgs))
+			}
+			for _, m := range pub.msgs {
+				if m.t != bmp.PeerStateChangeMsg {
+					continue
+				}
+				var p message.PeerStateChange
+				if err := json.Unmarshal(m.msg, &p); err != nil {
+					t.Fatalf("failed to unmarshal peer message: %+v", err)
+				}
+				if p.RouterIdentity != tt.identity {
+					t.Errorf("expected router identity %q got %q", tt.identity, p.RouterIdentity)
+				}
+			}
+		})
+	}
+}
+
+func TestTLSIntercept(t *testing.T) {
+	pki := newTestPKI(t)
+	destCert, destKey := pki.issue("localhost", x509.ExtKeyUsageServerAuth)
+	collectorCert, collectorKey := pki.issue("collector", x509.ExtKeyUsageClientAuth)
+	destConfig, err := NewServerTLSConfig(destCert, destKey, pki.caFile())
+	if err != nil {
+		t.Fatalf("failed to build destination TLS config: %+v", err)
+	}
+	dest, err := tls.Listen("tcp", "127.0.0.1:0", destConfig)
+	if err != nil {
+		t.Fatalf("failed to start destination listener: %+v", err)
+	}
+	defer dest.Close()
+	_, port, _ := net.SplitHostPort(dest.Addr().String())
+	dPort, _ := strconv.Atoi(port)
+	interceptConfig, err := NewClientTLSConfig(pki.caFile(), collectorCert, collectorKey, "localhost")
+	if err != nil {
+		t.Fatalf("failed to build intercept TLS config: %+v", err)
+	}
+	s, err := NewBMPServer(0, dPort, true, &recorder{}, true, false, &Config{InterceptTLS: interceptConfig})
+	if err != nil {
+		t.Fatalf("failed to create BMP server: %+v", err)
+	}
+	srv := s.(*bmpServer)
+	defer srv.Stop()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := dest.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+	stream := routerStream(1, 5)
+	if err := runSession(srv, 1, stream); err != nil {
+		t.Fatalf("failed to run BMP session: %+v", err)
+	}
+	select {
+	case b := <-received:
+		if string(b) != string(stream) {
+			t.Errorf("destination received %d bytes, expected %d", len(b), len(stream))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("destination did not receive intercepted messages")
+	}
+}
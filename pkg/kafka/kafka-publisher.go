@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -37,6 +38,9 @@ const (
 	FlowspecMessageV4Topic = "gobmp.parsed.flowspec_v4"
 	FlowspecMessageV6Topic = "gobmp.parsed.flowspec_v6"
 	StatsMessageTopic      = "gobmp.parsed.statistics"
+	RouterTopic            = "gobmp.parsed.router"
+	RouteMirrorTopic       = "gobmp.parsed.mirroring"
+	BMPRawTopic            = "gobmp.bmp_raw"
 )
 
 var (
@@ -67,59 +71,105 @@ var (
 		FlowspecMessageV4Topic,
 		FlowspecMessageV6Topic,
 		StatsMessageTopic,
+		RouterTopic,
+		RouteMirrorTopic,
+		BMPRawTopic,
 	}
 )
 
 type publisher struct {
 	broker   *sarama.Broker
 	config   *sarama.Config
+	kConfig  *Config
 	producer sarama.AsyncProducer
 	stopCh   chan struct{}
+	sync.Mutex
+	// tableTopics keeps the names of table topics which are known to exist
+	tableTopics map[string]struct{}
 }
 
 func (p *publisher) PublishMessage(t int, key []byte, msg []byte) error {
+	topic := topicName(t)
+	if topic == "" {
+		return fmt.Errorf("not implemented")
+	}
+
+	return p.produceMessage(topic, key, msg)
+}
+
+// PublishTableMessage publishes a route message of a Loc-RIB instance to the topic dedicated to the table,
+// the topic is created when the first message of the table is published.
+func (p *publisher) PublishTableMessage(t int, table string, key []byte, msg []byte) error {
+	topic := topicName(t)
+	if topic == "" {
+		return fmt.Errorf("not implemented")
+	}
+	topic = pub.TableTopic(topic, table)
+	p.Lock()
+	_, ok := p.tableTopics[topic]
+	p.Unlock()
+	if !ok {
+		if err := ensureTopic(p.broker, topicCreateTimeout, topic, p.kConfig); err != nil {
+			return fmt.Errorf("failed to ensure table topic %s with error: %w", topic, err)
+		}
+		p.Lock()
+		p.tableTopics[topic] = struct{}{}
+		p.Unlock()
+	}
+
+	return p.produceMessage(topic, key, msg)
+}
+
+// topicName returns the name of the topic of message type t, or empty string if the type is not supported
+func topicName(t int) string {
 	switch t {
 	case bmp.PeerStateChangeMsg:
-		return p.produceMessage(PeerTopic, key, msg)
+		return PeerTopic
 	case bmp.UnicastPrefixMsg:
-		return p.produceMessage(UnicastMessageTopic, key, msg)
+		return UnicastMessageTopic
 	case bmp.UnicastPrefixV4Msg:
-		return p.produceMessage(UnicastMessageV4Topic, key, msg)
+		return UnicastMessageV4Topic
 	case bmp.UnicastPrefixV6Msg:
-		return p.produceMessage(UnicastMessageV6Topic, key, msg)
+		return UnicastMessageV6Topic
 	case bmp.LSNodeMsg:
-		return p.produceMessage(LSNodeMessageTopic, key, msg)
+		return LSNodeMessageTopic
 	case bmp.LSLinkMsg:
-		return p.produceMessage(LSLinkMessageTopic, key, msg)
+		return LSLinkMessageTopic
 	case bmp.L3VPNMsg:
-		return p.produceMessage(L3vpnMessageTopic, key, msg)
+		return L3vpnMessageTopic
 	case bmp.L3VPNV4Msg:
-		return p.produceMessage(L3vpnMessageV4Topic, key, msg)
+		return L3vpnMessageV4Topic
 	case bmp.L3VPNV6Msg:
-		return p.produceMessage(L3vpnMessageV6Topic, key, msg)
+		return L3vpnMessageV6Topic
 	case bmp.LSPrefixMsg:
-		return p.produceMessage(LSPrefixMessageTopic, key, msg)
+		return LSPrefixMessageTopic
 	case bmp.LSSRv6SIDMsg:
-		return p.produceMessage(LSSRv6SIDMessageTopic, key, msg)
+		return LSSRv6SIDMessageTopic
 	case bmp.EVPNMsg:
-		return p.produceMessage(EVPNMessageTopic, key, msg)
+		return EVPNMessageTopic
 	case bmp.SRPolicyMsg:
-		return p.produceMessage(SRPolicyMessageTopic, key, msg)
+		return SRPolicyMessageTopic
 	case bmp.SRPolicyV4Msg:
-		return p.produceMessage(SRPolicyMessageV4Topic, key, msg)
+		return SRPolicyMessageV4Topic
 	case bmp.SRPolicyV6Msg:
-		return p.produceMessage(SRPolicyMessageV6Topic, key, msg)
+		return SRPolicyMessageV6Topic
 	case bmp.FlowspecMsg:
-		return p.produceMessage(FlowspecMessageTopic, key, msg)
+		return FlowspecMessageTopic
 	case bmp.FlowspecV4Msg:
-		return p.produceMessage(FlowspecMessageV4Topic, key, msg)
+		return FlowspecMessageV4Topic
 	case bmp.FlowspecV6Msg:
-		return p.produceMessage(FlowspecMessageV6Topic, key, msg)
+		return FlowspecMessageV6Topic
 	case bmp.StatsReportMsg:
-		return p.produceMessage(StatsMessageTopic, key, msg)
+		return StatsMessageTopic
+	case bmp.RouterMsg:
+		return RouterTopic
+	case bmp.RouteMirrorMsg:
+		return RouteMirrorTopic
+	case bmp.BMPRawMsg:
+		return BMPRawTopic
 	}
 
-	return fmt.Errorf("not implemented")
+	return ""
 }
 
 func (p *publisher) produceMessage(topic string, key []byte, msg []byte) error {
@@ -193,10 +243,12 @@ func NewKafkaPublisher(kConfig *Config) (pub.Publisher, error) {
 	}(producer, stopCh)
 
 	return &publisher{
-		stopCh:   stopCh,
-		broker:   br,
-		config:   config,
-		producer: producer,
+		stopCh:      stopCh,
+		broker:      br,
+		config:      config,
+		kConfig:     kConfig,
+		producer:    producer,
+		tableTopics: make(map[string]struct{}),
 	}, nil
 }
 
@@ -10,15 +10,22 @@ import (
 	"github.com/sbezverk/tools"
 )
 
-// UnmarshalL3VPNNLRI instantiates a L3 VPN NLRI object
-func UnmarshalL3VPNNLRI(b []byte, pathID bool, srv6 ...bool) (*base.MPNLRI, error) {
+// UnmarshalL3VPNNLRI instantiates a L3 VPN NLRI object, when multiLabel is true, labels are decoded up to the one
+// with Bottom of Stack bit set, otherwise each prefix carries a single label.
+func UnmarshalL3VPNNLRI(b []byte, pathID, multiLabel bool, srv6 ...bool) (*base.MPNLRI, error) {
 	srv6Flag := false
 	if len(srv6) == 1 {
 		srv6Flag = srv6[0]
 	}
 	if glog.V(6) {
-		glog.Infof("L3VPN NLRI Raw: %s path ID flag: %t srv6 flag: %t ", tools.MessageHex(b), pathID, srv6Flag)
+		glog.Infof("L3VPN NLRI Raw: %s path ID flag: %t multiple labels flag: %t srv6 flag: %t ", tools.MessageHex(b), pathID, multiLabel, srv6Flag)
 	}
+	return unmarshalL3VPNNLRI(b, pathID, multiLabel, srv6Flag, true)
+}
+
+// unmarshalL3VPNNLRI builds L3 VPN NLRI object, when retry is true and the object cannot be built, the opposite
+// value of pathID is tried
+func unmarshalL3VPNNLRI(b []byte, pathID, multiLabel, srv6Flag, retry bool) (*base.MPNLRI, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("NLRI length is 0")
 	}
@@ -38,6 +45,10 @@ func UnmarshalL3VPNNLRI(b []byte, pathID bool, srv6 ...bool) (*base.MPNLRI, erro
 			up.PathID = binary.BigEndian.Uint32(b[p : p+4])
 			p += 4
 		}
+		if p+1 > len(b) {
+			err = fmt.Errorf("not enough bytes to reconstruct l3vpn nlri")
+			goto error_handle
+		}
 		up.Length = b[p]
 		if up.Length <= 0 {
 			err = fmt.Errorf("not enough bytes to reconstruct l3vpn nlri")
@@ -75,7 +86,7 @@ func UnmarshalL3VPNNLRI(b []byte, pathID bool, srv6 ...bool) (*base.MPNLRI, erro
 				}
 				up.Label = append(up.Label, l)
 				p += 3
-				bos = l.BoS
+				bos = l.BoS || !multiLabel
 				if srv6Flag {
 					// When srv6Flag is set, it means 3 bytes of label is not really a label
 					// but a part of Prefix SID, as such, BoS does not exists.
@@ -121,7 +132,10 @@ error_handle:
 		// might be advertised and received, but BGP Update would not have PathID set due to some other conditions,
 		// example when bgp speakers are in different AS. In error handle, attempting to Unmarshal again with reversed
 		// value of PathID flag.
-		if mp, e := UnmarshalL3VPNNLRI(b, !pathID, srv6Flag); e == nil {
+		if !retry {
+			return nil, err
+		}
+		if mp, e := unmarshalL3VPNNLRI(b, !pathID, multiLabel, srv6Flag, false); e == nil {
 			return mp, nil
 		}
 		glog.Errorf("failed to reconstruct l3vpn nlri from slice %s with error: %+v", tools.MessageHex(b), err)
@@ -241,10 +241,21 @@ func TestUnmarshalL3VPNNLRI(t *testing.T) {
 			srv6:   false,
 			pathID: true,
 		},
+		{
+			name:  "truncated with and without path id",
+			input: []byte{0x70, 0, 1},
+			fail:  true,
+		},
+		{
+			name:   "path id without prefix",
+			input:  []byte{0, 0, 0, 1},
+			fail:   true,
+			pathID: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := UnmarshalL3VPNNLRI(tt.input, tt.pathID, tt.srv6)
+			got, err := UnmarshalL3VPNNLRI(tt.input, tt.pathID, true, tt.srv6)
 			if err != nil && !tt.fail {
 				t.Fatalf("expected to succeed but failed with error: %+v", err)
 			}
@@ -13,10 +13,10 @@ import (
 // nlri process base nlri information found and bgp update message and returns
 // a slice of UnicatPrefix.
 // Used Only by Legacy IPv4 Unicast
-func (p *producer) nlri(op int, ph *bmp.PerPeerHeader, update *bgp.Update) ([]*UnicastPrefix, error) {
+func (p *producer) nlri(op int, ph *bmp.PerPeerHeader, update *bgp.Update, addPath map[int]bool) ([]*UnicastPrefix, error) {
 	var operation string
 	var routes []base.Route
-	pathID := p.addPathCapable[bgp.NLRIMessageType(1, 1)]
+	pathID := addPath[bgp.NLRIMessageType(1, 1)]
 	switch op {
 	case 0:
 		operation = "add"
@@ -39,24 +39,35 @@ func (p *producer) nlri(op int, ph *bmp.PerPeerHeader, update *bgp.Update) ([]*U
 	// Check if Update carries any routes, if update comes with 0 routes, it is EoR message
 	if len(routes) == 0 {
 		glog.Infof("><SB> Suspected EoR message for Unicast ipv4")
-		return []*UnicastPrefix{
-			{
-				Action:     operation,
-				RouterHash: p.speakerHash,
-				RouterIP:   p.speakerIP,
-				PeerHash:   ph.GetPeerHash(),
-				PeerASN:    ph.PeerAS,
-				Timestamp:  ph.GetPeerTimestamp(),
-				PeerType:   uint8(ph.PeerType),
-				IsEOR:      true,
-			},
-		}, nil
+		eor := &UnicastPrefix{
+			Action:         operation,
+			RouterHash:     p.speakerHash,
+			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
+			PeerHash:       ph.GetPeerHash(),
+			PeerASN:        ph.PeerAS,
+			Timestamp:      ph.GetPeerTimestamp(),
+			PeerType:       uint8(ph.PeerType),
+			IsEOR:          true,
+		}
+		if f, err := ph.IsAdjRIBInPost(); err == nil {
+			eor.IsAdjRIBInPost = f
+		}
+		if f, err := ph.IsAdjRIBOutPost(); err == nil {
+			eor.IsAdjRIBOutPost = f
+		}
+		if f, err := ph.IsLocRIBFiltered(); err == nil {
+			eor.IsLocRIBFiltered = f
+		}
+		eor.TableName = p.tableName(ph)
+		return []*UnicastPrefix{eor}, nil
 	}
 	for _, pr := range routes {
 		prfx := &UnicastPrefix{
 			Action:         operation,
 			RouterHash:     p.speakerHash,
 			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
 			PeerHash:       ph.GetPeerHash(),
 			PeerASN:        ph.PeerAS,
 			Timestamp:      ph.GetPeerTimestamp(),
@@ -65,10 +76,7 @@ func (p *producer) nlri(op int, ph *bmp.PerPeerHeader, update *bgp.Update) ([]*U
 			PathID:         int32(pr.PathID),
 			BaseAttributes: update.BaseAttributes,
 		}
-		if ases := update.BaseAttributes.ASPath; len(ases) != 0 {
-			// Last element in AS_PATH would be the AS of the origin
-			prfx.OriginAS = ases[len(ases)-1]
-		}
+		prfx.OriginAS = update.BaseAttributes.OriginAS
 		prfx.IsIPv4 = true
 		prfx.PeerIP = ph.GetPeerAddrString()
 		prfx.Nexthop = update.BaseAttributes.Nexthop
@@ -85,6 +93,7 @@ func (p *producer) nlri(op int, ph *bmp.PerPeerHeader, update *bgp.Update) ([]*U
 		if f, err := ph.IsLocRIBFiltered(); err == nil {
 			prfx.IsLocRIBFiltered = f
 		}
+		prfx.TableName = p.tableName(ph)
 
 		prfxs = append(prfxs, prfx)
 	}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 
 	"github.com/golang/glog"
@@ -26,43 +27,146 @@ func (p *producer) produceStatsMessage(msg bmp.Message) {
 	}
 
 	m := Stats{
-		RemoteASN:  msg.PeerHeader.PeerAS,
-		PeerRD:     msg.PeerHeader.GetPeerDistinguisherString(),
-		Timestamp:  msg.PeerHeader.GetPeerTimestamp(),
-		RouterHash: p.speakerHash,
-		RouterIP:   p.speakerIP,
-		PeerType:   uint8(msg.PeerHeader.PeerType),
+		RemoteASN:      msg.PeerHeader.PeerAS,
+		PeerRD:         msg.PeerHeader.GetPeerDistinguisherString(),
+		Timestamp:      msg.PeerHeader.GetPeerTimestamp(),
+		RouterHash:     p.speakerHash,
+		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
+		PeerType:       uint8(msg.PeerHeader.PeerType),
 	}
 	m.RemoteIP = msg.PeerHeader.GetPeerAddrString()
 	m.RemoteBGPID = msg.PeerHeader.GetPeerBGPIDString()
 	for _, tlv := range StatsMsg.StatsTLV {
+		if !decodeStat(&m, tlv) {
+			m.Unknown = append(m.Unknown, &RawStat{
+				Type:  uint16(tlv.InformationType),
+				Value: hex.EncodeToString(tlv.Information),
+			})
+		}
+	}
+	if err := p.marshalAndPublish(&m, bmp.StatsReportMsg, []byte(m.RouterHash), false); err != nil {
+		glog.Errorf("failed to process peer Stats Report message with error: %+v", err)
+		return
+	}
+}
+
+const (
+	statCounterLength      = 4
+	statGaugeLength        = 8
+	statAFISAFIGaugeLength = 11
+	// statAFISAFICounterLength is the length of per AFI/SAFI 32-bit counter
+	statAFISAFICounterLength = 7
+)
+
+// decodeStat sets the field of Stats message corresponding to the stat type as defined in IANA
+// "BMP Statistics Types" registry, types 0 to 17 by RFC 7854 and RFC 8671, types 18 to 23 by
+// draft-ietf-grow-bmp-bgp-rib-stats. It returns false if the type is not known or the length of
+// the stat does not match the type.
+func decodeStat(m *Stats, tlv bmp.InformationalTLV) bool {
+	b := tlv.Information
+	switch tlv.InformationType {
+	case 0, 1, 2, 3, 4, 5, 6, 11, 12, 13:
+		if len(b) != statCounterLength {
+			return false
+		}
+		v := binary.BigEndian.Uint32(b)
 		switch tlv.InformationType {
+		case 0:
+			m.RejectedPrefixes = v
 		case 1:
-			m.DuplicatePrefixs = binary.BigEndian.Uint32(tlv.Information)
+			m.DuplicatePrefixs = v
 		case 2:
-			m.DuplicateWithDraws = binary.BigEndian.Uint32(tlv.Information)
+			m.DuplicateWithDraws = v
 		case 3:
-			m.InvalidatedDueCluster = binary.BigEndian.Uint32(tlv.Information)
+			m.InvalidatedDueCluster = v
 		case 4:
-			m.InvalidatedDueAspath = binary.BigEndian.Uint32(tlv.Information)
+			m.InvalidatedDueAspath = v
 		case 5:
-			m.InvalidatedDueOriginatorId = binary.BigEndian.Uint32(tlv.Information)
+			m.InvalidatedDueOriginatorId = v
 		case 6:
-			m.InvalidatedAsConfed = binary.BigEndian.Uint32(tlv.Information)
-		case 7:
-			m.AdjRIBsIn = binary.BigEndian.Uint64(tlv.Information)
-		case 8:
-			m.LocalRib = binary.BigEndian.Uint64(tlv.Information)
+			m.InvalidatedAsConfed = v
 		case 11:
-			m.UpdatesAsWithdraw = binary.BigEndian.Uint32(tlv.Information)
+			m.UpdatesAsWithdraw = v
 		case 12:
-			m.PrefixesAsWithdraw = binary.BigEndian.Uint32(tlv.Information)
+			m.PrefixesAsWithdraw = v
+		case 13:
+			m.DuplicateUpdates = v
+		}
+	case 7, 8, 14, 15:
+		if len(b) != statGaugeLength {
+			return false
+		}
+		v := binary.BigEndian.Uint64(b)
+		switch tlv.InformationType {
+		case 7:
+			m.AdjRIBsIn = v
+		case 8:
+			m.LocalRib = v
+		case 14:
+			m.AdjRIBOutPre = v
+		case 15:
+			m.AdjRIBOutPost = v
+		}
+	case 9, 10, 16, 17:
+		// Per AFI/SAFI gauges, 2 bytes AFI, 1 byte SAFI followed by 64-bit gauge
+		if len(b) != statAFISAFIGaugeLength {
+			return false
+		}
+		s := m.afiSAFIStats(binary.BigEndian.Uint16(b[0:2]), b[2])
+		v := binary.BigEndian.Uint64(b[3:])
+		switch tlv.InformationType {
+		case 9:
+			s.AdjRIBIn = v
+		case 10:
+			s.LocalRib = v
+		case 16:
+			s.AdjRIBOutPre = v
+		case 17:
+			s.AdjRIBOutPost = v
+		}
+	case 18, 19, 20, 21, 22, 23:
+		// Per AFI/SAFI counters, 2 bytes AFI, 1 byte SAFI followed by 64-bit gauge or 32-bit counter
+		var v uint64
+		switch len(b) {
+		case statAFISAFIGaugeLength:
+			v = binary.BigEndian.Uint64(b[3:])
+		case statAFISAFICounterLength:
+			v = uint64(binary.BigEndian.Uint32(b[3:]))
 		default:
-			// TBD glog.Warningf("unprocessed stats type:%v", tlv.InformationType)
+			return false
+		}
+		s := m.afiSAFIStats(binary.BigEndian.Uint16(b[0:2]), b[2])
+		switch tlv.InformationType {
+		case 18:
+			s.RejectedPrefixes = v
+		case 19:
+			s.AcceptedPrefixes = v
+		case 20:
+			s.DampedPrefixes = v
+		case 21:
+			s.StaleGRPrefixes = v
+		case 22:
+			s.StaleLLGRPrefixes = v
+		case 23:
+			s.PrefixesToLimit = v
 		}
+	default:
+		return false
 	}
-	if err := p.marshalAndPublish(&m, bmp.StatsReportMsg, []byte(m.RouterHash), false); err != nil {
-		glog.Errorf("failed to process peer Stats Report message with error: %+v", err)
-		return
+
+	return true
+}
+
+// afiSAFIStats returns per AFI/SAFI stats entry of the message, the entry is added if it does not exist yet
+func (m *Stats) afiSAFIStats(afi uint16, safi uint8) *AFISAFIStats {
+	for _, s := range m.PerAFISAFI {
+		if s.AFI == afi && s.SAFI == safi {
+			return s
+		}
 	}
+	s := &AFISAFIStats{AFI: afi, SAFI: safi}
+	m.PerAFISAFI = append(m.PerAFISAFI, s)
+
+	return s
 }
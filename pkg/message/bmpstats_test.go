@@ -0,0 +1,113 @@
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func TestProduceStatsMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		tlvs   []bmp.InformationalTLV
+		expect Stats
+	}{
+		{
+			name: "counters and gauges",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: 0, Information: []byte{0, 0, 0, 5}},
+				{InformationType: 1, Information: []byte{0, 0, 0, 1}},
+				{InformationType: 7, Information: []byte{0, 0, 0, 0, 0, 0, 1, 0}},
+				{InformationType: 13, Information: []byte{0, 0, 0, 3}},
+				{InformationType: 14, Information: []byte{0, 0, 0, 0, 0, 0, 0, 10}},
+				{InformationType: 15, Information: []byte{0, 0, 0, 0, 0, 0, 0, 9}},
+			},
+			expect: Stats{
+				RejectedPrefixes: 5,
+				DuplicatePrefixs: 1,
+				AdjRIBsIn:        256,
+				DuplicateUpdates: 3,
+				AdjRIBOutPre:     10,
+				AdjRIBOutPost:    9,
+			},
+		},
+		{
+			name: "per afi safi gauges",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: 9, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 20}},
+				{InformationType: 9, Information: []byte{0, 2, 1, 0, 0, 0, 0, 0, 0, 0, 30}},
+				{InformationType: 10, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 18}},
+				{InformationType: 16, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 17}},
+				{InformationType: 17, Information: []byte{0, 2, 1, 0, 0, 0, 0, 0, 0, 0, 16}},
+			},
+			expect: Stats{
+				PerAFISAFI: []*AFISAFIStats{
+					{AFI: 1, SAFI: 1, AdjRIBIn: 20, LocalRib: 18, AdjRIBOutPre: 17},
+					{AFI: 2, SAFI: 1, AdjRIBIn: 30, AdjRIBOutPost: 16},
+				},
+			},
+		},
+		{
+			name: "per afi safi counters",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: 18, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 4}},
+				{InformationType: 19, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 96}},
+				{InformationType: 20, Information: []byte{0, 2, 1, 0, 0, 0, 3}},
+				{InformationType: 21, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 5}},
+				{InformationType: 22, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 6}},
+				{InformationType: 23, Information: []byte{0, 2, 1, 0, 0, 0, 0, 0, 0, 0, 100}},
+				{InformationType: 9, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 100}},
+			},
+			expect: Stats{
+				PerAFISAFI: []*AFISAFIStats{
+					{AFI: 1, SAFI: 1, AdjRIBIn: 100, RejectedPrefixes: 4, AcceptedPrefixes: 96, StaleGRPrefixes: 5, StaleLLGRPrefixes: 6},
+					{AFI: 2, SAFI: 1, DampedPrefixes: 3, PrefixesToLimit: 100},
+				},
+			},
+		},
+		{
+			name: "unknown type and invalid length",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: 8, Information: []byte{0, 0, 0, 0, 0, 0, 0, 7}},
+				{InformationType: 100, Information: []byte{0xde, 0xad}},
+				{InformationType: 2, Information: []byte{0, 1}},
+				{InformationType: 9, Information: []byte{0, 1, 1, 0, 0, 0, 1}},
+				{InformationType: 18, Information: []byte{0, 1, 1, 0, 0}},
+				{InformationType: 24, Information: []byte{0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 4}},
+			},
+			expect: Stats{
+				LocalRib: 7,
+				Unknown: []*RawStat{
+					{Type: 100, Value: "dead"},
+					{Type: 2, Value: "0001"},
+					{Type: 9, Value: "00010100000001"},
+					{Type: 18, Value: "0001010000"},
+					{Type: 24, Value: "0001010000000000000004"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+			p.produceStatsMessage(bmp.Message{
+				PeerHeader: testPeerHeader(t, 0, 0, 0),
+				Payload:    &bmp.StatsReport{StatsCount: int32(len(tt.tlvs)), StatsTLV: tt.tlvs},
+			})
+			if len(pub.msgs) != 1 || pub.msgs[0].t != bmp.StatsReportMsg {
+				t.Fatalf("expected a single stats message got %+v", pub.msgs)
+			}
+			var m Stats
+			if err := json.Unmarshal(pub.msgs[0].msg, &m); err != nil {
+				t.Fatalf("failed to unmarshal stats message: %+v", err)
+			}
+			m.RemoteASN, m.RemoteIP, m.RemoteBGPID, m.PeerRD, m.Timestamp = 0, "", "", "", ""
+			if !reflect.DeepEqual(m, tt.expect) {
+				t.Errorf("expected stats %+v got %+v", tt.expect, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package message
+
+import (
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// peerCapabilities defines capabilities negotiated by the router and the peer in Open messages carried by Peer Up
+type peerCapabilities struct {
+	// received defines capabilities of Update messages received from the peer
+	received *bgp.UpdateCapabilities
+	// sent defines capabilities of Update messages sent to the peer
+	sent *bgp.UpdateCapabilities
+}
+
+// setPeerCapabilities keeps capabilities negotiated by the router and the peer, Sent Open is sent by the router
+// and Received Open is received from the peer.
+func (p *producer) setPeerCapabilities(ph *bmp.PerPeerHeader, peerUp *bmp.PeerUpMessage) {
+	c := &peerCapabilities{
+		received: bgp.NegotiateUpdateCapabilities(peerUp.ReceivedOpen, peerUp.SentOpen),
+		sent:     bgp.NegotiateUpdateCapabilities(peerUp.SentOpen, peerUp.ReceivedOpen),
+	}
+	p.capabilities[peerKey(ph)] = c
+	if glog.V(6) {
+		glog.Infof("router %s peer %s received updates capabilities: %+v sent updates capabilities: %+v",
+			p.speakerIP, ph.GetPeerAddrString(), *c.received, *c.sent)
+	}
+}
+
+// updateCapabilities returns capabilities of Update messages of the peer, messages of Adj-RIB-Out carry Updates
+// sent to the peer, other messages carry Updates received from the peer. Nil is returned when capabilities
+// of the peer are not known.
+func (p *producer) updateCapabilities(ph *bmp.PerPeerHeader) *bgp.UpdateCapabilities {
+	c, ok := p.capabilities[peerKey(ph)]
+	if !ok {
+		return nil
+	}
+	if out, err := ph.IsAdjRIBOutPost(); err == nil && out {
+		return c.sent
+	}
+	return c.received
+}
+
+// setUpdateAS4 decodes AS_PATH of the update of the peer with the size of ASes defined by the per peer header,
+// when A flag of the header of peer types 0, 1 and 2 is set, AS_PATH carries 2 bytes ASes, otherwise 4 bytes
+// ASes. AS_PATH of Loc-RIB always carries 4 bytes ASes.
+func setUpdateAS4(update *bgp.Update, ph *bmp.PerPeerHeader) {
+	legacy, err := ph.IsLegacyASPath()
+	if err != nil {
+		// Loc-RIB peer does not carry A flag
+		update.SetAS4(true)
+		return
+	}
+	update.SetAS4(!legacy)
+}
+
+// setTypedCommunities removes decoded communities from base attributes of the update unless the producer
+// is configured to publish them.
+func (p *producer) setTypedCommunities(update *bgp.Update) {
+	if p.typedCommunities || update.BaseAttributes == nil {
+		return
+	}
+	update.BaseAttributes.ClearTypedCommunities()
+}
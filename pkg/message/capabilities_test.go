@@ -0,0 +1,281 @@
+package message
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// testCapabilitiesPeerHeader returns per peer header of the peer with address 192.0.2.addr
+func testCapabilitiesPeerHeader(t *testing.T, flags, addr byte) *bmp.PerPeerHeader {
+	ph := testPeerHeader(t, 0, flags, 0)
+	ph.PeerAddress[15] = addr
+	return ph
+}
+
+// testCapabilitiesUpdate returns BGP Update carrying AS_PATH of two segments with 2 bytes ASes 1, 2 and 3,
+// which are decoded as 2 ASes of 4 bytes when ASes are 4 bytes long, and NLRI.
+func testCapabilitiesUpdate(t *testing.T, nlri []byte) *bmp.RouteMonitor {
+	b := []byte{
+		0, 0, // Withdrawn Routes Length
+		0, 17, // Total Path Attribute Length
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 10, 2, 2, 0, 1, 0, 2, 2, 1, 0, 3, // AS_PATH
+	}
+	u, err := bgp.UnmarshalBGPUpdate(append(b, nlri...))
+	if err != nil {
+		t.Fatalf("failed to unmarshal update with error: %+v", err)
+	}
+	return &bmp.RouteMonitor{Update: u}
+}
+
+func TestPeerCapabilities(t *testing.T) {
+	as2Path := []uint32{1, 2, 3}
+	as4Path := []uint32{0x00010002, 0x02010003}
+	// Router sends and receives multiple paths and supports 4 bytes ASes
+	router := &bgp.OpenMessage{
+		BGPID: []byte{192, 0, 2, 1},
+		Capabilities: bgp.Capability{
+			65: {{Value: []byte{0, 0, 0xfd, 0xe8}}},
+			69: {{Value: []byte{0, 1, 1, bgp.AddPathSendReceive}}},
+		},
+	}
+	peers := []struct {
+		addr byte
+		open *bgp.OpenMessage
+	}{
+		{
+			// Peer 2 sends and receives multiple paths and supports 4 bytes ASes
+			addr: 2,
+			open: &bgp.OpenMessage{Capabilities: bgp.Capability{
+				65: {{Value: []byte{0, 0, 0xfd, 0xe9}}},
+				69: {{Value: []byte{0, 1, 1, bgp.AddPathSendReceive}}},
+			}},
+		},
+		{
+			// Peer 3 does not support multiple paths and 4 bytes ASes
+			addr: 3,
+			open: &bgp.OpenMessage{Capabilities: bgp.Capability{}},
+		},
+		{
+			// Peer 4 only receives multiple paths and supports 4 bytes ASes
+			addr: 4,
+			open: &bgp.OpenMessage{Capabilities: bgp.Capability{
+				65: {{Value: []byte{0, 0, 0xfd, 0xea}}},
+				69: {{Value: []byte{0, 1, 1, bgp.AddPathReceive}}},
+			}},
+		},
+	}
+	tests := []struct {
+		name         string
+		addr         byte
+		flags        byte
+		nlri         []byte
+		expectPathID int32
+		expectASPath []uint32
+	}{
+		{
+			name:         "adj-rib-in of add-path and 4 bytes as peer",
+			addr:         2,
+			nlri:         []byte{0, 0, 0, 5, 8, 10},
+			expectPathID: 5,
+			expectASPath: as4Path,
+		},
+		{
+			name:         "adj-rib-in of peer without add-path and 4 bytes as",
+			addr:         3,
+			nlri:         []byte{8, 10},
+			expectASPath: as4Path,
+		},
+		{
+			name:         "legacy as path of peer without 4 bytes as",
+			addr:         3,
+			flags:        0x20,
+			nlri:         []byte{8, 10},
+			expectASPath: as2Path,
+		},
+		{
+			name:         "legacy as path of peer without peer up",
+			addr:         9,
+			flags:        0x20,
+			nlri:         []byte{8, 10},
+			expectASPath: as2Path,
+		},
+		{
+			name:         "as path of peer without peer up",
+			addr:         9,
+			nlri:         []byte{8, 10},
+			expectASPath: as4Path,
+		},
+		{
+			name:         "adj-rib-in of add-path receiving peer",
+			addr:         4,
+			nlri:         []byte{8, 10},
+			expectASPath: as4Path,
+		},
+		{
+			name:         "adj-rib-out of add-path receiving peer",
+			addr:         4,
+			flags:        0x10,
+			nlri:         []byte{0, 0, 0, 7, 8, 10},
+			expectPathID: 7,
+			expectASPath: as4Path,
+		},
+		{
+			name:         "legacy as path of 4 bytes as peer",
+			addr:         2,
+			flags:        0x20,
+			nlri:         []byte{0, 0, 0, 5, 8, 10},
+			expectPathID: 5,
+			expectASPath: as2Path,
+		},
+	}
+	pub := &testPublisher{}
+	p := NewProducer(pub, false, false, false, nil, "192.0.2.1", "", "").(*producer)
+	for _, peer := range peers {
+		p.producingWorker(bmp.Message{
+			PeerHeader: testCapabilitiesPeerHeader(t, 0, peer.addr),
+			Payload: &bmp.PeerUpMessage{
+				LocalAddress: make([]byte, 16),
+				SentOpen:     router,
+				ReceivedOpen: peer.open,
+			},
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub.msgs = nil
+			p.producingWorker(bmp.Message{
+				PeerHeader: testCapabilitiesPeerHeader(t, tt.flags, tt.addr),
+				Payload:    testCapabilitiesUpdate(t, tt.nlri),
+			})
+			if len(pub.msgs) != 1 {
+				t.Fatalf("expected 1 message got %d", len(pub.msgs))
+			}
+			var u UnicastPrefix
+			if err := json.Unmarshal(pub.msgs[0].msg, &u); err != nil {
+				t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+			}
+			if u.Prefix != "10.0.0.0" || u.PrefixLen != 8 || u.PathID != tt.expectPathID {
+				t.Errorf("expected prefix 10.0.0.0/8 path id %d got %s/%d path id %d", tt.expectPathID, u.Prefix, u.PrefixLen, u.PathID)
+			}
+			if !reflect.DeepEqual(u.BaseAttributes.ASPath, tt.expectASPath) {
+				t.Errorf("expected as path %v got %v", tt.expectASPath, u.BaseAttributes.ASPath)
+			}
+		})
+	}
+}
+
+func TestSetUpdateAS4(t *testing.T) {
+	tests := []struct {
+		name         string
+		peerType     byte
+		flags        byte
+		expectASPath []uint32
+	}{
+		{
+			name:         "global instance peer",
+			peerType:     0,
+			expectASPath: []uint32{0x00010002, 0x02010003},
+		},
+		{
+			name:         "global instance peer with legacy as path",
+			peerType:     0,
+			flags:        0x20,
+			expectASPath: []uint32{1, 2, 3},
+		},
+		{
+			name:         "rd instance peer with legacy as path",
+			peerType:     1,
+			flags:        0x20,
+			expectASPath: []uint32{1, 2, 3},
+		},
+		{
+			name:         "loc-rib",
+			peerType:     3,
+			expectASPath: []uint32{0x00010002, 0x02010003},
+		},
+		{
+			// A flag is not defined for Loc-RIB, the bit is ignored
+			name:         "loc-rib with bit of a flag set",
+			peerType:     3,
+			flags:        0x20,
+			expectASPath: []uint32{0x00010002, 0x02010003},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := testCapabilitiesUpdate(t, []byte{8, 10}).Update
+			setUpdateAS4(update, testPeerHeader(t, tt.peerType, tt.flags, 0))
+			if !reflect.DeepEqual(update.BaseAttributes.ASPath, tt.expectASPath) {
+				t.Errorf("expected as path %v got %v", tt.expectASPath, update.BaseAttributes.ASPath)
+			}
+		})
+	}
+}
+
+func TestPeerCapabilitiesRemovedOnPeerDown(t *testing.T) {
+	pub := &testPublisher{}
+	p := NewProducer(pub, false, false, false, nil, "192.0.2.1", "", "").(*producer)
+	addPath := bgp.Capability{69: {{Value: []byte{0, 1, 1, bgp.AddPathSendReceive}}}}
+	p.producingWorker(bmp.Message{
+		PeerHeader: testCapabilitiesPeerHeader(t, 0, 2),
+		Payload: &bmp.PeerUpMessage{
+			LocalAddress: make([]byte, 16),
+			SentOpen:     &bgp.OpenMessage{BGPID: []byte{192, 0, 2, 1}, Capabilities: addPath},
+			ReceivedOpen: &bgp.OpenMessage{Capabilities: addPath},
+		},
+	})
+	if c := p.updateCapabilities(testCapabilitiesPeerHeader(t, 0, 2)); c == nil || !c.AddPath[bgp.NLRIMessageType(1, 1)] {
+		t.Fatalf("expected add-path capability of the peer, got %+v", c)
+	}
+	if c := p.updateCapabilities(testCapabilitiesPeerHeader(t, 0, 3)); c != nil {
+		t.Fatalf("expected no capabilities of unknown peer, got %+v", c)
+	}
+	p.producingWorker(bmp.Message{
+		PeerHeader: testCapabilitiesPeerHeader(t, 0, 2),
+		Payload:    &bmp.PeerDownMessage{Reason: bmp.PeerDownRemoteNoData},
+	})
+	if c := p.updateCapabilities(testCapabilitiesPeerHeader(t, 0, 2)); c != nil {
+		t.Fatalf("expected no capabilities after peer down, got %+v", c)
+	}
+}
+
+func TestPeerUpDecodedCapabilities(t *testing.T) {
+	pub := &testPublisher{}
+	p := NewProducer(pub, false, false, false, nil, "192.0.2.1", "", "").(*producer)
+	p.producingWorker(bmp.Message{
+		PeerHeader: testCapabilitiesPeerHeader(t, 0, 2),
+		Payload: &bmp.PeerUpMessage{
+			LocalAddress: make([]byte, 16),
+			SentOpen: &bgp.OpenMessage{BGPID: []byte{192, 0, 2, 1}, Capabilities: bgp.Capability{
+				bgp.CapabilityMultiProtocol: {{Value: []byte{0, 1, 0, 1}}, {Value: []byte{0, 2, 0, 1}}},
+				bgp.CapabilityFourOctetAS:   {{Value: []byte{0, 0, 0xfd, 0xe8}}},
+			}},
+			ReceivedOpen: &bgp.OpenMessage{Capabilities: bgp.Capability{
+				bgp.CapabilityMultiProtocol: {{Value: []byte{0, 1, 0, 1}}},
+				bgp.CapabilityFQDN:          {{Value: []byte{2, 'r', '2', 0}}},
+			}},
+		},
+	})
+	if len(pub.msgs) != 1 {
+		t.Fatalf("expected 1 message got %d", len(pub.msgs))
+	}
+	var m PeerStateChange
+	if err := json.Unmarshal(pub.msgs[0].msg, &m); err != nil {
+		t.Fatalf("failed to unmarshal peer message: %+v", err)
+	}
+	if m.AdvDecodedCapabilities == nil || m.AdvDecodedCapabilities.FourOctetAS != 65000 || len(m.AdvDecodedCapabilities.MultiProtocol) != 2 {
+		t.Errorf("unexpected advertised capabilities %+v", m.AdvDecodedCapabilities)
+	}
+	if m.RcvDecodedCapabilities == nil || !reflect.DeepEqual(m.RcvDecodedCapabilities.FQDN, &bgp.FQDN{Hostname: "r2"}) {
+		t.Errorf("unexpected received capabilities %+v", m.RcvDecodedCapabilities)
+	}
+	expect := &bgp.NegotiatedCapabilities{MultiProtocol: []bgp.AFISAFI{{AFI: 1, SAFI: 1}}}
+	if !reflect.DeepEqual(m.NegotiatedCapabilities, expect) {
+		t.Errorf("expected negotiated capabilities %+v got %+v", expect, m.NegotiatedCapabilities)
+	}
+}
@@ -36,16 +36,14 @@ func (p *producer) evpn(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, update *
 			PeerType:       uint8(ph.PeerType),
 			RouterHash:     p.speakerHash,
 			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
 			PeerHash:       ph.GetPeerHash(),
 			PeerASN:        ph.PeerAS,
 			Timestamp:      ph.GetPeerTimestamp(),
 			Nexthop:        nlri.GetNextHop(),
 			BaseAttributes: update.BaseAttributes,
 		}
-		if ases := update.BaseAttributes.ASPath; len(ases) != 0 {
-			// Last element in AS_PATH would be the AS of the origin
-			prfx.OriginAS = ases[len(ases)-1]
-		}
+		prfx.OriginAS = update.BaseAttributes.OriginAS
 
 		prfx.PeerIP = ph.GetPeerAddrString()
 		prfx.RemoteBGPID = ph.GetPeerBGPIDString()
@@ -106,16 +104,17 @@ func (p *producer) evpn(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, update *
 				prfx.Labels = append(prfx.Labels, l.Value)
 				prfx.RawLabels = append(prfx.RawLabels, l.GetRawValue())
 			}
-			if f, err := ph.IsAdjRIBInPost(); err == nil {
-				prfx.IsAdjRIBInPost = f
-			}
-			if f, err := ph.IsAdjRIBOutPost(); err == nil {
-				prfx.IsAdjRIBOutPost = f
-			}
-			if f, err := ph.IsLocRIBFiltered(); err == nil {
-				prfx.IsLocRIBFiltered = f
-			}
 		}
+		if f, err := ph.IsAdjRIBInPost(); err == nil {
+			prfx.IsAdjRIBInPost = f
+		}
+		if f, err := ph.IsAdjRIBOutPost(); err == nil {
+			prfx.IsAdjRIBOutPost = f
+		}
+		if f, err := ph.IsLocRIBFiltered(); err == nil {
+			prfx.IsLocRIBFiltered = f
+		}
+		prfx.TableName = p.tableName(ph)
 		prfxs = append(prfxs, prfx)
 	}
 
@@ -29,6 +29,7 @@ func (p *producer) flowspec(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 	fs := &Flowspec{
 		Action:         operation,
 		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
 		PeerType:       uint8(ph.PeerType),
 		PeerASN:        ph.PeerAS,
 		Timestamp:      ph.GetPeerTimestamp(),
@@ -36,10 +37,7 @@ func (p *producer) flowspec(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 		SpecHash:       fsnlri.GetSpecHash(),
 	}
 
-	if ases := update.BaseAttributes.ASPath; len(ases) != 0 {
-		// Last element in AS_PATH would be the AS of the origin
-		fs.OriginAS = ases[len(ases)-1]
-	}
+	fs.OriginAS = update.BaseAttributes.OriginAS
 
 	fs.Nexthop = nlri.GetNextHop()
 	fs.Spec = fsnlri.Spec
@@ -55,6 +53,7 @@ func (p *producer) flowspec(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 	if f, err := ph.IsLocRIBFiltered(); err == nil {
 		fs.IsLocRIBFiltered = f
 	}
+	fs.TableName = p.tableName(ph)
 
 	return []*Flowspec{fs}, nil
 }
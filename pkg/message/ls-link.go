@@ -21,14 +21,15 @@ func (p *producer) lsLink(link *base.LinkNLRI, nextHop string, op int, ph *bmp.P
 		return nil, fmt.Errorf("unknown operation %d", op)
 	}
 	msg := LSLink{
-		Action:     operation,
-		RouterHash: p.speakerHash,
-		RouterIP:   p.speakerIP,
-		PeerType:   uint8(ph.PeerType),
-		PeerHash:   ph.GetPeerHash(),
-		PeerASN:    ph.PeerAS,
-		Timestamp:  ph.GetPeerTimestamp(),
-		DomainID:   link.GetIdentifier(),
+		Action:         operation,
+		RouterHash:     p.speakerHash,
+		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
+		PeerType:       uint8(ph.PeerType),
+		PeerHash:       ph.GetPeerHash(),
+		PeerASN:        ph.PeerAS,
+		Timestamp:      ph.GetPeerTimestamp(),
+		DomainID:       link.GetIdentifier(),
 	}
 	if f, err := ph.IsAdjRIBInPost(); err == nil {
 		msg.IsAdjRIBInPost = f
@@ -39,6 +40,7 @@ func (p *producer) lsLink(link *base.LinkNLRI, nextHop string, op int, ph *bmp.P
 	if f, err := ph.IsLocRIBFiltered(); err == nil {
 		msg.IsLocRIBFiltered = f
 	}
+	msg.TableName = p.tableName(ph)
 	msg.Nexthop = nextHop
 	msg.PeerIP = ph.GetPeerAddrString()
 	msg.Protocol = link.GetLinkProtocolID()
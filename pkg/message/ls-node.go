@@ -20,14 +20,15 @@ func (p *producer) lsNode(node *base.NodeNLRI, _ /* place holder for the next ho
 		return nil, fmt.Errorf("unknown operation %d", op)
 	}
 	msg := LSNode{
-		Action:     operation,
-		RouterHash: p.speakerHash,
-		RouterIP:   p.speakerIP,
-		PeerType:   uint8(ph.PeerType),
-		PeerHash:   ph.GetPeerHash(),
-		PeerASN:    ph.PeerAS,
-		Timestamp:  ph.GetPeerTimestamp(),
-		DomainID:   node.GetIdentifier(),
+		Action:         operation,
+		RouterHash:     p.speakerHash,
+		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
+		PeerType:       uint8(ph.PeerType),
+		PeerHash:       ph.GetPeerHash(),
+		PeerASN:        ph.PeerAS,
+		Timestamp:      ph.GetPeerTimestamp(),
+		DomainID:       node.GetIdentifier(),
 	}
 	if f, err := ph.IsAdjRIBInPost(); err == nil {
 		msg.IsAdjRIBInPost = f
@@ -38,6 +39,7 @@ func (p *producer) lsNode(node *base.NodeNLRI, _ /* place holder for the next ho
 	if f, err := ph.IsLocRIBFiltered(); err == nil {
 		msg.IsLocRIBFiltered = f
 	}
+	msg.TableName = p.tableName(ph)
 	msg.PeerIP = ph.GetPeerAddrString()
 	msg.Protocol = node.GetNodeProtocolID()
 	msg.ProtocolID = node.ProtocolID
@@ -37,24 +37,35 @@ func (p *producer) unicast(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, updat
 	}
 	// Check if Update carries any routes, if update comes with 0 routes, it is EoR message
 	if len(u.NLRI) == 0 {
-		return []*UnicastPrefix{
-			{
-				Action:     operation,
-				RouterHash: p.speakerHash,
-				RouterIP:   p.speakerIP,
-				PeerHash:   ph.GetPeerHash(),
-				PeerASN:    ph.PeerAS,
-				Timestamp:  ph.GetPeerTimestamp(),
-				PeerType:   uint8(ph.PeerType),
-				IsEOR:      true,
-			},
-		}, nil
+		eor := &UnicastPrefix{
+			Action:         operation,
+			RouterHash:     p.speakerHash,
+			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
+			PeerHash:       ph.GetPeerHash(),
+			PeerASN:        ph.PeerAS,
+			Timestamp:      ph.GetPeerTimestamp(),
+			PeerType:       uint8(ph.PeerType),
+			IsEOR:          true,
+		}
+		if f, err := ph.IsAdjRIBInPost(); err == nil {
+			eor.IsAdjRIBInPost = f
+		}
+		if f, err := ph.IsAdjRIBOutPost(); err == nil {
+			eor.IsAdjRIBOutPost = f
+		}
+		if f, err := ph.IsLocRIBFiltered(); err == nil {
+			eor.IsLocRIBFiltered = f
+		}
+		eor.TableName = p.tableName(ph)
+		return []*UnicastPrefix{eor}, nil
 	}
 	for _, e := range u.NLRI {
 		prfx := &UnicastPrefix{
 			Action:         operation,
 			RouterHash:     p.speakerHash,
 			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
 			PeerType:       uint8(ph.PeerType),
 			PeerHash:       ph.GetPeerHash(),
 			PeerASN:        ph.PeerAS,
@@ -72,10 +83,8 @@ func (p *producer) unicast(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, updat
 		if f, err := ph.IsLocRIBFiltered(); err == nil {
 			prfx.IsLocRIBFiltered = f
 		}
-		if ases := update.BaseAttributes.ASPath; len(ases) != 0 {
-			// Last element in AS_PATH would be the AS of the origin
-			prfx.OriginAS = ases[len(ases)-1]
-		}
+		prfx.TableName = p.tableName(ph)
+		prfx.OriginAS = update.BaseAttributes.OriginAS
 		prfx.PeerIP = ph.GetPeerAddrString()
 		prfx.Nexthop = nlri.GetNextHop()
 		if nlri.IsIPv6NLRI() {
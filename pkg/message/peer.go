@@ -1,11 +1,13 @@
 package message
 
 import (
-	"crypto/md5"
 	"fmt"
 	"net"
+	"sort"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bgp"
 	"github.com/sbezverk/gobmp/pkg/bmp"
 )
 
@@ -51,37 +53,46 @@ func (p *producer) producePeerMessage(op int, msg bmp.Message) {
 		m.LocalBGPID = net.IP(peerUpMsg.SentOpen.BGPID).To4().String()
 		m.IsIPv4 = !msg.PeerHeader.IsRemotePeerIPv6()
 		m.LocalIP = peerUpMsg.GetLocalAddressString()
+		m.TableName = peerUpMsg.GetTableName()
+		if msg.PeerHeader.PeerType == bmp.PeerType3 && m.TableName != "" {
+			// Keeping the table name of Loc-RIB instance to set it in route messages of the instance
+			p.tableNames[msg.PeerHeader.GetPeerDistinguisherString()] = m.TableName
+		}
 		glog.Infof("PeerUp msg from %s:%d for %s:%d", m.LocalIP, m.LocalPort, m.RemoteIP, m.RemotePort)
-		// Saving local bgp speaker identities.
-		p.speakerIP = m.LocalIP
-		p.speakerHash = fmt.Sprintf("%x", md5.Sum([]byte(p.speakerIP)))
+		if p.speakerIP == "" {
+			// The address of the router is not known, the local address of the first peer identifies the router
+			p.setSpeaker(m.LocalIP)
+		}
 		m.RouterIP = p.speakerIP
+		m.RouterIdentity = p.routerIdentity
 		m.RouterHash = p.speakerHash
+		m.Name = p.name()
 
 		m.LocalASN = uint32(peerUpMsg.SentOpen.MyAS)
 		if lasn, ok := peerUpMsg.SentOpen.Is4BytesASCapable(); ok {
 			// Local BGP speaker is 4 bytes AS capable
 			m.LocalASN = lasn
 		}
-		// Check if local router advertises AddPath Send/Receive for any AFI/SAFI,
-		// if map comes back empty no further AddPath Capability is needed
-		if lAddPath := peerUpMsg.SentOpen.AddPathCapability(); len(lAddPath) != 0 {
-			// Check if remote router advertises AddPath Send/Receive for any AFI/SAFI,
-			// if map comes back empty no further AddPath Capability is needed
-			if rAddPath := peerUpMsg.ReceivedOpen.AddPathCapability(); len(rAddPath) != 0 {
-				for k := range lAddPath {
-					// Enable AddPath only for AFI/SAFI types existing in both local and remote maps
-					if capable, ok := rAddPath[k]; ok {
-						// AFI/SAFI type exists in both maps, which means both peers support Send/Receive of AddPath
-						p.addPathCapable[k] = capable
-					}
-				}
-			}
-		}
+		// Keeping capabilities negotiated with the peer to decode Updates of the peer
+		p.setPeerCapabilities(msg.PeerHeader, peerUpMsg)
 		m.AdvCapabilities = peerUpMsg.SentOpen.GetCapabilities()
 		m.RcvCapabilities = peerUpMsg.ReceivedOpen.GetCapabilities()
-		if glog.V(6) {
-			glog.Infof("producer for speaker ip: %s add path: %+v", p.speakerIP, p.addPathCapable)
+		m.AdvDecodedCapabilities = m.AdvCapabilities.Decode()
+		m.RcvDecodedCapabilities = m.RcvCapabilities.Decode()
+		m.NegotiatedCapabilities = bgp.NegotiateCapabilities(m.AdvDecodedCapabilities, m.RcvDecodedCapabilities)
+		// Keeping Peer Down message of the peer, it is published if the session with the router is lost
+		p.peers[peerKey(msg.PeerHeader)] = &PeerStateChange{
+			Action:           "down",
+			PeerType:         m.PeerType,
+			RemoteASN:        m.RemoteASN,
+			PeerRD:           m.PeerRD,
+			RemoteIP:         m.RemoteIP,
+			RemoteBGPID:      m.RemoteBGPID,
+			IsIPv4:           m.IsIPv4,
+			IsAdjRIBInPost:   m.IsAdjRIBInPost,
+			IsAdjRIBOutPost:  m.IsAdjRIBOutPost,
+			IsLocRIBFiltered: m.IsLocRIBFiltered,
+			TableName:        m.TableName,
 		}
 	} else {
 		peerDownMsg, ok := msg.Payload.(*bmp.PeerDownMessage)
@@ -90,20 +101,43 @@ func (p *producer) producePeerMessage(op int, msg bmp.Message) {
 			return
 		}
 		m = PeerStateChange{
-			Action:     "down",
-			RouterIP:   p.speakerIP,
-			PeerType:   uint8(msg.PeerHeader.PeerType),
-			RouterHash: p.speakerHash,
-			BMPReason:  int(peerDownMsg.Reason),
-			RemoteASN:  msg.PeerHeader.PeerAS,
-			PeerRD:     msg.PeerHeader.GetPeerDistinguisherString(),
-			Timestamp:  msg.PeerHeader.GetPeerTimestamp(),
+			Action:         "down",
+			RouterIP:       p.speakerIP,
+			RouterIdentity: p.routerIdentity,
+			PeerType:       uint8(msg.PeerHeader.PeerType),
+			RouterHash:     p.speakerHash,
+			Name:           p.name(),
+			BMPReason:      int(peerDownMsg.Reason),
+			RemoteASN:      msg.PeerHeader.PeerAS,
+			PeerRD:         msg.PeerHeader.GetPeerDistinguisherString(),
+			Timestamp:      msg.PeerHeader.GetPeerTimestamp(),
 		}
 		m.RemoteIP = msg.PeerHeader.GetPeerAddrString()
 		m.RemoteBGPID = msg.PeerHeader.GetPeerBGPIDString()
 		m.IsIPv4 = !msg.PeerHeader.IsRemotePeerIPv6()
 		m.InfoData = make([]byte, len(peerDownMsg.Data))
 		copy(m.InfoData, peerDownMsg.Data)
+		if f, err := msg.PeerHeader.IsAdjRIBInPost(); err == nil {
+			m.IsAdjRIBInPost = f
+		}
+		if f, err := msg.PeerHeader.IsAdjRIBOutPost(); err == nil {
+			m.IsAdjRIBOutPost = f
+		}
+		if f, err := msg.PeerHeader.IsLocRIBFiltered(); err == nil {
+			m.IsLocRIBFiltered = f
+		}
+		if t, ok := p.peers[peerKey(msg.PeerHeader)]; ok {
+			m.TableName = t.TableName
+		}
+		if m.TableName == "" {
+			m.TableName = peerDownMsg.GetTableName()
+		}
+		setPeerDownReason(&m, peerDownMsg, msg.PeerHeader.PeerType)
+		delete(p.peers, peerKey(msg.PeerHeader))
+		delete(p.capabilities, peerKey(msg.PeerHeader))
+		if msg.PeerHeader.PeerType == bmp.PeerType3 {
+			delete(p.tableNames, msg.PeerHeader.GetPeerDistinguisherString())
+		}
 
 	}
 	if err := p.marshalAndPublish(&m, bmp.PeerStateChangeMsg, []byte(m.RouterHash), false); err != nil {
@@ -111,3 +145,72 @@ func (p *producer) producePeerMessage(op int, msg bmp.Message) {
 		return
 	}
 }
+
+// setPeerDownReason sets human readable reason of Peer Down message, for reasons carrying BGP Notification
+// error code and subcode with their names and Shutdown Communication are set.
+func setPeerDownReason(m *PeerStateChange, pdw *bmp.PeerDownMessage, peerType bmp.PeerType) {
+	m.BMPReasonText = bmp.PeerDownReasonString(pdw.Reason)
+	switch pdw.Reason {
+	case bmp.PeerDownLocalNotification, bmp.PeerDownRemoteNotification:
+		n, err := pdw.Notification()
+		if err != nil {
+			glog.Warningf("failed to decode BGP Notification of Peer Down message for peer %s with error: %+v", m.RemoteIP, err)
+			m.ErrorText = "malformed BGP Notification: " + err.Error()
+			return
+		}
+		m.BMPErrorCode = int(n.ErrorCode)
+		m.BMPErrorSubCode = int(n.ErrorSubCode)
+		m.BMPErrorCodeText = n.ErrorCodeString()
+		m.BMPErrorSubCodeText = n.ErrorSubCodeString()
+		m.ErrorText = m.BMPErrorCodeText + ": " + m.BMPErrorSubCodeText
+		if s, ok := n.ShutdownCommunication(); ok {
+			m.ShutdownCommunication = s
+			m.ErrorText += ": " + s
+		}
+	case bmp.PeerDownLocalNoNotification:
+		m.ErrorText = m.BMPReasonText
+		if e, ok := pdw.FSMEvent(); ok {
+			m.FSMEvent = int(e)
+			m.ErrorText += fmt.Sprintf(", FSM event %d", e)
+		}
+	case bmp.PeerDownLocalTLV:
+		m.ErrorText = m.BMPReasonText
+		if peerType == bmp.PeerType3 {
+			m.ErrorText = "Loc-RIB instance de-configured"
+		}
+	default:
+		m.ErrorText = m.BMPReasonText
+	}
+}
+
+func peerKey(ph *bmp.PerPeerHeader) string {
+	return ph.GetPeerDistinguisherString() + "|" + ph.GetPeerAddrString()
+}
+
+// ProducePeersDown publishes Peer Down messages marked as collector generated for all peers
+// which are still up, reason is published as the error text.
+func (p *producer) ProducePeersDown(reason string) {
+	keys := make([]string, 0, len(p.peers))
+	for k := range p.peers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	ts := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, k := range keys {
+		m := *p.peers[k]
+		m.RouterIP = p.speakerIP
+		m.RouterHash = p.speakerHash
+		m.RouterIdentity = p.routerIdentity
+		m.Name = p.name()
+		m.Timestamp = ts
+		m.ErrorText = reason
+		m.CollectorGenerated = true
+		glog.Infof("publishing collector generated Peer Down for %s, router: %s, reason: %s", m.RemoteIP, p.speakerIP, reason)
+		if err := p.marshalAndPublish(&m, bmp.PeerStateChangeMsg, []byte(m.RouterHash), false); err != nil {
+			glog.Errorf("failed to process peer message with error: %+v", err)
+		}
+		delete(p.peers, k)
+	}
+	p.tableNames = make(map[string]string)
+	p.capabilities = make(map[string]*peerCapabilities)
+}
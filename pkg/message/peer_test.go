@@ -0,0 +1,130 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func TestPeerDownReason(t *testing.T) {
+	marker := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	tests := []struct {
+		name     string
+		peerType byte
+		pdw      *bmp.PeerDownMessage
+		expect   PeerStateChange
+	}{
+		{
+			name:     "remote shutdown with communication",
+			peerType: 0,
+			pdw: &bmp.PeerDownMessage{
+				Reason: 3,
+				Data:   append(append([]byte{}, marker...), 0x00, 0x1b, 0x03, 0x06, 0x02, 0x05, 'm', 'a', 'i', 'n', 't'),
+			},
+			expect: PeerStateChange{
+				BMPReason:             3,
+				BMPReasonText:         "Remote system closed the session with a notification",
+				BMPErrorCode:          6,
+				BMPErrorSubCode:       2,
+				BMPErrorCodeText:      "Cease",
+				BMPErrorSubCodeText:   "Administrative Shutdown",
+				ShutdownCommunication: "maint",
+				ErrorText:             "Cease: Administrative Shutdown: maint",
+			},
+		},
+		{
+			name:     "local hold timer expired",
+			peerType: 0,
+			pdw: &bmp.PeerDownMessage{
+				Reason: 1,
+				Data:   append(append([]byte{}, marker...), 0x00, 0x15, 0x03, 0x04, 0x00),
+			},
+			expect: PeerStateChange{
+				BMPReason:           1,
+				BMPReasonText:       "Local system closed the session with a notification",
+				BMPErrorCode:        4,
+				BMPErrorCodeText:    "Hold Timer Expired",
+				BMPErrorSubCodeText: "Unspecific",
+				ErrorText:           "Hold Timer Expired: Unspecific",
+			},
+		},
+		{
+			name:     "malformed notification",
+			peerType: 0,
+			pdw:      &bmp.PeerDownMessage{Reason: 1, Data: []byte{0xFF}},
+			expect: PeerStateChange{
+				BMPReason:     1,
+				BMPReasonText: "Local system closed the session with a notification",
+				ErrorText:     "malformed BGP Notification: invalid length 1 of BGP Notification in Peer Down message",
+			},
+		},
+		{
+			name:     "local fsm event",
+			peerType: 0,
+			pdw:      &bmp.PeerDownMessage{Reason: 2, Data: []byte{0x00, 0x12}},
+			expect: PeerStateChange{
+				BMPReason:     2,
+				BMPReasonText: "Local system closed the session without a notification",
+				FSMEvent:      18,
+				ErrorText:     "Local system closed the session without a notification, FSM event 18",
+			},
+		},
+		{
+			name:     "peer de-configured",
+			peerType: 0,
+			pdw:      &bmp.PeerDownMessage{Reason: 5},
+			expect: PeerStateChange{
+				BMPReason:     5,
+				BMPReasonText: "Peer de-configured",
+				ErrorText:     "Peer de-configured",
+			},
+		},
+		{
+			name:     "loc-rib de-configured",
+			peerType: 3,
+			pdw:      &bmp.PeerDownMessage{Reason: 6, Data: []byte{0x00, 0x03, 0x00, 0x04, 'b', 'l', 'u', 'e'}},
+			expect: PeerStateChange{
+				BMPReason:     6,
+				BMPReasonText: "Local system closed the session",
+				ErrorText:     "Loc-RIB instance de-configured",
+				TableName:     "blue",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+			p.producingWorker(bmp.Message{PeerHeader: testPeerHeader(t, tt.peerType, 0, 0), Payload: tt.pdw})
+			if len(pub.msgs) != 1 || pub.msgs[0].t != bmp.PeerStateChangeMsg {
+				t.Fatalf("expected a single peer message got %+v", pub.msgs)
+			}
+			var m PeerStateChange
+			if err := json.Unmarshal(pub.msgs[0].msg, &m); err != nil {
+				t.Fatalf("failed to unmarshal peer message: %+v", err)
+			}
+			if m.BMPReason != tt.expect.BMPReason || m.BMPReasonText != tt.expect.BMPReasonText {
+				t.Errorf("expected reason %d %q got %d %q", tt.expect.BMPReason, tt.expect.BMPReasonText, m.BMPReason, m.BMPReasonText)
+			}
+			if m.BMPErrorCode != tt.expect.BMPErrorCode || m.BMPErrorSubCode != tt.expect.BMPErrorSubCode {
+				t.Errorf("expected error code %d/%d got %d/%d", tt.expect.BMPErrorCode, tt.expect.BMPErrorSubCode, m.BMPErrorCode, m.BMPErrorSubCode)
+			}
+			if m.BMPErrorCodeText != tt.expect.BMPErrorCodeText || m.BMPErrorSubCodeText != tt.expect.BMPErrorSubCodeText {
+				t.Errorf("expected error code names %q/%q got %q/%q", tt.expect.BMPErrorCodeText, tt.expect.BMPErrorSubCodeText, m.BMPErrorCodeText, m.BMPErrorSubCodeText)
+			}
+			if m.ShutdownCommunication != tt.expect.ShutdownCommunication {
+				t.Errorf("expected shutdown communication %q got %q", tt.expect.ShutdownCommunication, m.ShutdownCommunication)
+			}
+			if m.FSMEvent != tt.expect.FSMEvent {
+				t.Errorf("expected fsm event %d got %d", tt.expect.FSMEvent, m.FSMEvent)
+			}
+			if m.ErrorText != tt.expect.ErrorText {
+				t.Errorf("expected error text %q got %q", tt.expect.ErrorText, m.ErrorText)
+			}
+			if m.TableName != tt.expect.TableName {
+				t.Errorf("expected table name %q got %q", tt.expect.TableName, m.TableName)
+			}
+		})
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"github.com/sbezverk/gobmp/pkg/srv6"
 )
 
-func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPeerHeader, update *bgp.Update) {
+// processMPUpdate produces messages from MP_REACH_NLRI or MP_UNREACH_NLRI of the update, index is the index
+// of the first NLRI in Path Marking TLVs. The number of processed NLRIs is returned.
+func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPeerHeader, rm *bmp.RouteMonitor, index int) int {
+	update := rm.Update
 	labeled := false
 	labeledSet := false
 	switch nlri.GetAFISAFIType() {
@@ -40,7 +43,17 @@ func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPe
 		}
 		msgs, err := p.unicast(nlri, operation, ph, update, labeled)
 		if err != nil {
-			return
+			return 0
+		}
+		n := 0
+		for _, m := range msgs {
+			if m.IsEOR {
+				continue
+			}
+			if operation == AddPrefix {
+				m.PathStatus = pathStatus(rm, index+n)
+			}
+			n++
 		}
 		// Loop through and publish all collected messages
 		for _, m := range msgs {
@@ -52,18 +65,24 @@ func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPe
 					topicType = bmp.UnicastPrefixV6Msg
 				}
 			}
-			if err := p.marshalAndPublish(&m, topicType, []byte(m.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &m, topicType, []byte(m.RouterHash)); err != nil {
 				glog.Errorf("failed to process Unicast Prefix message with error: %+v", err)
-				return
+				break
 			}
 		}
+		return n
 	case 18:
 		fallthrough
 	case 19:
 		msgs, err := p.l3vpn(nlri, operation, ph, update)
 		if err != nil {
 			glog.Errorf("failed to produce l3vpn messages with error: %+v", err)
-			return
+			return 0
+		}
+		if operation == AddPrefix {
+			for i := range msgs {
+				msgs[i].PathStatus = pathStatus(rm, index+i)
+			}
 		}
 		for _, m := range msgs {
 			topicType := bmp.L3VPNMsg
@@ -74,30 +93,37 @@ func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPe
 					topicType = bmp.L3VPNV6Msg
 				}
 			}
-			if err := p.marshalAndPublish(&m, topicType, []byte(m.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &m, topicType, []byte(m.RouterHash)); err != nil {
 				glog.Errorf("failed to process L3VPN message with error: %+v", err)
-				return
+				break
 			}
 		}
+		return len(msgs)
 	case 24:
 		msgs, err := p.evpn(nlri, operation, ph, update)
 		if err != nil {
 			glog.Errorf("failed to produce evpn messages with error: %+v", err)
-			return
+			return 0
+		}
+		if operation == AddPrefix {
+			for i := range msgs {
+				msgs[i].PathStatus = pathStatus(rm, index+i)
+			}
 		}
 		for _, msg := range msgs {
-			if err := p.marshalAndPublish(&msg, bmp.EVPNMsg, []byte(msg.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &msg, bmp.EVPNMsg, []byte(msg.RouterHash)); err != nil {
 				glog.Errorf("failed to process EVPNP message with error: %+v", err)
-				return
+				break
 			}
 		}
+		return len(msgs)
 	case 25:
 		fallthrough
 	case 26:
 		msgs, err := p.srpolicy(nlri, operation, ph, update)
 		if err != nil {
 			glog.Errorf("failed to produce srpolicy messages with error: %+v", err)
-			return
+			return 0
 		}
 		for _, m := range msgs {
 			topicType := bmp.SRPolicyMsg
@@ -108,16 +134,17 @@ func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPe
 					topicType = bmp.SRPolicyV6Msg
 				}
 			}
-			if err := p.marshalAndPublish(&m, topicType, []byte(m.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &m, topicType, []byte(m.RouterHash)); err != nil {
 				glog.Errorf("failed to process SRPolicy message with error: %+v", err)
-				return
+				break
 			}
 		}
+		return len(msgs)
 	case 27:
 		msgs, err := p.flowspec(nlri, operation, ph, update)
 		if err != nil {
 			glog.Errorf("failed to produce flowspec messages with error: %+v", err)
-			return
+			return 0
 		}
 		for _, m := range msgs {
 			topicType := bmp.FlowspecMsg
@@ -128,22 +155,26 @@ func (p *producer) processMPUpdate(nlri bgp.MPNLRI, operation int, ph *bmp.PerPe
 					topicType = bmp.FlowspecV6Msg
 				}
 			}
-			if err := p.marshalAndPublish(&m, topicType, []byte(m.SpecHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &m, topicType, []byte(m.SpecHash)); err != nil {
 				glog.Errorf("failed to process Flowspec message with error: %+v", err)
-				return
+				break
 			}
 		}
+		return len(msgs)
 	case 71:
-		p.processNLRI71SubTypes(nlri, operation, ph, update)
+		return p.processNLRI71SubTypes(nlri, operation, ph, update)
 	}
+
+	return 0
 }
 
-func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp.PerPeerHeader, update *bgp.Update) {
+// processNLRI71SubTypes produces BGP-LS messages and returns the number of processed NLRIs
+func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp.PerPeerHeader, update *bgp.Update) int {
 	// NLRI 71 carries 6 known sub type
 	ls, err := nlri.GetNLRI71()
 	if err != nil {
 		glog.Errorf("failed to NLRI 71 with error: %+v", err)
-		return
+		return 0
 	}
 	for _, e := range ls.NLRI {
 		// ipv4Flag used to differentiate between IPv4 and IPv6 Prefix NLRI messages
@@ -163,7 +194,7 @@ func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp
 			if p.msgQueue != nil {
 				p.msgQueue <- msg
 			}
-			if err := p.marshalAndPublish(&msg, bmp.LSNodeMsg, []byte(msg.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &msg, bmp.LSNodeMsg, []byte(msg.RouterHash)); err != nil {
 				glog.Errorf("failed to process LSNode message with error: %+v", err)
 				continue
 			}
@@ -181,7 +212,7 @@ func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp
 			if p.msgQueue != nil {
 				p.msgQueue <- msg
 			}
-			if err := p.marshalAndPublish(&msg, bmp.LSLinkMsg, []byte(msg.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &msg, bmp.LSLinkMsg, []byte(msg.RouterHash)); err != nil {
 				glog.Errorf("failed to process LSLink message with error: %+v", err)
 				continue
 			}
@@ -199,7 +230,7 @@ func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp
 				glog.Errorf("failed to produce ls_prefix message with error: %+v", err)
 				continue
 			}
-			if err := p.marshalAndPublish(&msg, bmp.LSPrefixMsg, []byte(msg.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &msg, bmp.LSPrefixMsg, []byte(msg.RouterHash)); err != nil {
 				glog.Errorf("failed to process LSPrefix message with error: %+v", err)
 				continue
 			}
@@ -214,7 +245,7 @@ func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp
 				glog.Errorf("failed to produce ls_srv6_sid message with error: %+v", err)
 				continue
 			}
-			if err := p.marshalAndPublish(&msg, bmp.LSSRv6SIDMsg, []byte(msg.RouterHash), false); err != nil {
+			if err := p.marshalAndPublishRoute(ph, &msg, bmp.LSSRv6SIDMsg, []byte(msg.RouterHash)); err != nil {
 				glog.Errorf("failed to process LSSRv6SID message with error: %+v", err)
 				continue
 			}
@@ -223,4 +254,6 @@ func (p *producer) processNLRI71SubTypes(nlri bgp.MPNLRI, operation int, ph *bmp
 		}
 
 	}
+
+	return len(ls.NLRI)
 }
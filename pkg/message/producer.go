@@ -1,6 +1,9 @@
 package message
 
 import (
+	"crypto/md5"
+	"fmt"
+
 	"github.com/golang/glog"
 	"github.com/sbezverk/gobmp/pkg/bmp"
 	"github.com/sbezverk/gobmp/pkg/pub"
@@ -14,25 +17,52 @@ const (
 // Producer defines methods to act as a message producer
 type Producer interface {
 	Producer(queue chan bmp.Message, stop chan struct{})
+	// ProducePeersDown publishes Peer Down messages for all peers which are still up, it must
+	// not be called concurrently with Producer.
+	ProducePeersDown(reason string)
 }
 
 type producer struct {
-	publisher      pub.Publisher
-	speakerIP      string
-	speakerHash    string
-	addPathCapable map[int]bool
+	publisher pub.Publisher
+	// speakerIP is the IP address of the router, it is fixed for the lifetime of BMP session and set in
+	// RouterIP field of all messages. It is the remote address of BMP session, when the address is not known,
+	// the local address of the first Peer Up message is used.
+	speakerIP string
+	// speakerHash is md5 hash of speakerIP, it is set in RouterHash field of all messages and used as their key
+	speakerHash string
+	// routerIdentity is the identity of the router authenticated by the BMP session's TLS client certificate
+	routerIdentity string
+	// routerName is the name configured for the router, it takes precedence over sysName
+	routerName string
+	// sysName is the name of the router advertised in BMP Initiation message
+	sysName string
+	// capabilities keeps capabilities negotiated by the peers which are up, keyed by peer distinguisher and address
+	capabilities map[string]*peerCapabilities
+	// peers keeps Peer Down messages of the peers which are up, keyed by peer distinguisher and address
+	peers map[string]*PeerStateChange
+	// tableNames keeps VRF/Table names of Loc-RIB instances keyed by peer distinguisher
+	tableNames map[string]string
 	// If splitAF is set to true, ipv4 and ipv6 messages will go into separate topics
 	splitAF bool
+	// If tableTopics is set to true, route messages of Loc-RIB instances go into topics of their tables
+	tableTopics bool
+	// If typedCommunities is set to true, base attributes carry decoded communities in addition to their strings
+	typedCommunities bool
 	// Queue to send messages
 	msgQueue chan interface{}
 }
 
-// Producer dispatches kafka workers upon request received from the channel
+// Producer processes messages received from the channel strictly in the order of their arrival,
+// messages are published in the same order they were received. Producer returns when stop channel
+// is closed or when the queue is closed and all queued messages are processed.
 func (p *producer) Producer(queue chan bmp.Message, stop chan struct{}) {
 	for {
 		select {
-		case msg := <-queue:
-			go p.producingWorker(msg)
+		case msg, ok := <-queue:
+			if !ok {
+				return
+			}
+			p.producingWorker(msg)
 		case <-stop:
 			glog.Infof("received interrupt, stopping.")
 			return
@@ -41,6 +71,9 @@ func (p *producer) Producer(queue chan bmp.Message, stop chan struct{}) {
 }
 
 func (p *producer) producingWorker(msg bmp.Message) {
+	if msg.Done != nil {
+		defer msg.Done()
+	}
 	switch obj := msg.Payload.(type) {
 	case *bmp.PeerUpMessage:
 		p.producePeerMessage(peerUP, msg)
@@ -50,17 +83,59 @@ func (p *producer) producingWorker(msg bmp.Message) {
 		p.produceRouteMonitorMessage(msg)
 	case *bmp.StatsReport:
 		p.produceStatsMessage(msg)
+	case *bmp.RouteMirror:
+		p.produceRouteMirrorMessage(msg)
+	case *bmp.InitiationMessage, *bmp.TerminationMessage:
+		p.produceRouterMessage(msg)
 	default:
 		glog.Warningf("got Unknown message %T to push to the producer, ignoring it...", obj)
 	}
 }
 
-// NewProducer instantiates a new instance of a producer with Publisher interface
-func NewProducer(publisher pub.Publisher, splitAF bool, msgQueue chan interface{}) Producer {
-	return &producer{
-		publisher:      publisher,
-		splitAF:        splitAF,
-		addPathCapable: make(map[int]bool),
-		msgQueue:       msgQueue,
+// NewProducer instantiates a new instance of a producer with Publisher interface. routerIP is the address
+// of the router identifying all produced messages, routerName when not empty is published as the router's name
+// instead of sysName advertised by the router, routerIdentity when not empty is set in all produced messages.
+// When tableTopics is true and the publisher implements pub.TablePublisher, route messages of Loc-RIB instances
+// are published to topics of their tables. When typedCommunities is true, base attributes of route messages
+// carry decoded communities, extended communities and large communities.
+func NewProducer(publisher pub.Publisher, splitAF, tableTopics, typedCommunities bool, msgQueue chan interface{}, routerIP, routerName, routerIdentity string) Producer {
+	p := &producer{
+		publisher:        publisher,
+		splitAF:          splitAF,
+		tableTopics:      tableTopics,
+		typedCommunities: typedCommunities,
+		tableNames:       make(map[string]string),
+		routerName:       routerName,
+		routerIdentity:   routerIdentity,
+		capabilities:     make(map[string]*peerCapabilities),
+		peers:            make(map[string]*PeerStateChange),
+		msgQueue:         msgQueue,
+	}
+	if routerIP != "" {
+		p.setSpeaker(routerIP)
+	}
+
+	return p
+}
+
+// setSpeaker sets the IP address and the hash identifying the router
+func (p *producer) setSpeaker(ip string) {
+	p.speakerIP = ip
+	p.speakerHash = fmt.Sprintf("%x", md5.Sum([]byte(ip)))
+}
+
+// name returns the name of the router, the configured name takes precedence over sysName
+func (p *producer) name() string {
+	if p.routerName != "" {
+		return p.routerName
+	}
+	return p.sysName
+}
+
+// tableName returns VRF/Table name of the Loc-RIB instance of the peer, or empty string for other peers
+func (p *producer) tableName(ph *bmp.PerPeerHeader) string {
+	if ph.PeerType != bmp.PeerType3 {
+		return ""
 	}
+	return p.tableNames[ph.GetPeerDistinguisherString()]
 }
@@ -0,0 +1,169 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+type tableRecorded struct {
+	t     int
+	table string
+}
+
+// testTablePublisher records messages published to table topics
+type testTablePublisher struct {
+	testPublisher
+	tables []tableRecorded
+}
+
+func (tp *testTablePublisher) PublishTableMessage(t int, table string, key []byte, msg []byte) error {
+	tp.tables = append(tp.tables, tableRecorded{t: t, table: table})
+	return tp.PublishMessage(t, key, msg)
+}
+
+func testPeerHeader(t *testing.T, peerType, flags byte, pd byte) *bmp.PerPeerHeader {
+	b := make([]byte, bmp.PerPeerHeaderLength)
+	b[0] = peerType
+	b[1] = flags
+	b[9] = pd
+	copy(b[22:26], []byte{192, 0, 2, 2})
+	b[29] = 100
+	ph, err := bmp.UnmarshalPerPeerHeader(b)
+	if err != nil {
+		t.Fatalf("failed to unmarshal per peer header: %+v", err)
+	}
+	return ph
+}
+
+func testPeerUp(table string) *bmp.PeerUpMessage {
+	pu := &bmp.PeerUpMessage{
+		LocalAddress: make([]byte, 16),
+		SentOpen:     &bgp.OpenMessage{BGPID: []byte{192, 0, 2, 1}},
+		ReceivedOpen: &bgp.OpenMessage{},
+	}
+	if table != "" {
+		pu.Information = []bmp.InformationalTLV{{InformationType: bmp.PeerUpInfoVRFTableName, Information: []byte(table)}}
+	}
+	return pu
+}
+
+// testUpdate returns BGP Update with IPv4 NLRI 10.0.0.0/8
+func testUpdate() *bmp.RouteMonitor {
+	return &bmp.RouteMonitor{
+		Update: &bgp.Update{
+			NLRI:           []byte{8, 10},
+			BaseAttributes: &bgp.BaseAttributes{},
+		},
+	}
+}
+
+func TestLocRIBTableName(t *testing.T) {
+	tests := []struct {
+		name        string
+		peerType    byte
+		flags       byte
+		table       string
+		tableTopics bool
+		expectTable string
+		filtered    bool
+		published   []tableRecorded
+	}{
+		{
+			name:        "loc-rib instance",
+			peerType:    3,
+			table:       "blue",
+			expectTable: "blue",
+		},
+		{
+			name:        "filtered loc-rib instance with table topics",
+			peerType:    3,
+			flags:       0x80,
+			table:       "blue",
+			tableTopics: true,
+			expectTable: "blue",
+			filtered:    true,
+			published:   []tableRecorded{{t: bmp.UnicastPrefixV4Msg, table: "blue"}},
+		},
+		{
+			name:        "loc-rib instance without table name",
+			peerType:    3,
+			tableTopics: true,
+		},
+		{
+			name:        "global instance peer",
+			peerType:    0,
+			table:       "blue",
+			tableTopics: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testTablePublisher{}
+			p := NewProducer(pub, true, tt.tableTopics, false, nil, "", "", "").(*producer)
+			ph := testPeerHeader(t, tt.peerType, tt.flags, 1)
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testPeerUp(tt.table)})
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testUpdate()})
+			// Route of another instance does not carry the table name
+			p.producingWorker(bmp.Message{PeerHeader: testPeerHeader(t, tt.peerType, tt.flags, 2), Payload: testUpdate()})
+			if len(pub.msgs) != 3 {
+				t.Fatalf("expected 3 messages got %d", len(pub.msgs))
+			}
+			var peer PeerStateChange
+			if err := json.Unmarshal(pub.msgs[0].msg, &peer); err != nil {
+				t.Fatalf("failed to unmarshal peer message: %+v", err)
+			}
+			if peer.TableName != tt.table {
+				t.Errorf("expected peer table name %q got %q", tt.table, peer.TableName)
+			}
+			for i, expect := range []string{tt.expectTable, ""} {
+				var u UnicastPrefix
+				if err := json.Unmarshal(pub.msgs[i+1].msg, &u); err != nil {
+					t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+				}
+				if u.TableName != expect {
+					t.Errorf("expected route table name %q got %q", expect, u.TableName)
+				}
+				if u.IsLocRIBFiltered != tt.filtered {
+					t.Errorf("expected loc-rib filtered %t got %t", tt.filtered, u.IsLocRIBFiltered)
+				}
+			}
+			if len(pub.tables) != len(tt.published) {
+				t.Fatalf("expected %d messages published to table topics got %d", len(tt.published), len(pub.tables))
+			}
+			for i := range tt.published {
+				if pub.tables[i] != tt.published[i] {
+					t.Errorf("expected table message %+v got %+v", tt.published[i], pub.tables[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLocRIBTableNameRemovedOnPeerDown(t *testing.T) {
+	pub := &testPublisher{}
+	p := NewProducer(pub, true, false, false, nil, "", "", "").(*producer)
+	ph := testPeerHeader(t, 3, 0, 1)
+	p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testPeerUp("blue")})
+	p.producingWorker(bmp.Message{PeerHeader: ph, Payload: &bmp.PeerDownMessage{Reason: 5}})
+	p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testUpdate()})
+	if len(pub.msgs) != 3 {
+		t.Fatalf("expected 3 messages got %d", len(pub.msgs))
+	}
+	var down PeerStateChange
+	if err := json.Unmarshal(pub.msgs[1].msg, &down); err != nil {
+		t.Fatalf("failed to unmarshal peer message: %+v", err)
+	}
+	if down.TableName != "blue" {
+		t.Errorf("expected peer down table name \"blue\" got %q", down.TableName)
+	}
+	var u UnicastPrefix
+	if err := json.Unmarshal(pub.msgs[2].msg, &u); err != nil {
+		t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+	}
+	if u.TableName != "" {
+		t.Errorf("expected no table name after peer down got %q", u.TableName)
+	}
+}
@@ -0,0 +1,235 @@
+package message
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/base"
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+const (
+	// bgpHeaderLength is the length of BGP message header, 16 bytes of marker, 2 bytes of length and 1 byte of type
+	bgpHeaderLength = 19
+	bgpUpdateType   = 2
+)
+
+// produceRouteMirrorMessage produces RouteMirror messages from BMP Route Mirroring message, mirrored
+// BGP Updates are decoded when possible, other PDUs are published as hex.
+func (p *producer) produceRouteMirrorMessage(msg bmp.Message) {
+	if msg.PeerHeader == nil {
+		glog.Errorf("perPeerHeader is missing, cannot construct RouteMirror message")
+		return
+	}
+	rm, ok := msg.Payload.(*bmp.RouteMirror)
+	if !ok {
+		glog.Errorf("got invalid Payload type in bmp.RouteMirror %+v", msg.Payload)
+		return
+	}
+	tmpl := RouteMirror{
+		RouterHash:     p.speakerHash,
+		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
+		PeerHash:       msg.PeerHeader.GetPeerHash(),
+		PeerType:       uint8(msg.PeerHeader.PeerType),
+		PeerRD:         msg.PeerHeader.GetPeerDistinguisherString(),
+		RemoteBGPID:    msg.PeerHeader.GetPeerBGPIDString(),
+		RemoteASN:      msg.PeerHeader.PeerAS,
+		RemoteIP:       msg.PeerHeader.GetPeerAddrString(),
+		IsIPv4:         !msg.PeerHeader.IsRemotePeerIPv6(),
+		Timestamp:      msg.PeerHeader.GetPeerTimestamp(),
+	}
+	for _, code := range rm.InformationCodes() {
+		switch code {
+		case bmp.RouteMirrorErroredPDU:
+			tmpl.ErroredPDU = true
+		case bmp.RouteMirrorMessagesLost:
+			tmpl.MessagesLost = true
+		}
+	}
+	msgs := make([]RouteMirror, 0)
+	for _, b := range rm.BGPMessages() {
+		m := tmpl
+		if len(b) >= bgpHeaderLength {
+			m.BGPMessageType = b[18]
+		}
+		if m.BGPMessageType == bgpUpdateType {
+			if u, err := p.decodeMirroredUpdate(b, msg.PeerHeader); err == nil {
+				m.Update = u
+			} else {
+				m.DecodeError = err.Error()
+			}
+		}
+		if m.Update == nil {
+			m.RawMessage = hex.EncodeToString(b)
+		}
+		msgs = append(msgs, m)
+	}
+	if len(msgs) == 0 {
+		msgs = append(msgs, tmpl)
+	}
+	for i := range msgs {
+		if err := p.marshalAndPublish(&msgs[i], bmp.RouteMirrorMsg, []byte(msgs[i].RouterHash), false); err != nil {
+			glog.Errorf("failed to process route mirroring message with error: %+v", err)
+			return
+		}
+	}
+}
+
+// decodeMirroredUpdate decodes BGP Update message b including BGP header. Mirrored PDUs are often
+// malformed, the structure of the Update is validated before decoding and a failure to decode
+// any of the attributes is returned as an error.
+func (p *producer) decodeMirroredUpdate(b []byte, ph *bmp.PerPeerHeader) (*MirroredUpdate, error) {
+	if err := validateUpdate(b); err != nil {
+		return nil, err
+	}
+	update, err := bgp.UnmarshalBGPUpdate(b[bgpHeaderLength:])
+	if err != nil {
+		return nil, err
+	}
+	setUpdateAS4(update, ph)
+	p.setTypedCommunities(update)
+	// Route Mirroring message does not carry Stateless Parsing TLVs, capabilities of the peer apply
+	addPath, multiLabel := routeMonitorCapabilities(p.updateCapabilities(ph), &bmp.RouteMonitor{Update: update})
+	u := &MirroredUpdate{
+		BaseAttributes: update.BaseAttributes,
+	}
+	for _, attr := range update.PathAttributes {
+		u.Attributes = append(u.Attributes, int(attr.AttributeType))
+		switch attr.AttributeType {
+		case bgp.MP_REACH_NLRI:
+			nlri, err := bgp.UnmarshalMPReachNLRI(attr.Attribute, update.HasPrefixSID(), addPath, multiLabel)
+			if err != nil {
+				return nil, fmt.Errorf("malformed MP_REACH_NLRI attribute: %w", err)
+			}
+			mp := nlri.(*bgp.MPReachNLRI)
+			u.MPReachAFI, u.MPReachSAFI = mp.AddressFamilyID, mp.SubAddressFamilyID
+			u.MPReachNextHop = nlri.GetNextHop()
+			if u.MPReachNLRI, err = mpPrefixes(nlri, u.MPReachAFI, u.MPReachSAFI); err != nil {
+				return nil, fmt.Errorf("malformed MP_REACH_NLRI attribute: %w", err)
+			}
+		case bgp.MP_UNREACH_NLRI:
+			nlri, err := bgp.UnmarshalMPUnReachNLRI(attr.Attribute, addPath, multiLabel)
+			if err != nil {
+				return nil, fmt.Errorf("malformed MP_UNREACH_NLRI attribute: %w", err)
+			}
+			mp := nlri.(*bgp.MPUnReachNLRI)
+			u.MPUnreachAFI, u.MPUnreachSAFI = mp.AddressFamilyID, mp.SubAddressFamilyID
+			if u.MPUnreachNLRI, err = mpPrefixes(nlri, u.MPUnreachAFI, u.MPUnreachSAFI); err != nil {
+				return nil, fmt.Errorf("malformed MP_UNREACH_NLRI attribute: %w", err)
+			}
+		}
+	}
+	pathID := addPath[bgp.NLRIMessageType(1, 1)]
+	if u.WithdrawnRoutes, err = ipv4Prefixes(update.WithdrawnRoutes, pathID); err != nil {
+		return nil, fmt.Errorf("malformed withdrawn routes: %w", err)
+	}
+	if u.NLRI, err = ipv4Prefixes(update.NLRI, pathID); err != nil {
+		return nil, fmt.Errorf("malformed nlri: %w", err)
+	}
+
+	return u, nil
+}
+
+// mpPrefixes returns prefixes of IPv4 and IPv6 Unicast, Labeled Unicast and L3VPN NLRIs, prefixes of L3VPN
+// NLRIs are prepended with the Route Distinguisher and a space. NLRIs of other families are not listed.
+func mpPrefixes(nlri bgp.MPNLRI, afi uint16, safi uint8) ([]string, error) {
+	var mp *base.MPNLRI
+	var err error
+	switch {
+	case afi != 1 && afi != 2:
+		return nil, nil
+	case safi == 1:
+		mp, err = nlri.GetNLRIUnicast()
+	case safi == 4:
+		mp, err = nlri.GetNLRILU()
+	case safi == 128:
+		mp, err = nlri.GetNLRIL3VPN()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l := 4
+	if afi == 2 {
+		l = 16
+	}
+	prefixes := make([]string, 0, len(mp.NLRI))
+	for _, r := range mp.NLRI {
+		if int(r.Length) > l*8 || len(r.Prefix) > l {
+			return nil, fmt.Errorf("invalid prefix length %d", r.Length)
+		}
+		a := make([]byte, l)
+		copy(a, r.Prefix)
+		prefix := net.IP(a).String() + "/" + strconv.Itoa(int(r.Length))
+		if r.RD != nil {
+			prefix = r.RD.String() + " " + prefix
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// validateUpdate checks that lengths of BGP Update message b, its Withdrawn Routes, Path Attributes
+// and every Path Attribute are consistent.
+func validateUpdate(b []byte) error {
+	if len(b) < bgpHeaderLength+4 {
+		return fmt.Errorf("update is too short, %d bytes", len(b))
+	}
+	if l := int(binary.BigEndian.Uint16(b[16:18])); l != len(b) {
+		return fmt.Errorf("update length %d does not match pdu length %d", l, len(b))
+	}
+	b = b[bgpHeaderLength:]
+	wl := int(binary.BigEndian.Uint16(b[0:2]))
+	if 2+wl+2 > len(b) {
+		return fmt.Errorf("invalid withdrawn routes length %d", wl)
+	}
+	al := int(binary.BigEndian.Uint16(b[2+wl : 2+wl+2]))
+	if 2+wl+2+al > len(b) {
+		return fmt.Errorf("invalid total path attribute length %d", al)
+	}
+	for a := b[2+wl+2 : 2+wl+2+al]; len(a) != 0; {
+		hl := 3
+		if a[0]&0x10 != 0 {
+			hl = 4
+		}
+		if len(a) < hl {
+			return fmt.Errorf("truncated path attribute header")
+		}
+		vl := int(a[2])
+		if hl == 4 {
+			vl = int(binary.BigEndian.Uint16(a[2:4]))
+		}
+		if hl+vl > len(a) {
+			return fmt.Errorf("invalid length %d of path attribute type %d", vl, a[1])
+		}
+		a = a[hl+vl:]
+	}
+
+	return nil
+}
+
+func ipv4Prefixes(b []byte, pathID bool) ([]string, error) {
+	routes, err := base.UnmarshalRoutes(b, pathID)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]string, 0, len(routes))
+	for _, r := range routes {
+		if r.Length > 32 {
+			return nil, fmt.Errorf("invalid ipv4 prefix length %d", r.Length)
+		}
+		a := make([]byte, 4)
+		copy(a, r.Prefix)
+		prefixes = append(prefixes, net.IP(a).String()+"/"+strconv.Itoa(int(r.Length)))
+	}
+
+	return prefixes, nil
+}
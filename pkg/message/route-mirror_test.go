@@ -0,0 +1,187 @@
+package message
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func bgpMessage(t byte, body []byte) []byte {
+	b := make([]byte, 19, 19+len(body))
+	for i := 0; i < 16; i++ {
+		b[i] = 0xff
+	}
+	binary.BigEndian.PutUint16(b[16:18], uint16(19+len(body)))
+	b[18] = t
+	return append(b, body...)
+}
+
+func TestProduceRouteMirrorMessage(t *testing.T) {
+	// Origin IGP, Next Hop 192.0.2.1, withdrawn 10.0.0.0/8, NLRI 192.0.2.0/24 and 198.51.100.128/25
+	update := bgpMessage(2, []byte{
+		0x00, 0x02, 0x08, 0x0a,
+		0x00, 0x0b, 0x40, 0x01, 0x01, 0x00, 0x40, 0x03, 0x04, 0xc0, 0x00, 0x02, 0x01,
+		0x18, 0xc0, 0x00, 0x02, 0x19, 0xc6, 0x33, 0x64, 0x80,
+	})
+	// Next Hop attribute length exceeds Total Path Attribute Length
+	malformed := bgpMessage(2, []byte{0x00, 0x00, 0x00, 0x04, 0x40, 0x03, 0x04, 0xc0})
+	// MP_REACH_NLRI of IPv6 Unicast 2001:db8::/32 with Next Hop 2001:db8::1, MP_UNREACH_NLRI of VPNv4 10.0.0.0/8 with RD 1:1
+	mp := bgpMessage(2, []byte{
+		0x00, 0x00,
+		0x00, 0x3b,
+		0x80, 0x0e, 0x1a, 0x00, 0x02, 0x01, 0x10,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x20, 0x20, 0x01, 0x0d, 0xb8,
+		0x80, 0x0f, 0x10, 0x00, 0x01, 0x80,
+		0x60, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0a,
+		0x40, 0x01, 0x01, 0x00,
+		0x40, 0x03, 0x04, 0xc0, 0x00, 0x02, 0x01,
+	})
+	// Next Hop length of MP_REACH_NLRI exceeds the attribute
+	truncatedMP := bgpMessage(2, []byte{0x00, 0x00, 0x00, 0x08, 0x80, 0x0e, 0x05, 0x00, 0x02, 0x01, 0x10, 0x20})
+	keepalive := bgpMessage(4, nil)
+	tests := []struct {
+		name    string
+		tlvs    []bmp.InformationalTLV
+		expect  []RouteMirror
+		hasBase bool
+	}{
+		{
+			name: "decoded update",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorInformationTLV, Information: []byte{0, 0}},
+				{InformationType: bmp.RouteMirrorBGPMessageTLV, Information: update},
+			},
+			expect: []RouteMirror{
+				{
+					ErroredPDU:     true,
+					BGPMessageType: 2,
+					Update: &MirroredUpdate{
+						Attributes:      []int{1, 3},
+						WithdrawnRoutes: []string{"10.0.0.0/8"},
+						NLRI:            []string{"192.0.2.0/24", "198.51.100.128/25"},
+					},
+				},
+			},
+			hasBase: true,
+		},
+		{
+			name: "malformed update",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorInformationTLV, Information: []byte{0, 0}},
+				{InformationType: bmp.RouteMirrorBGPMessageTLV, Information: malformed},
+			},
+			expect: []RouteMirror{
+				{
+					ErroredPDU:     true,
+					BGPMessageType: 2,
+					RawMessage:     hex.EncodeToString(malformed),
+					DecodeError:    "invalid length 4 of path attribute type 3",
+				},
+			},
+		},
+		{
+			name: "mp update",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorBGPMessageTLV, Information: mp},
+			},
+			expect: []RouteMirror{
+				{
+					BGPMessageType: 2,
+					Update: &MirroredUpdate{
+						Attributes:     []int{14, 15, 1, 3},
+						MPReachAFI:     2,
+						MPReachSAFI:    1,
+						MPReachNextHop: "2001:db8::1",
+						MPReachNLRI:    []string{"2001:db8::/32"},
+						MPUnreachAFI:   1,
+						MPUnreachSAFI:  128,
+						MPUnreachNLRI:  []string{"1:1 10.0.0.0/8"},
+					},
+				},
+			},
+			hasBase: true,
+		},
+		{
+			name: "truncated mp_reach_nlri",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorBGPMessageTLV, Information: truncatedMP},
+			},
+			expect: []RouteMirror{
+				{
+					BGPMessageType: 2,
+					RawMessage:     hex.EncodeToString(truncatedMP),
+					DecodeError:    "malformed MP_REACH_NLRI attribute: invalid next hop length 16 of MP_REACH_NLRI",
+				},
+			},
+		},
+		{
+			name: "not an update",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorBGPMessageTLV, Information: keepalive},
+			},
+			expect: []RouteMirror{
+				{
+					BGPMessageType: 4,
+					RawMessage:     hex.EncodeToString(keepalive),
+				},
+			},
+		},
+		{
+			name: "messages lost",
+			tlvs: []bmp.InformationalTLV{
+				{InformationType: bmp.RouteMirrorInformationTLV, Information: []byte{0, 1}},
+			},
+			expect: []RouteMirror{
+				{
+					MessagesLost: true,
+				},
+			},
+		},
+	}
+	ph := &bmp.PerPeerHeader{
+		PeerDistinguisher: make([]byte, 8),
+		PeerAddress:       []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 192, 0, 2, 2},
+		PeerAS:            65001,
+		PeerBGPID:         []byte{192, 0, 2, 2},
+		PeerTimestamp:     make([]byte, 8),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: &bmp.RouteMirror{TLV: tt.tlvs}})
+			if len(pub.msgs) != len(tt.expect) {
+				t.Fatalf("expected %d messages got %d", len(tt.expect), len(pub.msgs))
+			}
+			for i, r := range pub.msgs {
+				if r.t != bmp.RouteMirrorMsg {
+					t.Fatalf("expected message type %d got %d", bmp.RouteMirrorMsg, r.t)
+				}
+				var m RouteMirror
+				if err := json.Unmarshal(r.msg, &m); err != nil {
+					t.Fatalf("failed to unmarshal route mirroring message: %+v", err)
+				}
+				if m.RemoteIP != "192.0.2.2" || m.RemoteASN != 65001 || !m.IsIPv4 {
+					t.Errorf("route mirroring message is missing peer information: %+v", m)
+				}
+				if tt.hasBase && (m.Update == nil || m.Update.BaseAttributes == nil || m.Update.BaseAttributes.Nexthop != "192.0.2.1") {
+					t.Errorf("expected decoded base attributes, got %+v", m.Update)
+				}
+				if m.Update != nil {
+					m.Update.BaseAttributes = nil
+				}
+				m.RemoteIP, m.RemoteASN, m.IsIPv4, m.RemoteBGPID, m.PeerRD, m.PeerHash, m.Timestamp = "", 0, false, "", "", "", ""
+				if !reflect.DeepEqual(tt.expect[i], m) {
+					t.Logf("differences: %+v", deep.Equal(tt.expect[i], m))
+					t.Errorf("expected %+v got %+v", tt.expect[i], m)
+				}
+			}
+		})
+	}
+}
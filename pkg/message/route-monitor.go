@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/sbezverk/gobmp/pkg/bgp"
 	"github.com/sbezverk/gobmp/pkg/bmp"
+	"github.com/sbezverk/gobmp/pkg/pub"
 )
 
 const (
@@ -33,56 +34,130 @@ func (p *producer) produceRouteMonitorMessage(msg bmp.Message) {
 	if routeMonitorMsg.Update == nil {
 		return
 	}
-	attrType := uint8(0)
+	update := routeMonitorMsg.Update
+	ph := msg.PeerHeader
+	caps := p.updateCapabilities(ph)
+	setUpdateAS4(update, ph)
+	p.setTypedCommunities(update)
+	addPath, multiLabel := routeMonitorCapabilities(caps, routeMonitorMsg)
+	// All sections of the update carrying NLRIs are processed in the order of legacy withdrawn routes,
+	// MP_UNREACH_NLRI, MP_REACH_NLRI and legacy NLRI, index counts NLRIs of all sections in the same order
+	// and identifies NLRIs in Path Marking TLVs.
 	index := 0
-	if len(routeMonitorMsg.Update.PathAttributes) != 0 {
-		// If PathAttribute is present in Update, then take the value of Attribute Type
-		attrType, index = routeMonitorMsg.Update.GetNLRIType()
-	}
-	// Using first attribute type to select which nlri processor to call
-	switch attrType {
-	case 14:
-		nlri, err := bgp.UnmarshalMPReachNLRI(routeMonitorMsg.Update.PathAttributes[index].Attribute, routeMonitorMsg.Update.HasPrefixSID(), p.addPathCapable)
-		if err != nil {
-			glog.Errorf("failed to process MP_REACH_NLRI with error: %+v", err)
+	if len(update.WithdrawnRoutes) != 0 {
+		index += p.processNLRI(DelPrefix, ph, routeMonitorMsg, addPath, index)
+	}
+	for _, attr := range update.PathAttributes {
+		if attr.AttributeType != bgp.MP_UNREACH_NLRI {
+			continue
 		}
-		p.processMPUpdate(nlri, AddPrefix, msg.PeerHeader, routeMonitorMsg.Update)
-	case 15:
-		// MP_UNREACH_NLRI
-		nlri, err := bgp.UnmarshalMPUnReachNLRI(routeMonitorMsg.Update.PathAttributes[index].Attribute, p.addPathCapable)
+		nlri, err := bgp.UnmarshalMPUnReachNLRI(attr.Attribute, addPath, multiLabel)
 		if err != nil {
 			glog.Errorf("failed to process MP_UNREACH_NLRI with error: %+v", err)
+			continue
 		}
-		p.processMPUpdate(nlri, DelPrefix, msg.PeerHeader, routeMonitorMsg.Update)
-	default:
-		t := bmp.UnicastPrefixMsg
-		if p.splitAF {
-			t = bmp.UnicastPrefixV4Msg
-		}
-		// Original BGP's NLRI messages processing
-		msgs := make([]*UnicastPrefix, 0)
-		if routeMonitorMsg.Update.WithdrawnRoutesLength != 0 {
-			msg, err := p.nlri(DelPrefix, msg.PeerHeader, routeMonitorMsg.Update)
-			if err != nil {
-				glog.Errorf("failed to produce original NLRI Withdraw message with error: %+v", err)
-				return
-			}
-			msgs = append(msgs, msg...)
+		index += p.processMPUpdate(nlri, DelPrefix, ph, routeMonitorMsg, index)
+	}
+	for _, attr := range update.PathAttributes {
+		if attr.AttributeType != bgp.MP_REACH_NLRI {
+			continue
 		}
-		msg, err := p.nlri(AddPrefix, msg.PeerHeader, routeMonitorMsg.Update)
+		nlri, err := bgp.UnmarshalMPReachNLRI(attr.Attribute, update.HasPrefixSID(), addPath, multiLabel)
 		if err != nil {
-			glog.Errorf("failed to produce original NLRI Withdraw message with error: %+v", err)
-			return
+			glog.Errorf("failed to process MP_REACH_NLRI with error: %+v", err)
+			continue
+		}
+		index += p.processMPUpdate(nlri, AddPrefix, ph, routeMonitorMsg, index)
+	}
+	// Update without withdrawn routes, path attributes and NLRI is End-of-RIB of IPv4 Unicast
+	if len(update.NLRI) != 0 || (len(update.WithdrawnRoutes) == 0 && len(update.PathAttributes) == 0) {
+		p.processNLRI(AddPrefix, ph, routeMonitorMsg, addPath, index)
+	}
+}
+
+// processNLRI produces Unicast Prefix messages from legacy withdrawn routes or NLRI of the update, index is
+// the index of the first NLRI in Path Marking TLVs. The number of processed NLRIs is returned.
+func (p *producer) processNLRI(op int, ph *bmp.PerPeerHeader, rm *bmp.RouteMonitor, addPath map[int]bool, index int) int {
+	msgs, err := p.nlri(op, ph, rm.Update, addPath)
+	if err != nil {
+		glog.Errorf("failed to produce original NLRI messages with error: %+v", err)
+		return 0
+	}
+	n := 0
+	for _, m := range msgs {
+		if m.IsEOR {
+			continue
+		}
+		if op == AddPrefix {
+			m.PathStatus = pathStatus(rm, index+n)
 		}
-		msgs = append(msgs, msg...)
-		// Loop through and publish all collected messages
-		for _, m := range msgs {
-			if err := p.marshalAndPublish(&m, t, []byte(m.RouterHash), false); err != nil {
-				glog.Errorf("failed to process Unicast Prefix message with error: %+v", err)
-				return
-			}
+		n++
+	}
+	t := bmp.UnicastPrefixMsg
+	if p.splitAF {
+		t = bmp.UnicastPrefixV4Msg
+	}
+	for _, m := range msgs {
+		if err := p.marshalAndPublishRoute(ph, &m, t, []byte(m.RouterHash)); err != nil {
+			glog.Errorf("failed to process Unicast Prefix message with error: %+v", err)
+			break
 		}
 	}
+
+	return n
+}
+
+// routeMonitorCapabilities returns NLRI types carrying Path Identifier and NLRI types which may carry more than
+// one label in Updates of the peer, when Route Monitoring message carries Stateless Parsing TLVs, their flags take
+// precedence over the capabilities of the peer. When capabilities of the peer are not known, NLRIs do not carry
+// Path Identifier and nil multiLabel is returned.
+func routeMonitorCapabilities(caps *bgp.UpdateCapabilities, rm *bmp.RouteMonitor) (addPath, multiLabel map[int]bool) {
+	if caps != nil {
+		addPath, multiLabel = caps.AddPath, caps.MultiLabel
+	}
+	sp := rm.StatelessParsing()
+	if len(sp) == 0 {
+		return addPath, multiLabel
+	}
+	addPath = copyNLRITypes(addPath)
+	if multiLabel != nil {
+		multiLabel = copyNLRITypes(multiLabel)
+	}
+	for _, s := range sp {
+		t := bgp.NLRIMessageType(s.AFI, s.SAFI)
+		addPath[t] = s.AddPath
+		if multiLabel != nil {
+			multiLabel[t] = s.MultipleLabels
+		}
+	}
+
+	return addPath, multiLabel
+}
+
+func copyNLRITypes(m map[int]bool) map[int]bool {
+	c := make(map[int]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// pathStatus returns the status of NLRI with the index from Path Marking TLVs of Route Monitoring message
+func pathStatus(rm *bmp.RouteMonitor, index int) *PathStatus {
+	pm := rm.PathMarking(index)
+	if pm == nil {
+		return nil
+	}
+	ps := &PathStatus{
+		Status:     pm.Status,
+		StatusText: pm.StatusStrings(),
+	}
+	if pm.HasReason {
+		r := pm.Reason
+		ps.Reason = &r
+	}
+
+	return ps
 }
 
 func (p *producer) marshalAndPublish(msg interface{}, msgType int, hash []byte, debug bool) error {
@@ -98,3 +173,21 @@ func (p *producer) marshalAndPublish(msg interface{}, msgType int, hash []byte,
 	}
 	return nil
 }
+
+// marshalAndPublishRoute publishes a route message of the peer, when table topics are enabled, messages
+// of Loc-RIB instances with known table name are published to the topic of the table.
+func (p *producer) marshalAndPublishRoute(ph *bmp.PerPeerHeader, msg interface{}, msgType int, hash []byte) error {
+	table := p.tableName(ph)
+	tp, ok := p.publisher.(pub.TablePublisher)
+	if !p.tableTopics || table == "" || !ok {
+		return p.marshalAndPublish(msg, msgType, hash, false)
+	}
+	j, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal a message of type %d with error: %+v", msgType, err)
+	}
+	if err := tp.PublishTableMessage(msgType, table, hash, j); err != nil {
+		return fmt.Errorf("failed to push a message of type %d for table %s with error: %+v", msgType, table, err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+func TestRouteMonitorTLVs(t *testing.T) {
+	reason := uint16(7)
+	tests := []struct {
+		name   string
+		nlri   []byte
+		tlvs   []bmp.RouteMonitorTLV
+		expect []UnicastPrefix
+	}{
+		{
+			name: "path marking of second nlri",
+			nlri: []byte{8, 10, 16, 10, 1},
+			tlvs: []bmp.RouteMonitorTLV{
+				{Type: bmp.RouteMonitorPathMarkingTLV, Index: 1, Value: []byte{0, 0, 0, 0x12, 0, 7}},
+			},
+			expect: []UnicastPrefix{
+				{Prefix: "10.0.0.0", PrefixLen: 8},
+				{Prefix: "10.1.0.0", PrefixLen: 16, PathStatus: &PathStatus{Status: 0x12, StatusText: []string{"best", "backup"}, Reason: &reason}},
+			},
+		},
+		{
+			name: "path marking of group",
+			nlri: []byte{8, 10, 16, 10, 1},
+			tlvs: []bmp.RouteMonitorTLV{
+				{Type: bmp.RouteMonitorGroupTLV, Index: 0x8000, Value: []byte{0, 0, 0, 1}},
+				{Type: bmp.RouteMonitorPathMarkingTLV, Index: 0x8000, Value: []byte{0, 0, 0, 0x08}},
+			},
+			expect: []UnicastPrefix{
+				{Prefix: "10.0.0.0", PrefixLen: 8, PathStatus: &PathStatus{Status: 0x08, StatusText: []string{"primary"}}},
+				{Prefix: "10.1.0.0", PrefixLen: 16, PathStatus: &PathStatus{Status: 0x08, StatusText: []string{"primary"}}},
+			},
+		},
+		{
+			name: "stateless parsing add-path",
+			nlri: []byte{0, 0, 0, 5, 8, 10},
+			tlvs: []bmp.RouteMonitorTLV{
+				{Type: bmp.RouteMonitorStatelessParsingTLV, Value: []byte{0, 1, 1, bmp.StatelessParsingAddPath}},
+			},
+			expect: []UnicastPrefix{
+				{Prefix: "10.0.0.0", PrefixLen: 8, PathID: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+			p.producingWorker(bmp.Message{
+				PeerHeader: testPeerHeader(t, 0, 0, 0),
+				Payload: &bmp.RouteMonitor{
+					Update: &bgp.Update{NLRI: tt.nlri, BaseAttributes: &bgp.BaseAttributes{}},
+					TLV:    tt.tlvs,
+				},
+			})
+			if len(pub.msgs) != len(tt.expect) {
+				t.Fatalf("expected %d messages got %d", len(tt.expect), len(pub.msgs))
+			}
+			for i, e := range tt.expect {
+				var u UnicastPrefix
+				if err := json.Unmarshal(pub.msgs[i].msg, &u); err != nil {
+					t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+				}
+				if u.Prefix != e.Prefix || u.PrefixLen != e.PrefixLen || u.PathID != e.PathID {
+					t.Errorf("expected prefix %s/%d path id %d got %s/%d path id %d", e.Prefix, e.PrefixLen, e.PathID, u.Prefix, u.PrefixLen, u.PathID)
+				}
+				if !reflect.DeepEqual(u.PathStatus, e.PathStatus) {
+					t.Errorf("expected path status %+v got %+v", e.PathStatus, u.PathStatus)
+				}
+			}
+		})
+	}
+}
+
+// testMixedUpdate returns BGP Update built from legacy withdrawn routes, path attributes and legacy NLRI
+func testMixedUpdate(t *testing.T, withdrawn, attrs, nlri []byte) *bgp.Update {
+	b := []byte{byte(len(withdrawn) >> 8), byte(len(withdrawn))}
+	b = append(b, withdrawn...)
+	b = append(b, byte(len(attrs)>>8), byte(len(attrs)))
+	b = append(b, attrs...)
+	u, err := bgp.UnmarshalBGPUpdate(append(b, nlri...))
+	if err != nil {
+		t.Fatalf("failed to unmarshal update with error: %+v", err)
+	}
+	return u
+}
+
+func TestRouteMonitorMixedUpdate(t *testing.T) {
+	base := []byte{
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 0, // AS_PATH
+	}
+	// MP_UNREACH_NLRI of IPv6 Unicast withdrawing 2001:db8::/32
+	unreach := []byte{0x80, 15, 8, 0, 2, 1, 32, 0x20, 0x01, 0x0d, 0xb8}
+	// MP_REACH_NLRI of IPv6 Unicast advertising 2001:db9::/32 with next hop 2001:db8::1
+	reach := []byte{0x80, 14, 26, 0, 2, 1, 16, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 32, 0x20, 0x01, 0x0d, 0xb9}
+	type prefix struct {
+		action string
+		prefix string
+		eor    bool
+		marked bool
+	}
+	tests := []struct {
+		name      string
+		withdrawn []byte
+		attrs     []byte
+		nlri      []byte
+		tlvs      []bmp.RouteMonitorTLV
+		expect    []prefix
+	}{
+		{
+			name:      "legacy withdrawn routes and nlri",
+			withdrawn: []byte{8, 10},
+			attrs:     base,
+			nlri:      []byte{16, 10, 1},
+			expect: []prefix{
+				{action: "del", prefix: "10.0.0.0/8"},
+				{action: "add", prefix: "10.1.0.0/16"},
+			},
+		},
+		{
+			name:  "mp_reach_nlri before mp_unreach_nlri",
+			attrs: append(append(append([]byte{}, base...), reach...), unreach...),
+			expect: []prefix{
+				{action: "del", prefix: "2001:db8::/32"},
+				{action: "add", prefix: "2001:db9::/32"},
+			},
+		},
+		{
+			name:      "legacy and mp sections",
+			withdrawn: []byte{8, 10},
+			attrs:     append(append(append([]byte{}, base...), unreach...), reach...),
+			nlri:      []byte{16, 10, 1},
+			tlvs: []bmp.RouteMonitorTLV{
+				{Type: bmp.RouteMonitorPathMarkingTLV, Index: 2, Value: []byte{0, 0, 0, 0x02}},
+				{Type: bmp.RouteMonitorPathMarkingTLV, Index: 3, Value: []byte{0, 0, 0, 0x02}},
+			},
+			expect: []prefix{
+				{action: "del", prefix: "10.0.0.0/8"},
+				{action: "del", prefix: "2001:db8::/32"},
+				{action: "add", prefix: "2001:db9::/32", marked: true},
+				{action: "add", prefix: "10.1.0.0/16", marked: true},
+			},
+		},
+		{
+			name:      "legacy withdrawn routes only",
+			withdrawn: []byte{8, 10},
+			expect: []prefix{
+				{action: "del", prefix: "10.0.0.0/8"},
+			},
+		},
+		{
+			name: "end of rib",
+			expect: []prefix{
+				{action: "add", eor: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+			p.producingWorker(bmp.Message{
+				PeerHeader: testPeerHeader(t, 0, 0, 0),
+				Payload: &bmp.RouteMonitor{
+					Update: testMixedUpdate(t, tt.withdrawn, tt.attrs, tt.nlri),
+					TLV:    tt.tlvs,
+				},
+			})
+			if len(pub.msgs) != len(tt.expect) {
+				t.Fatalf("expected %d messages got %d", len(tt.expect), len(pub.msgs))
+			}
+			for i, e := range tt.expect {
+				if pub.msgs[i].t != bmp.UnicastPrefixMsg {
+					t.Errorf("expected message type %d got %d", bmp.UnicastPrefixMsg, pub.msgs[i].t)
+				}
+				var u UnicastPrefix
+				if err := json.Unmarshal(pub.msgs[i].msg, &u); err != nil {
+					t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+				}
+				got := prefix{action: u.Action, eor: u.IsEOR, marked: u.PathStatus != nil}
+				if !u.IsEOR {
+					got.prefix = fmt.Sprintf("%s/%d", u.Prefix, u.PrefixLen)
+				}
+				if got != e {
+					t.Errorf("expected message %d %+v got %+v", i, e, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteMonitorTypedCommunities(t *testing.T) {
+	attrs := []byte{
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 0, // AS_PATH
+		0xc0, 8, 4, 0xfd, 0xe8, 0, 100, // COMMUNITIES 65000:100
+	}
+	tests := []struct {
+		name             string
+		typedCommunities bool
+		expect           []bgp.Community
+	}{
+		{
+			name: "strings only",
+		},
+		{
+			name:             "typed communities",
+			typedCommunities: true,
+			expect:           []bgp.Community{{ASN: 65000, Value: 100}},
+		},
+	}
+	hashes := make(map[string]struct{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, tt.typedCommunities, nil, "", "", "").(*producer)
+			p.producingWorker(bmp.Message{
+				PeerHeader: testPeerHeader(t, 0, 0, 0),
+				Payload:    &bmp.RouteMonitor{Update: testMixedUpdate(t, nil, attrs, []byte{8, 10})},
+			})
+			if len(pub.msgs) != 1 {
+				t.Fatalf("expected a single message got %d", len(pub.msgs))
+			}
+			var u UnicastPrefix
+			if err := json.Unmarshal(pub.msgs[0].msg, &u); err != nil {
+				t.Fatalf("failed to unmarshal unicast prefix message: %+v", err)
+			}
+			if !reflect.DeepEqual(u.BaseAttributes.CommunityList, []string{"65000:100"}) {
+				t.Errorf("expected community list [65000:100] got %+v", u.BaseAttributes.CommunityList)
+			}
+			if !reflect.DeepEqual(u.BaseAttributes.Communities, tt.expect) {
+				t.Errorf("expected communities %+v got %+v", tt.expect, u.BaseAttributes.Communities)
+			}
+			hashes[u.BaseAttributes.BaseAttrHash] = struct{}{}
+		})
+	}
+	if len(hashes) != 1 {
+		t.Errorf("expected the same hash of base attributes regardless of typed communities got %d hashes", len(hashes))
+	}
+}
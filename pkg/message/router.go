@@ -0,0 +1,69 @@
+package message
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+// Information TLV types of Initiation message per rfc7854
+const (
+	initString   = 0
+	initSysDescr = 1
+	initSysName  = 2
+)
+
+// produceRouterMessage produces Router message from BMP Initiation or Termination message, sysName
+// of the router is kept to be set in Peer messages.
+func (p *producer) produceRouterMessage(msg bmp.Message) {
+	ts := msg.Received
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	m := Router{
+		Timestamp: ts.UTC().Format(time.RFC3339Nano),
+	}
+	switch obj := msg.Payload.(type) {
+	case *bmp.InitiationMessage:
+		m.Action = "init"
+		var data []string
+		for _, tlv := range obj.TLV {
+			switch tlv.InformationType {
+			case initString:
+				data = append(data, string(tlv.Information))
+			case initSysDescr:
+				m.Description = string(tlv.Information)
+			case initSysName:
+				p.sysName = string(tlv.Information)
+			}
+		}
+		m.InitData = strings.Join(data, "\n")
+	case *bmp.TerminationMessage:
+		m.Action = "term"
+		var data []string
+		for _, tlv := range obj.TLV {
+			if tlv.InformationType == 0 {
+				data = append(data, string(tlv.Information))
+			}
+		}
+		m.TermData = strings.Join(data, "\n")
+		if reason, ok := obj.Reason(); ok {
+			m.TermCode = &reason
+			m.TermReason = bmp.TerminationReasonString(reason)
+		}
+	default:
+		glog.Errorf("got invalid Payload type %T, cannot construct Router message", msg.Payload)
+		return
+	}
+	m.Name = p.name()
+	m.RouterIP = p.speakerIP
+	m.RouterHash = p.speakerHash
+	m.RouterIdentity = p.routerIdentity
+	glog.Infof("Router %s msg from %s name: %s", m.Action, m.RouterIP, m.Name)
+	if err := p.marshalAndPublish(&m, bmp.RouterMsg, []byte(m.RouterHash), false); err != nil {
+		glog.Errorf("failed to process router message with error: %+v", err)
+		return
+	}
+}
@@ -0,0 +1,185 @@
+package message
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bmp"
+)
+
+type recorded struct {
+	t   int
+	msg []byte
+}
+
+type testPublisher struct {
+	msgs []recorded
+}
+
+func (tp *testPublisher) PublishMessage(t int, key []byte, msg []byte) error {
+	tp.msgs = append(tp.msgs, recorded{t: t, msg: msg})
+	return nil
+}
+
+func (tp *testPublisher) Stop() {}
+
+func TestProduceRouterMessage(t *testing.T) {
+	reason := uint16(bmp.TerminationReasonOutOfResources)
+	tests := []struct {
+		name    string
+		payload interface{}
+		expect  Router
+	}{
+		{
+			name: "initiation",
+			payload: &bmp.InitiationMessage{
+				TLV: []bmp.InformationalTLV{
+					{InformationType: 0, Information: []byte("first")},
+					{InformationType: 1, Information: []byte("router OS 1.0")},
+					{InformationType: 2, Information: []byte("r1")},
+					{InformationType: 0, Information: []byte("second")},
+				},
+			},
+			expect: Router{
+				Action:      "init",
+				Name:        "r1",
+				Description: "router OS 1.0",
+				InitData:    "first\nsecond",
+			},
+		},
+		{
+			name: "termination",
+			payload: &bmp.TerminationMessage{
+				TLV: []bmp.InformationalTLV{
+					{InformationType: 0, Information: []byte("bye")},
+					{InformationType: 1, Information: []byte{0, 2}},
+				},
+			},
+			expect: Router{
+				Action:     "term",
+				TermCode:   &reason,
+				TermReason: "Out of resources",
+				TermData:   "bye",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, "", "", "CN=r1").(*producer)
+			p.producingWorker(bmp.Message{Payload: tt.payload})
+			if len(pub.msgs) != 1 || pub.msgs[0].t != bmp.RouterMsg {
+				t.Fatalf("expected a single router message got %+v", pub.msgs)
+			}
+			var m Router
+			if err := json.Unmarshal(pub.msgs[0].msg, &m); err != nil {
+				t.Fatalf("failed to unmarshal router message: %+v", err)
+			}
+			if m.Timestamp == "" {
+				t.Errorf("router message is missing timestamp")
+			}
+			m.Timestamp = ""
+			tt.expect.RouterIdentity = "CN=r1"
+			b1, _ := json.Marshal(&m)
+			b2, _ := json.Marshal(&tt.expect)
+			if string(b1) != string(b2) {
+				t.Errorf("expected router message %s got %s", string(b2), string(b1))
+			}
+		})
+	}
+}
+
+func TestPeerNameFromSysName(t *testing.T) {
+	pub := &testPublisher{}
+	p := NewProducer(pub, false, false, false, nil, "", "", "").(*producer)
+	p.producingWorker(bmp.Message{Payload: &bmp.InitiationMessage{
+		TLV: []bmp.InformationalTLV{{InformationType: 2, Information: []byte("r1")}},
+	}})
+	p.peers["0:0|192.0.2.1"] = &PeerStateChange{Action: "down", RemoteIP: "192.0.2.1"}
+	p.ProducePeersDown("session closed")
+	if len(pub.msgs) != 2 {
+		t.Fatalf("expected router and peer messages got %d", len(pub.msgs))
+	}
+	var m PeerStateChange
+	if err := json.Unmarshal(pub.msgs[1].msg, &m); err != nil {
+		t.Fatalf("failed to unmarshal peer message: %+v", err)
+	}
+	if m.Name != "r1" {
+		t.Errorf("expected peer message name \"r1\" got %q", m.Name)
+	}
+}
+
+func TestRouterIdentity(t *testing.T) {
+	hash := func(s string) string { return fmt.Sprintf("%x", md5.Sum([]byte(s))) }
+	tests := []struct {
+		name       string
+		routerIP   string
+		routerName string
+		expectIP   string
+		expectName string
+	}{
+		{
+			name:       "session address and sysName",
+			routerIP:   "192.0.2.1",
+			expectIP:   "192.0.2.1",
+			expectName: "r1",
+		},
+		{
+			name:       "configured name",
+			routerIP:   "192.0.2.1",
+			routerName: "edge1",
+			expectIP:   "192.0.2.1",
+			expectName: "edge1",
+		},
+		{
+			name:       "unknown session address",
+			expectIP:   "0.0.0.0",
+			expectName: "r1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &testPublisher{}
+			p := NewProducer(pub, false, false, false, nil, tt.routerIP, tt.routerName, "").(*producer)
+			ph := testPeerHeader(t, 0, 0, 0)
+			// Stats are received before any Peer Up and before Initiation
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: &bmp.StatsReport{
+				StatsCount: 1,
+				StatsTLV:   []bmp.InformationalTLV{{InformationType: 1, Information: []byte{0, 0, 0, 1}}},
+			}})
+			p.producingWorker(bmp.Message{Payload: &bmp.InitiationMessage{
+				TLV: []bmp.InformationalTLV{{InformationType: 2, Information: []byte("r1")}},
+			}})
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testPeerUp("")})
+			pu := testPeerUp("")
+			pu.LocalAddress[15] = 9
+			p.producingWorker(bmp.Message{PeerHeader: testPeerHeader(t, 0, 0, 1), Payload: pu})
+			p.producingWorker(bmp.Message{PeerHeader: ph, Payload: testUpdate()})
+			if len(pub.msgs) != 5 {
+				t.Fatalf("expected 5 messages got %d", len(pub.msgs))
+			}
+			for i, r := range pub.msgs {
+				var m struct {
+					RouterIP   string `json:"router_ip"`
+					RouterHash string `json:"router_hash"`
+					Name       string `json:"name"`
+				}
+				if err := json.Unmarshal(r.msg, &m); err != nil {
+					t.Fatalf("failed to unmarshal message: %+v", err)
+				}
+				if tt.routerIP == "" && i < 2 {
+					// Router address is not known before the first Peer Up
+					continue
+				}
+				if m.RouterIP != tt.expectIP || m.RouterHash != hash(tt.expectIP) {
+					t.Errorf("message %d of type %d: expected router %s got %s hash %s", i, r.t, tt.expectIP, m.RouterIP, m.RouterHash)
+				}
+				if (r.t == bmp.RouterMsg || r.t == bmp.PeerStateChangeMsg) && m.Name != tt.expectName {
+					t.Errorf("message %d of type %d: expected name %q got %q", i, r.t, tt.expectName, m.Name)
+				}
+			}
+		})
+	}
+}
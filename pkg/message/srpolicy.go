@@ -6,6 +6,7 @@ import (
 	"github.com/sbezverk/gobmp/pkg/bgp"
 	"github.com/sbezverk/gobmp/pkg/bmp"
 	"github.com/sbezverk/gobmp/pkg/srpolicy"
+	"github.com/sbezverk/gobmp/pkg/tunnelencap"
 )
 
 // evpn process MP_REACH_NLRI AFI 25 SAFI 70 update message and returns
@@ -28,6 +29,7 @@ func (p *producer) srpolicy(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 		Action:         operation,
 		RouterHash:     p.speakerHash,
 		RouterIP:       p.speakerIP,
+		RouterIdentity: p.routerIdentity,
 		PeerType:       uint8(ph.PeerType),
 		PeerHash:       ph.GetPeerHash(),
 		PeerASN:        ph.PeerAS,
@@ -44,10 +46,8 @@ func (p *producer) srpolicy(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 	if f, err := ph.IsLocRIBFiltered(); err == nil {
 		prfx.IsLocRIBFiltered = f
 	}
-	if ases := update.BaseAttributes.ASPath; len(ases) != 0 {
-		// Last element in AS_PATH would be the AS of the origin
-		prfx.OriginAS = ases[len(ases)-1]
-	}
+	prfx.TableName = p.tableName(ph)
+	prfx.OriginAS = update.BaseAttributes.OriginAS
 	prfx.PeerIP = ph.GetPeerAddrString()
 	prfx.IsIPv4 = true
 	prfx.IsNexthopIPv4 = true
@@ -60,11 +60,7 @@ func (p *producer) srpolicy(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 	prfx.Endpoint = make([]byte, len(sr.Endpoint))
 	copy(prfx.Endpoint, sr.Endpoint)
 	// Getting SR Policy TLV encapsulated into Tunnel Encapsulate Attribute of type 15
-	tlv, err := srpolicy.UnmarshalSRPolicyTLV(update.BaseAttributes.TunnelEncapAttr)
-	if err != nil {
-		return nil, err
-	}
-	if tlv != nil {
+	if tlv := srPolicyTLV(update.BaseAttributes); tlv != nil {
 		prfx.PolicyName = tlv.Name
 		if tlv.BindingSID != nil {
 			prfx.BSID = tlv.BindingSID
@@ -84,3 +80,14 @@ func (p *producer) srpolicy(nlri bgp.MPNLRI, op int, ph *bmp.PerPeerHeader, upda
 
 	return []*SRPolicy{&prfx}, nil
 }
+
+// srPolicyTLV returns SR Policy sub TLVs carried by the tunnel of type 15 of decoded Tunnel Encapsulation attribute
+func srPolicyTLV(ba *bgp.BaseAttributes) *srpolicy.TLV {
+	for _, t := range ba.TunnelEncap {
+		if t.Type == tunnelencap.SRPolicy {
+			return t.SRPolicy
+		}
+	}
+
+	return nil
+}
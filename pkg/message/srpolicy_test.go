@@ -0,0 +1,55 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sbezverk/gobmp/pkg/bgp"
+	"github.com/sbezverk/gobmp/pkg/srpolicy"
+	"github.com/sbezverk/gobmp/pkg/tunnelencap"
+)
+
+func TestSRPolicyTLV(t *testing.T) {
+	srPolicyTunnel := []byte{0, tunnelencap.SRPolicy, 0, 8, 12, 6, 0, 0, 0, 0, 0, 100}
+	tests := []struct {
+		name   string
+		attr   []byte
+		expect *srpolicy.TLV
+	}{
+		{
+			name: "sr policy tunnel",
+			attr: srPolicyTunnel,
+			expect: &srpolicy.TLV{
+				Preference:  &srpolicy.Preference{Preference: 100},
+				SegmentList: []*srpolicy.SegmentList{},
+			},
+		},
+		{
+			name: "malformed tunnel preceding sr policy tunnel",
+			attr: append([]byte{0, tunnelencap.VXLAN, 0, 4, tunnelencap.UDPDestinationPortSTLV, 3, 0x12, 0xb5}, srPolicyTunnel...),
+			expect: &srpolicy.TLV{
+				Preference:  &srpolicy.Preference{Preference: 100},
+				SegmentList: []*srpolicy.SegmentList{},
+			},
+		},
+		{
+			name: "malformed tunnel without sr policy tunnel",
+			attr: []byte{0, tunnelencap.VXLAN, 0, 4, tunnelencap.UDPDestinationPortSTLV, 3, 0x12, 0xb5},
+		},
+		{
+			name: "truncated tunnel preceding sr policy tunnel",
+			attr: append([]byte{0, tunnelencap.VXLAN, 0, 40}, srPolicyTunnel...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ba, err := bgp.UnmarshalBGPBaseAttributes(append([]byte{0xc0, 23, byte(len(tt.attr))}, tt.attr...))
+			if err != nil {
+				t.Fatalf("failed to unmarshal base attributes: %+v", err)
+			}
+			if got := srPolicyTLV(ba); !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected sr policy %+v got %+v", tt.expect, got)
+			}
+		})
+	}
+}
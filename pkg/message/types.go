@@ -15,6 +15,28 @@ import (
 	"github.com/sbezverk/tools/sort"
 )
 
+// Router defines a message format sent as a result of BMP Initiation or Termination message
+type Router struct {
+	Key            string `json:"_key,omitempty"`
+	ID             string `json:"_id,omitempty"`
+	Rev            string `json:"_rev,omitempty"`
+	Action         string `json:"action,omitempty"` // Action can be "init" for Initiation and "term" for Termination message
+	Sequence       int    `json:"sequence,omitempty"`
+	Name           string `json:"name,omitempty"` // sysName of the router
+	RouterHash     string `json:"router_hash,omitempty"`
+	RouterIP       string `json:"router_ip,omitempty"`
+	RouterIdentity string `json:"router_identity,omitempty"`
+	Description    string `json:"description,omitempty"` // sysDescr of the router
+	// InitData carries free-form strings of Initiation message separated by new line
+	InitData string `json:"init_data,omitempty"`
+	// TermCode and TermReason are set when Termination message carries the Reason TLV
+	TermCode   *uint16 `json:"term_code,omitempty"`
+	TermReason string  `json:"term_reason,omitempty"`
+	// TermData carries free-form strings of Termination message separated by new line
+	TermData  string `json:"term_data,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
 // PeerStateChange defines a message format sent to as a result of BMP Peer Up or Peer Down message
 type PeerStateChange struct {
 	Key             string         `json:"_key,omitempty"`
@@ -27,6 +49,7 @@ type PeerStateChange struct {
 	Name            string         `json:"name,omitempty"`
 	RemoteBGPID     string         `json:"remote_bgp_id,omitempty"`
 	RouterIP        string         `json:"router_ip,omitempty"`
+	RouterIdentity  string         `json:"router_identity,omitempty"`
 	Timestamp       string         `json:"timestamp,omitempty"`
 	RemoteASN       uint32         `json:"remote_asn,omitempty"`
 	RemoteIP        string         `json:"remote_ip,omitempty"`
@@ -46,10 +69,24 @@ type PeerStateChange struct {
 	BMPErrorCode    int            `json:"bmp_error_code,omitempty"`
 	BMPErrorSubCode int            `json:"bmp_error_sub_code,omitempty"`
 	ErrorText       string         `json:"error_text,omitempty"`
-	IsL3VPN         bool           `json:"is_l"`
-	IsPrepolicy     bool           `json:"is_prepolicy"`
-	IsIPv4          bool           `json:"is_ipv4"`
-	TableName       string         `json:"table_name,omitempty"`
+	// Decoded values of capabilities advertised by the router and received from the peer, and capabilities
+	// advertised by both of them
+	AdvDecodedCapabilities *bgp.DecodedCapabilities    `json:"adv_cap_decoded,omitempty"`
+	RcvDecodedCapabilities *bgp.DecodedCapabilities    `json:"recv_cap_decoded,omitempty"`
+	NegotiatedCapabilities *bgp.NegotiatedCapabilities `json:"negotiated_cap,omitempty"`
+	// Peer Down reason and BGP Notification details decoded from BMP Peer Down message
+	BMPReasonText         string `json:"bmp_reason_text,omitempty"`
+	BMPErrorCodeText      string `json:"bmp_error_code_text,omitempty"`
+	BMPErrorSubCodeText   string `json:"bmp_error_sub_code_text,omitempty"`
+	ShutdownCommunication string `json:"shutdown_communication,omitempty"`
+	FSMEvent              int    `json:"fsm_event,omitempty"`
+	// CollectorGenerated is set for Peer Down messages generated by the collector when the BMP session
+	// with the router is lost.
+	CollectorGenerated bool   `json:"collector_generated,omitempty"`
+	IsL3VPN            bool   `json:"is_l"`
+	IsPrepolicy        bool   `json:"is_prepolicy"`
+	IsIPv4             bool   `json:"is_ipv4"`
+	TableName          string `json:"table_name,omitempty"`
 	// Values are assigned based on PerPeerHeader flas
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
@@ -67,6 +104,7 @@ type UnicastPrefix struct {
 	Hash           string              `json:"hash,omitempty"`
 	RouterHash     string              `json:"router_hash,omitempty"`
 	RouterIP       string              `json:"router_ip,omitempty"`
+	RouterIdentity string              `json:"router_identity,omitempty"`
 	BaseAttributes *bgp.BaseAttributes `json:"base_attrs,omitempty"`
 	PeerHash       string              `json:"peer_hash,omitempty"`
 	PeerIP         string              `json:"peer_ip,omitempty"`
@@ -87,6 +125,10 @@ type UnicastPrefix struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
+	// PathStatus is the path marking of the route carried by BMP Route Monitoring message
+	PathStatus *PathStatus `json:"path_status,omitempty"`
 }
 
 func (u *UnicastPrefix) Equal(ou *UnicastPrefix) (bool, []string) {
@@ -178,6 +220,13 @@ func (u *UnicastPrefix) Equal(ou *UnicastPrefix) (bool, []string) {
 	return equal, diffs
 }
 
+// PathStatus defines the status of the route carried by BMP Path Marking TLV
+type PathStatus struct {
+	Status     uint32   `json:"status"`
+	StatusText []string `json:"status_text,omitempty"`
+	Reason     *uint16  `json:"reason,omitempty"`
+}
+
 // LSNode defines a structure of LS Node message
 type LSNode struct {
 	Key                 string                          `json:"_key,omitempty"`
@@ -189,6 +238,7 @@ type LSNode struct {
 	RouterHash          string                          `json:"router_hash,omitempty"`
 	DomainID            int64                           `json:"domain_id"`
 	RouterIP            string                          `json:"router_ip,omitempty"`
+	RouterIdentity      string                          `json:"router_identity,omitempty"`
 	PeerHash            string                          `json:"peer_hash,omitempty"`
 	PeerIP              string                          `json:"peer_ip,omitempty"`
 	PeerType            uint8                           `json:"peer_type"`
@@ -214,6 +264,8 @@ type LSNode struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
 }
 
 // LSLink defines a structure of LS link message
@@ -226,6 +278,7 @@ type LSLink struct {
 	Hash                  string                        `json:"hash,omitempty"`
 	RouterHash            string                        `json:"router_hash,omitempty"`
 	RouterIP              string                        `json:"router_ip,omitempty"`
+	RouterIdentity        string                        `json:"router_identity,omitempty"`
 	DomainID              int64                         `json:"domain_id"`
 	PeerHash              string                        `json:"peer_hash,omitempty"`
 	PeerIP                string                        `json:"peer_ip,omitempty"`
@@ -285,6 +338,8 @@ type LSLink struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
 }
 
 // L3VPNPrefix defines the structure of Layer 3 VPN message
@@ -297,6 +352,7 @@ type L3VPNPrefix struct {
 	Hash           string              `json:"hash,omitempty"`
 	RouterHash     string              `json:"router_hash,omitempty"`
 	RouterIP       string              `json:"router_ip,omitempty"`
+	RouterIdentity string              `json:"router_identity,omitempty"`
 	BaseAttributes *bgp.BaseAttributes `json:"base_attrs,omitempty"`
 	PeerHash       string              `json:"peer_hash,omitempty"`
 	PeerIP         string              `json:"peer_ip,omitempty"`
@@ -319,6 +375,10 @@ type L3VPNPrefix struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
+	// PathStatus is the path marking of the route carried by BMP Route Monitoring message
+	PathStatus *PathStatus `json:"path_status,omitempty"`
 }
 
 // LSPrefix defines a structure of LS Prefix message
@@ -331,6 +391,7 @@ type LSPrefix struct {
 	Hash                 string                        `json:"hash,omitempty"`
 	RouterHash           string                        `json:"router_hash,omitempty"`
 	RouterIP             string                        `json:"router_ip,omitempty"`
+	RouterIdentity       string                        `json:"router_identity,omitempty"`
 	DomainID             int64                         `json:"domain_id"`
 	PeerHash             string                        `json:"peer_hash,omitempty"`
 	PeerIP               string                        `json:"peer_ip,omitempty"`
@@ -361,6 +422,8 @@ type LSPrefix struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
 }
 
 // LSSRv6SID defines a structure of LS SRv6 SID message
@@ -373,6 +436,7 @@ type LSSRv6SID struct {
 	Hash                 string                        `json:"hash,omitempty"`
 	RouterHash           string                        `json:"router_hash,omitempty"`
 	RouterIP             string                        `json:"router_ip,omitempty"`
+	RouterIdentity       string                        `json:"router_identity,omitempty"`
 	DomainID             int64                         `json:"domain_id"`
 	PeerHash             string                        `json:"peer_hash,omitempty"`
 	PeerIP               string                        `json:"peer_ip,omitempty"`
@@ -404,6 +468,8 @@ type LSSRv6SID struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
 }
 
 // EVPNPrefix defines the structure of EVPN message
@@ -416,6 +482,7 @@ type EVPNPrefix struct {
 	Hash           string              `json:"hash,omitempty"`
 	RouterHash     string              `json:"router_hash,omitempty"`
 	RouterIP       string              `json:"router_ip,omitempty"`
+	RouterIdentity string              `json:"router_identity,omitempty"`
 	BaseAttributes *bgp.BaseAttributes `json:"base_attrs,omitempty"`
 	PeerHash       string              `json:"peer_hash,omitempty"`
 	RemoteBGPID    string              `json:"remote_bgp_id,omitempty"`
@@ -448,6 +515,10 @@ type EVPNPrefix struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
+	// PathStatus is the path marking of the route carried by BMP Route Monitoring message
+	PathStatus *PathStatus `json:"path_status,omitempty"`
 }
 
 // SRPolicy defines the structure of SR Policy message
@@ -460,6 +531,7 @@ type SRPolicy struct {
 	Hash           string                  `json:"hash,omitempty"`
 	RouterHash     string                  `json:"router_hash,omitempty"`
 	RouterIP       string                  `json:"router_ip,omitempty"`
+	RouterIdentity string                  `json:"router_identity,omitempty"`
 	BaseAttributes *bgp.BaseAttributes     `json:"base_attrs,omitempty"`
 	PeerHash       string                  `json:"peer_hash,omitempty"`
 	PeerIP         string                  `json:"peer_ip,omitempty"`
@@ -487,6 +559,8 @@ type SRPolicy struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
 }
 
 // Flowspec defines the structure of SR Policy message
@@ -497,6 +571,7 @@ type Flowspec struct {
 	Action         string              `json:"action,omitempty"` // Action can be "add" or "del"
 	Sequence       int                 `json:"sequence,omitempty"`
 	RouterIP       string              `json:"router_ip,omitempty"`
+	RouterIdentity string              `json:"router_identity,omitempty"`
 	BaseAttributes *bgp.BaseAttributes `json:"base_attrs,omitempty"`
 	PeerIP         string              `json:"peer_ip,omitempty"`
 	PeerType       uint8               `json:"peer_type"`
@@ -513,6 +588,58 @@ type Flowspec struct {
 	IsAdjRIBInPost   bool `json:"is_adj_rib_in_post_policy"`
 	IsAdjRIBOutPost  bool `json:"is_adj_rib_out_post_policy"`
 	IsLocRIBFiltered bool `json:"is_loc_rib_filtered"`
+	// TableName is the VRF/Table name of the Loc-RIB instance
+	TableName string `json:"table_name,omitempty"`
+}
+
+// RouteMirror defines a message format sent as a result of BMP Route Mirroring message, a message is
+// sent for every mirrored BGP PDU, or a single message when Route Mirroring message carries only
+// Information TLVs.
+type RouteMirror struct {
+	Key            string `json:"_key,omitempty"`
+	ID             string `json:"_id,omitempty"`
+	Rev            string `json:"_rev,omitempty"`
+	Sequence       int    `json:"sequence,omitempty"`
+	RouterHash     string `json:"router_hash,omitempty"`
+	RouterIP       string `json:"router_ip,omitempty"`
+	RouterIdentity string `json:"router_identity,omitempty"`
+	PeerHash       string `json:"peer_hash,omitempty"`
+	PeerType       uint8  `json:"peer_type"`
+	PeerRD         string `json:"peer_rd,omitempty"`
+	RemoteBGPID    string `json:"remote_bgp_id,omitempty"`
+	RemoteASN      uint32 `json:"remote_asn,omitempty"`
+	RemoteIP       string `json:"remote_ip,omitempty"`
+	IsIPv4         bool   `json:"is_ipv4"`
+	Timestamp      string `json:"timestamp,omitempty"`
+	// ErroredPDU is set when the router found the mirrored PDU to be in error
+	ErroredPDU bool `json:"errored_pdu,omitempty"`
+	// MessagesLost is set when the router lost one or more messages which were not mirrored
+	MessagesLost bool `json:"messages_lost,omitempty"`
+	// BGPMessageType is the type of the mirrored BGP PDU, 2 for Update
+	BGPMessageType uint8 `json:"bgp_msg_type,omitempty"`
+	// Update is set when the mirrored BGP Update was decoded
+	Update *MirroredUpdate `json:"update,omitempty"`
+	// RawMessage is the hex encoded mirrored BGP PDU, it is set when the PDU was not decoded
+	RawMessage string `json:"raw_message,omitempty"`
+	// DecodeError is the reason the mirrored BGP Update was not decoded
+	DecodeError string `json:"decode_error,omitempty"`
+}
+
+// MirroredUpdate defines the decoded content of a mirrored BGP Update
+type MirroredUpdate struct {
+	BaseAttributes *bgp.BaseAttributes `json:"base_attrs,omitempty"`
+	// Attributes lists types of all Path Attributes in the order they appear in the Update
+	Attributes      []int    `json:"attributes,omitempty"`
+	WithdrawnRoutes []string `json:"withdrawn_routes,omitempty"`
+	NLRI            []string `json:"nlri,omitempty"`
+	MPReachAFI      uint16   `json:"mp_reach_afi,omitempty"`
+	MPReachSAFI     uint8    `json:"mp_reach_safi,omitempty"`
+	MPUnreachAFI    uint16   `json:"mp_unreach_afi,omitempty"`
+	MPUnreachSAFI   uint8    `json:"mp_unreach_safi,omitempty"`
+	// MPReachNLRI and MPUnreachNLRI list prefixes of IPv4 and IPv6 Unicast, Labeled Unicast and L3VPN NLRIs
+	MPReachNextHop string   `json:"mp_reach_nexthop,omitempty"`
+	MPReachNLRI    []string `json:"mp_reach_nlri,omitempty"`
+	MPUnreachNLRI  []string `json:"mp_unreach_nlri,omitempty"`
 }
 
 // Stats defines a message format sent to as a result of BMP Stats Message
@@ -523,6 +650,7 @@ type Stats struct {
 	Sequence                   int    `json:"sequence,omitempty"`
 	RouterHash                 string `json:"router_hash,omitempty"`
 	RouterIP                   string `json:"router_ip,omitempty"`
+	RouterIdentity             string `json:"router_identity,omitempty"`
 	PeerType                   uint8  `json:"peer_type"`
 	RemoteBGPID                string `json:"remote_bgp_id,omitempty"`
 	RemoteASN                  uint32 `json:"remote_asn,omitempty"`
@@ -539,4 +667,35 @@ type Stats struct {
 	LocalRib                   uint64 `json:"local_rib,omitempty"`
 	UpdatesAsWithdraw          uint32 `json:"updates_as_withdraw,omitempty"`
 	PrefixesAsWithdraw         uint32 `json:"prefixes_as_withdraw,omitempty"`
+	RejectedPrefixes           uint32 `json:"rejected_prefixes,omitempty"`
+	DuplicateUpdates           uint32 `json:"duplicate_updates,omitempty"`
+	AdjRIBOutPre               uint64 `json:"adj_rib_out_pre_policy,omitempty"`
+	AdjRIBOutPost              uint64 `json:"adj_rib_out_post_policy,omitempty"`
+	// PerAFISAFI carries per AFI/SAFI gauges, one entry per AFI/SAFI in the order of appearance
+	PerAFISAFI []*AFISAFIStats `json:"per_afi_safi,omitempty"`
+	// Unknown carries stats of not registered types and stats with unexpected length
+	Unknown []*RawStat `json:"unknown_stats,omitempty"`
+}
+
+// AFISAFIStats defines per AFI/SAFI gauges and counters of BMP Stats Report message
+type AFISAFIStats struct {
+	AFI           uint16 `json:"afi"`
+	SAFI          uint8  `json:"safi"`
+	AdjRIBIn      uint64 `json:"adj_rib_in,omitempty"`
+	LocalRib      uint64 `json:"local_rib,omitempty"`
+	AdjRIBOutPre  uint64 `json:"adj_rib_out_pre_policy,omitempty"`
+	AdjRIBOutPost uint64 `json:"adj_rib_out_post_policy,omitempty"`
+	// Counters of stat types 18 to 23
+	RejectedPrefixes  uint64 `json:"rejected_prefixes,omitempty"`
+	AcceptedPrefixes  uint64 `json:"accepted_prefixes,omitempty"`
+	DampedPrefixes    uint64 `json:"damped_prefixes,omitempty"`
+	StaleGRPrefixes   uint64 `json:"stale_gr_prefixes,omitempty"`
+	StaleLLGRPrefixes uint64 `json:"stale_llgr_prefixes,omitempty"`
+	PrefixesToLimit   uint64 `json:"prefixes_to_limit,omitempty"`
+}
+
+// RawStat defines a BMP Stats Report stat which is not decoded, the value is hex encoded
+type RawStat struct {
+	Type  uint16 `json:"type"`
+	Value string `json:"value,omitempty"`
 }
@@ -31,6 +31,9 @@ const (
 	flowspecMessageV4Topic = "gobmp.parsed.flowspec_v4"
 	flowspecMessageV6Topic = "gobmp.parsed.flowspec_v6"
 	statsMessageTopic      = "gobmp.parsed.statistics"
+	routerTopic            = "gobmp.parsed.router"
+	routeMirrorTopic       = "gobmp.parsed.mirroring"
+	bmpRawTopic            = "gobmp.bmp_raw"
 )
 
 var (
@@ -45,48 +48,74 @@ type publisher struct {
 }
 
 func (p *publisher) PublishMessage(t int, key []byte, msg []byte) error {
+	subject := subjectName(t)
+	if subject == "" {
+		return fmt.Errorf("not implemented")
+	}
+
+	return p.produceMessage(subject, key, msg)
+}
+
+// PublishTableMessage publishes a route message of a Loc-RIB instance to the subject dedicated to the table
+func (p *publisher) PublishTableMessage(t int, table string, key []byte, msg []byte) error {
+	subject := subjectName(t)
+	if subject == "" {
+		return fmt.Errorf("not implemented")
+	}
+
+	return p.produceMessage(pub.TableTopic(subject, table), key, msg)
+}
+
+// subjectName returns the subject of message type t, or empty string if the type is not supported
+func subjectName(t int) string {
 	switch t {
 	case bmp.PeerStateChangeMsg:
-		return p.produceMessage(peerTopic, key, msg)
+		return peerTopic
 	case bmp.UnicastPrefixMsg:
-		return p.produceMessage(unicastMessageTopic, key, msg)
+		return unicastMessageTopic
 	case bmp.UnicastPrefixV4Msg:
-		return p.produceMessage(unicastMessageV4Topic, key, msg)
+		return unicastMessageV4Topic
 	case bmp.UnicastPrefixV6Msg:
-		return p.produceMessage(unicastMessageV6Topic, key, msg)
+		return unicastMessageV6Topic
 	case bmp.LSNodeMsg:
-		return p.produceMessage(lsNodeMessageTopic, key, msg)
+		return lsNodeMessageTopic
 	case bmp.LSLinkMsg:
-		return p.produceMessage(lsLinkMessageTopic, key, msg)
+		return lsLinkMessageTopic
 	case bmp.L3VPNMsg:
-		return p.produceMessage(l3vpnMessageTopic, key, msg)
+		return l3vpnMessageTopic
 	case bmp.L3VPNV4Msg:
-		return p.produceMessage(l3vpnMessageV4Topic, key, msg)
+		return l3vpnMessageV4Topic
 	case bmp.L3VPNV6Msg:
-		return p.produceMessage(l3vpnMessageV6Topic, key, msg)
+		return l3vpnMessageV6Topic
 	case bmp.LSPrefixMsg:
-		return p.produceMessage(lsPrefixMessageTopic, key, msg)
+		return lsPrefixMessageTopic
 	case bmp.LSSRv6SIDMsg:
-		return p.produceMessage(lsSRv6SIDMessageTopic, key, msg)
+		return lsSRv6SIDMessageTopic
 	case bmp.EVPNMsg:
-		return p.produceMessage(evpnMessageTopic, key, msg)
+		return evpnMessageTopic
 	case bmp.SRPolicyMsg:
-		return p.produceMessage(srPolicyMessageTopic, key, msg)
+		return srPolicyMessageTopic
 	case bmp.SRPolicyV4Msg:
-		return p.produceMessage(srPolicyMessageV4Topic, key, msg)
+		return srPolicyMessageV4Topic
 	case bmp.SRPolicyV6Msg:
-		return p.produceMessage(srPolicyMessageV6Topic, key, msg)
+		return srPolicyMessageV6Topic
 	case bmp.FlowspecMsg:
-		return p.produceMessage(flowspecMessageTopic, key, msg)
+		return flowspecMessageTopic
 	case bmp.FlowspecV4Msg:
-		return p.produceMessage(flowspecMessageV4Topic, key, msg)
+		return flowspecMessageV4Topic
 	case bmp.FlowspecV6Msg:
-		return p.produceMessage(flowspecMessageV6Topic, key, msg)
+		return flowspecMessageV6Topic
 	case bmp.StatsReportMsg:
-		return p.produceMessage(statsMessageTopic, key, msg)
+		return statsMessageTopic
+	case bmp.RouterMsg:
+		return routerTopic
+	case bmp.RouteMirrorMsg:
+		return routeMirrorTopic
+	case bmp.BMPRawMsg:
+		return bmpRawTopic
 	}
 
-	return fmt.Errorf("not implemented")
+	return ""
 }
 
 func (p *publisher) produceMessage(subject string, key []byte, data []byte) error {
@@ -6,12 +6,13 @@ import (
 	"github.com/sbezverk/tools"
 )
 
-// Parser dispatches workers upon request received from the channel
+// Parser processes messages received from the channel strictly in the order of their arrival,
+// resulting BMP messages are sent to the producer queue in the same order.
 func Parser(queue chan []byte, producerQueue chan bmp.Message, stop chan struct{}) {
 	for {
 		select {
 		case msg := <-queue:
-			go parsingWorker(msg, producerQueue)
+			parsingWorker(msg, producerQueue)
 		case <-stop:
 			glog.Infof("received interrupt, stopping.")
 			return
@@ -19,7 +20,50 @@ func Parser(queue chan []byte, producerQueue chan bmp.Message, stop chan struct{
 	}
 }
 
+// Parse decodes all BMP messages found in the slice and sends them to the producer queue
+// in the order they appear in the slice. Parse returns only when all decoded messages
+// have been accepted by the producer queue. Decoded messages may reference the slice,
+// when done is not nil, it is attached to the last message sent to the producer queue,
+// so it gets called once the producer has processed all messages decoded from the slice.
+// If no message is sent to the producer queue, done is called before Parse returns.
+func Parse(b []byte, producerQueue chan bmp.Message, done func()) {
+	parse(b, queueEmitter(producerQueue), done)
+}
+
+// ParseFunc decodes all BMP messages found in the slice and passes them to emit in the order they
+// appear in the slice, done is attached to the last message the same way as by Parse.
+func ParseFunc(b []byte, emit func(bmp.Message), done func()) {
+	parse(b, emit, done)
+}
+
 func parsingWorker(b []byte, producerQueue chan bmp.Message) {
+	parse(b, queueEmitter(producerQueue), nil)
+}
+
+// queueEmitter returns a function sending messages to the producer queue, nil when the queue is nil
+func queueEmitter(producerQueue chan bmp.Message) func(bmp.Message) {
+	if producerQueue == nil {
+		return nil
+	}
+	return func(m bmp.Message) {
+		producerQueue <- m
+	}
+}
+
+func parse(b []byte, emit func(bmp.Message), done func()) {
+	// Last decoded message is held back until the next one is found, or until the slice is processed,
+	// to attach done callback to it.
+	var pending *bmp.Message
+	defer func() {
+		if pending != nil {
+			pending.Done = done
+			emit(*pending)
+			return
+		}
+		if done != nil {
+			done()
+		}
+	}()
 	perPerHeaderLen := 0
 	var bmpMsg bmp.Message
 	// Loop through all found Common Headers in the slice and process them
@@ -86,24 +130,34 @@ func parsingWorker(b []byte, producerQueue chan bmp.Message) {
 			}
 			p += perPerHeaderLen
 		case bmp.InitiationMsg:
-			if _, err := bmp.UnmarshalInitiationMessage(b[p : p+(int(ch.MessageLength)-bmp.CommonHeaderLength)]); err != nil {
+			if bmpMsg.Payload, err = bmp.UnmarshalInitiationMessage(b[p : p+(int(ch.MessageLength)-bmp.CommonHeaderLength)]); err != nil {
 				glog.Errorf("fail to recover BMP Initiation message with error: %+v", err)
 				return
 			}
 		case bmp.TerminationMsg:
-			glog.V(5).Infof("Termination message")
-			if glog.V(6) {
-				glog.Infof("Content: %s", tools.MessageHex(b))
+			if bmpMsg.Payload, err = bmp.UnmarshalTerminationMessage(b[p : p+(int(ch.MessageLength)-bmp.CommonHeaderLength)]); err != nil {
+				glog.Errorf("fail to recover BMP Termination message with error: %+v", err)
+				return
 			}
 		case bmp.RouteMirrorMsg:
-			glog.V(5).Infof("Route Mirroring message")
-			if glog.V(6) {
-				glog.Infof("Content:%s", tools.MessageHex(b))
+			if bmpMsg.PeerHeader, err = bmp.UnmarshalPerPeerHeader(b[p : p+int(ch.MessageLength-bmp.CommonHeaderLength)]); err != nil {
+				glog.Errorf("fail to recover BMP Per Peer Header with error: %+v", err)
+				return
 			}
+			perPerHeaderLen = bmp.PerPeerHeaderLength
+			if bmpMsg.Payload, err = bmp.UnmarshalRouteMirrorMessage(b[p+perPerHeaderLen : p+int(ch.MessageLength)-bmp.CommonHeaderLength]); err != nil {
+				glog.Errorf("fail to recover BMP Route Mirroring message with error: %+v", err)
+				return
+			}
+			p += perPerHeaderLen
 		}
 		p += (int(ch.MessageLength) - bmp.CommonHeaderLength)
-		if producerQueue != nil && bmpMsg.Payload != nil {
-			producerQueue <- bmpMsg
+		if emit != nil && bmpMsg.Payload != nil {
+			if pending != nil {
+				emit(*pending)
+			}
+			m := bmpMsg
+			pending = &m
 		}
 	}
 }
@@ -8,3 +8,21 @@ type Publisher interface {
 	PublishMessage(msgType int, msgHash []byte, msg []byte) error
 	Stop()
 }
+
+// TablePublisher is implemented by publishers able to publish route messages of a Loc-RIB instance
+// to a topic dedicated to the table, table is the VRF/Table name of the instance.
+type TablePublisher interface {
+	PublishTableMessage(msgType int, table string, msgHash []byte, msg []byte) error
+}
+
+// TableTopic returns the name of the topic dedicated to the table, the table name is appended
+// to the topic name with characters other than letters, digits, '-' and '_' replaced by '_'.
+func TableTopic(topic, table string) string {
+	b := []byte(table)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	return topic + "." + string(b)
+}
@@ -0,0 +1,29 @@
+package pub
+
+import "testing"
+
+func TestTableTopic(t *testing.T) {
+	tests := []struct {
+		name   string
+		table  string
+		expect string
+	}{
+		{
+			name:   "plain",
+			table:  "global",
+			expect: "gobmp.parsed.unicast_prefix_v4.global",
+		},
+		{
+			name:   "special characters",
+			table:  "vrf.blue/1 *",
+			expect: "gobmp.parsed.unicast_prefix_v4.vrf_blue_1__",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TableTopic("gobmp.parsed.unicast_prefix_v4", tt.table); got != tt.expect {
+				t.Errorf("expected topic %s got %s", tt.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package pub
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// OpenBMP binary header of raw BMP messages, version 1.7
+const (
+	// RawMagic defines the magic number "OBMP" of the header
+	RawMagic = 0x4F424D50
+	// RawMajorVersion and RawMinorVersion define the version of the header
+	RawMajorVersion = 1
+	RawMinorVersion = 7
+	// RawMessageType defines the type of the message carrying a raw BMP message
+	RawMessageType = 12
+	// RawFlagRouterMessage is set for messages received from a router
+	RawFlagRouterMessage = 0x80
+	// RawFlagIPv6 is set when the router IP is IPv6
+	RawFlagIPv6 = 0x40
+	// rawFixedHeaderLength defines the length of the header without Collector Admin ID and Router Group
+	rawFixedHeaderLength = 78
+)
+
+// RawHeader defines OpenBMP binary header prepended to raw BMP messages
+type RawHeader struct {
+	Timestamp        time.Time
+	CollectorHash    [16]byte
+	CollectorAdminID string
+	RouterHash       [16]byte
+	RouterIP         net.IP
+	RouterGroup      string
+}
+
+// MarshalRawMessage returns the raw BMP message prepended with OpenBMP binary header,
+// IPv4 router IP is stored in the first 4 bytes of the Router IP field.
+func MarshalRawMessage(h *RawHeader, msg []byte) []byte {
+	hl := rawFixedHeaderLength + len(h.CollectorAdminID) + len(h.RouterGroup)
+	b := make([]byte, hl+len(msg))
+	binary.BigEndian.PutUint32(b[0:4], RawMagic)
+	b[4] = RawMajorVersion
+	b[5] = RawMinorVersion
+	binary.BigEndian.PutUint16(b[6:8], uint16(hl))
+	binary.BigEndian.PutUint32(b[8:12], uint32(len(msg)))
+	b[12] = RawFlagRouterMessage
+	b[13] = RawMessageType
+	binary.BigEndian.PutUint32(b[14:18], uint32(h.Timestamp.Unix()))
+	binary.BigEndian.PutUint32(b[18:22], uint32(h.Timestamp.Nanosecond()/1000))
+	p := 22
+	p += copy(b[p:], h.CollectorHash[:])
+	binary.BigEndian.PutUint16(b[p:p+2], uint16(len(h.CollectorAdminID)))
+	p += 2
+	p += copy(b[p:], h.CollectorAdminID)
+	p += copy(b[p:], h.RouterHash[:])
+	if ip := h.RouterIP.To4(); ip != nil {
+		copy(b[p:], ip)
+	} else {
+		b[12] |= RawFlagIPv6
+		copy(b[p:], h.RouterIP.To16())
+	}
+	p += 16
+	binary.BigEndian.PutUint16(b[p:p+2], uint16(len(h.RouterGroup)))
+	p += 2
+	p += copy(b[p:], h.RouterGroup)
+	// Row count, a raw message carries a single BMP message
+	binary.BigEndian.PutUint32(b[p:p+4], 1)
+	p += 4
+	copy(b[p:], msg)
+
+	return b
+}
+
+// UnmarshalRawMessage validates OpenBMP binary header and returns the header and the raw BMP message
+func UnmarshalRawMessage(b []byte) (*RawHeader, []byte, error) {
+	if len(b) < rawFixedHeaderLength {
+		return nil, nil, fmt.Errorf("not enough bytes to unmarshal raw message header")
+	}
+	if binary.BigEndian.Uint32(b[0:4]) != RawMagic {
+		return nil, nil, fmt.Errorf("invalid magic number of raw message header")
+	}
+	if b[4] != RawMajorVersion || b[13] != RawMessageType {
+		return nil, nil, fmt.Errorf("unsupported version %d.%d or type %d of raw message header", b[4], b[5], b[13])
+	}
+	hl := int(binary.BigEndian.Uint16(b[6:8]))
+	ml := int(binary.BigEndian.Uint32(b[8:12]))
+	if hl < rawFixedHeaderLength || hl+ml != len(b) {
+		return nil, nil, fmt.Errorf("invalid header length %d or message length %d of raw message", hl, ml)
+	}
+	h := &RawHeader{
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint32(b[14:18])), int64(binary.BigEndian.Uint32(b[18:22]))*1000),
+	}
+	p := 22
+	p += copy(h.CollectorHash[:], b[p:p+16])
+	l := int(binary.BigEndian.Uint16(b[p : p+2]))
+	p += 2
+	if p+l+16+16+2 > hl {
+		return nil, nil, fmt.Errorf("invalid collector admin id length %d of raw message header", l)
+	}
+	h.CollectorAdminID = string(b[p : p+l])
+	p += l
+	p += copy(h.RouterHash[:], b[p:p+16])
+	if b[12]&RawFlagIPv6 != 0 {
+		h.RouterIP = net.IP(append([]byte{}, b[p:p+16]...))
+	} else {
+		h.RouterIP = net.IP(append([]byte{}, b[p:p+4]...))
+	}
+	p += 16
+	l = int(binary.BigEndian.Uint16(b[p : p+2]))
+	p += 2
+	if p+l+4 != hl {
+		return nil, nil, fmt.Errorf("invalid router group length %d of raw message header", l)
+	}
+	h.RouterGroup = string(b[p : p+l])
+
+	return h, b[hl:], nil
+}
@@ -0,0 +1,92 @@
+package pub
+
+import (
+	"bytes"
+	"crypto/md5"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRawMessage(t *testing.T) {
+	msg := []byte{3, 0, 0, 0, 6, 4}
+	ts := time.Unix(1700000000, 123456000)
+	tests := []struct {
+		name   string
+		header *RawHeader
+		ipv6   bool
+	}{
+		{
+			name: "ipv4 router",
+			header: &RawHeader{
+				Timestamp:        ts,
+				CollectorHash:    md5.Sum([]byte("collector1")),
+				CollectorAdminID: "collector1",
+				RouterHash:       md5.Sum([]byte("192.0.2.1")),
+				RouterIP:         net.ParseIP("192.0.2.1").To4(),
+			},
+		},
+		{
+			name: "ipv6 router with group",
+			header: &RawHeader{
+				Timestamp:     ts,
+				CollectorHash: md5.Sum([]byte("")),
+				RouterHash:    md5.Sum([]byte("2001:db8::1")),
+				RouterIP:      net.ParseIP("2001:db8::1"),
+				RouterGroup:   "edge",
+			},
+			ipv6: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := MarshalRawMessage(tt.header, msg)
+			if !bytes.Equal(b[0:4], []byte("OBMP")) {
+				t.Fatalf("invalid magic number %q", b[0:4])
+			}
+			if (b[12]&RawFlagIPv6 != 0) != tt.ipv6 || b[12]&RawFlagRouterMessage == 0 {
+				t.Errorf("invalid flags 0x%02x", b[12])
+			}
+			h, m, err := UnmarshalRawMessage(b)
+			if err != nil {
+				t.Fatalf("failed to unmarshal raw message with error: %+v", err)
+			}
+			if !bytes.Equal(m, msg) {
+				t.Errorf("expected message %v got %v", msg, m)
+			}
+			if !h.Timestamp.Equal(tt.header.Timestamp) || h.CollectorHash != tt.header.CollectorHash ||
+				h.CollectorAdminID != tt.header.CollectorAdminID || h.RouterHash != tt.header.RouterHash ||
+				!h.RouterIP.Equal(tt.header.RouterIP) || h.RouterGroup != tt.header.RouterGroup {
+				t.Errorf("expected header %+v got %+v", tt.header, h)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRawMessageErrors(t *testing.T) {
+	valid := MarshalRawMessage(&RawHeader{RouterIP: net.IPv4(192, 0, 2, 1)}, []byte{3, 0, 0, 0, 6, 4})
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "too short",
+			input: valid[:20],
+		},
+		{
+			name:  "invalid magic",
+			input: append([]byte("XBMP"), valid[4:]...),
+		},
+		{
+			name:  "truncated message",
+			input: valid[:len(valid)-1],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := UnmarshalRawMessage(tt.input); err == nil {
+				t.Fatalf("supposed to fail but succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package tunnelencap
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"github.com/golang/glog"
+	"github.com/sbezverk/gobmp/pkg/prefixsid"
+	"github.com/sbezverk/gobmp/pkg/srpolicy"
+	"github.com/sbezverk/tools"
+)
+
+// Tunnel Types
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#tunnel-types
+const (
+	L2TPv3           = 1
+	GRE              = 2
+	IPinIP           = 7
+	VXLAN            = 8
+	NVGRE            = 9
+	MPLS             = 10
+	MPLSinGRE        = 11
+	VXLANGPE         = 12
+	MPLSinUDP        = 13
+	IPv6Tunnel       = 14
+	SRPolicy         = 15
+	Bare             = 16
+	SRTunnel         = 17
+	Geneve           = 19
+	AnyEncapsulation = 20
+)
+
+var tunnelTypes = map[uint16]string{
+	L2TPv3:           "L2TPv3 over IP",
+	GRE:              "GRE",
+	3:                "Transmit tunnel endpoint",
+	4:                "IPsec in Tunnel-mode",
+	5:                "IP in IP tunnel with IPsec Transport Mode",
+	6:                "MPLS-in-IP tunnel with IPsec Transport Mode",
+	IPinIP:           "IP in IP",
+	VXLAN:            "VXLAN",
+	NVGRE:            "NVGRE",
+	MPLS:             "MPLS",
+	MPLSinGRE:        "MPLS in GRE",
+	VXLANGPE:         "VXLAN GPE",
+	MPLSinUDP:        "MPLS in UDP",
+	IPv6Tunnel:       "IPv6 Tunnel",
+	SRPolicy:         "SR Policy",
+	Bare:             "Bare",
+	SRTunnel:         "SR Tunnel",
+	18:               "Cloud Security",
+	Geneve:           "Geneve",
+	AnyEncapsulation: "Any-Encapsulation",
+}
+
+// TunnelTypeString returns the name of the tunnel type
+func TunnelTypeString(t uint16) string {
+	return tunnelTypes[t]
+}
+
+// Sub-TLV Types
+// https://www.iana.org/assignments/bgp-parameters/bgp-parameters.xhtml#encaps-sub-tlv-types
+const (
+	EncapsulationSTLV         = 1
+	ProtocolTypeSTLV          = 2
+	ColorSTLV                 = 4
+	TunnelEgressEndpointSTLV  = 6
+	DSFieldSTLV               = 7
+	UDPDestinationPortSTLV    = 8
+	EmbeddedLabelHandlingSTLV = 9
+	MPLSLabelStackSTLV        = 10
+	PrefixSIDSTLV             = 11
+)
+
+// Encapsulation defines Encapsulation sub-TLV, fields are set according to the tunnel type, RFC 9012 section 3.2.
+// Value carries the sub-TLV of other tunnel types as hex.
+type Encapsulation struct {
+	VNID      uint32 `json:"vn_id,omitempty"`
+	MAC       string `json:"mac,omitempty"`
+	Version   uint8  `json:"version,omitempty"`
+	Key       uint32 `json:"key,omitempty"`
+	SessionID uint32 `json:"session_id,omitempty"`
+	Cookie    string `json:"cookie,omitempty"`
+	Value     string `json:"value,omitempty"`
+}
+
+// LabelStackEntry defines an entry of MPLS Label Stack sub-TLV, RFC 9012 section 3.6
+type LabelStackEntry struct {
+	Label uint32 `json:"label"`
+	TC    uint8  `json:"tc,omitempty"`
+	S     bool   `json:"s,omitempty"`
+	TTL   uint8  `json:"ttl,omitempty"`
+}
+
+// SubTLV defines a sub-TLV which is not decoded
+type SubTLV struct {
+	Type  uint8  `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// Tunnel defines Tunnel TLV of Tunnel Encapsulation attribute, RFC 9012. Sub-TLVs of SR Policy tunnel
+// are decoded in SRPolicy.
+type Tunnel struct {
+	Type                  uint16            `json:"type"`
+	TypeText              string            `json:"type_text,omitempty"`
+	Encapsulation         *Encapsulation    `json:"encapsulation,omitempty"`
+	ProtocolType          uint16            `json:"protocol_type,omitempty"`
+	Color                 uint32            `json:"color,omitempty"`
+	EgressEndpoint        string            `json:"egress_endpoint,omitempty"`
+	DSField               uint8             `json:"ds_field,omitempty"`
+	UDPDestinationPort    uint16            `json:"udp_destination_port,omitempty"`
+	EmbeddedLabelHandling uint8             `json:"embedded_label_handling,omitempty"`
+	MPLSLabelStack        []LabelStackEntry `json:"mpls_label_stack,omitempty"`
+	PrefixSID             *prefixsid.PSid   `json:"prefix_sid,omitempty"`
+	SRPolicy              *srpolicy.TLV     `json:"sr_policy,omitempty"`
+	UnknownSubTLVs        []SubTLV          `json:"unknown_sub_tlvs,omitempty"`
+}
+
+// UnmarshalTunnelEncapAttr builds a slice of Tunnel TLVs of Tunnel Encapsulation attribute, tunnels with malformed
+// sub-TLVs are skipped, an error is returned when the length of any Tunnel TLV is invalid.
+func UnmarshalTunnelEncapAttr(b []byte) ([]Tunnel, error) {
+	if glog.V(6) {
+		glog.Infof("Tunnel Encapsulation Raw: %s", tools.MessageHex(b))
+	}
+	tunnels := make([]Tunnel, 0)
+	for p := 0; p < len(b); {
+		if p+4 > len(b) {
+			return nil, fmt.Errorf("invalid length of Tunnel TLV")
+		}
+		t := binary.BigEndian.Uint16(b[p : p+2])
+		l := int(binary.BigEndian.Uint16(b[p+2 : p+4]))
+		if p+4+l > len(b) {
+			return nil, fmt.Errorf("invalid length %d of Tunnel TLV of type %d", l, t)
+		}
+		// A malformed tunnel is skipped, other tunnels of the attribute are kept, RFC 9012 section 13
+		tunnel, err := unmarshalTunnel(t, b[p:p+4+l])
+		if err != nil {
+			glog.Warningf("skipping malformed Tunnel TLV of type %d with error: %+v", t, err)
+		} else {
+			tunnels = append(tunnels, *tunnel)
+		}
+		p += 4 + l
+	}
+
+	return tunnels, nil
+}
+
+// unmarshalTunnel builds Tunnel TLV object from the TLV including Type and Length fields
+func unmarshalTunnel(t uint16, b []byte) (*Tunnel, error) {
+	tunnel := &Tunnel{
+		Type:     t,
+		TypeText: TunnelTypeString(t),
+	}
+	if t == SRPolicy {
+		tlv, err := srpolicy.UnmarshalSRPolicyTLV(b)
+		if err != nil {
+			return nil, err
+		}
+		tunnel.SRPolicy = tlv
+		return tunnel, nil
+	}
+	for p := 4; p < len(b); {
+		st := b[p]
+		p++
+		var sl int
+		// Sub-TLVs of types 128 to 255 have 2 bytes length
+		if st >= 128 {
+			if p+2 > len(b) {
+				return nil, fmt.Errorf("invalid length of sub-TLV of type %d", st)
+			}
+			sl = int(binary.BigEndian.Uint16(b[p : p+2]))
+			p += 2
+		} else {
+			if p+1 > len(b) {
+				return nil, fmt.Errorf("invalid length of sub-TLV of type %d", st)
+			}
+			sl = int(b[p])
+			p++
+		}
+		if p+sl > len(b) {
+			return nil, fmt.Errorf("invalid length %d of sub-TLV of type %d", sl, st)
+		}
+		if err := tunnel.unmarshalSubTLV(st, b[p:p+sl]); err != nil {
+			return nil, err
+		}
+		p += sl
+	}
+
+	return tunnel, nil
+}
+
+func (t *Tunnel) unmarshalSubTLV(st uint8, b []byte) error {
+	var err error
+	switch st {
+	case EncapsulationSTLV:
+		t.Encapsulation = unmarshalEncapsulation(t.Type, b)
+	case ProtocolTypeSTLV:
+		if len(b) != 2 {
+			return fmt.Errorf("invalid length %d of Protocol Type sub-TLV", len(b))
+		}
+		t.ProtocolType = binary.BigEndian.Uint16(b)
+	case ColorSTLV:
+		// Color sub-TLV carries Color Extended Community
+		if len(b) != 8 {
+			return fmt.Errorf("invalid length %d of Color sub-TLV", len(b))
+		}
+		t.Color = binary.BigEndian.Uint32(b[4:])
+	case TunnelEgressEndpointSTLV:
+		if t.EgressEndpoint, err = unmarshalEgressEndpoint(b); err != nil {
+			return err
+		}
+	case DSFieldSTLV:
+		if len(b) != 1 {
+			return fmt.Errorf("invalid length %d of DS Field sub-TLV", len(b))
+		}
+		t.DSField = b[0]
+	case UDPDestinationPortSTLV:
+		if len(b) != 2 {
+			return fmt.Errorf("invalid length %d of UDP Destination Port sub-TLV", len(b))
+		}
+		t.UDPDestinationPort = binary.BigEndian.Uint16(b)
+	case EmbeddedLabelHandlingSTLV:
+		if len(b) != 1 {
+			return fmt.Errorf("invalid length %d of Embedded Label Handling sub-TLV", len(b))
+		}
+		t.EmbeddedLabelHandling = b[0]
+	case MPLSLabelStackSTLV:
+		if len(b)%4 != 0 {
+			return fmt.Errorf("invalid length %d of MPLS Label Stack sub-TLV", len(b))
+		}
+		t.MPLSLabelStack = make([]LabelStackEntry, 0, len(b)/4)
+		for p := 0; p < len(b); p += 4 {
+			e := binary.BigEndian.Uint32(b[p : p+4])
+			t.MPLSLabelStack = append(t.MPLSLabelStack, LabelStackEntry{
+				Label: e >> 12,
+				TC:    uint8(e>>9) & 0x7,
+				S:     e&0x100 == 0x100,
+				TTL:   uint8(e),
+			})
+		}
+	case PrefixSIDSTLV:
+		if t.PrefixSID, err = prefixsid.UnmarshalBGPAttrPrefixSID(b); err != nil {
+			return err
+		}
+	default:
+		t.UnknownSubTLVs = append(t.UnknownSubTLVs, SubTLV{Type: st, Value: hex.EncodeToString(b)})
+	}
+
+	return nil
+}
+
+// unmarshalEncapsulation returns Encapsulation sub-TLV of the tunnel type, the sub-TLV of unknown
+// or malformed encapsulation is returned as hex.
+func unmarshalEncapsulation(t uint16, b []byte) *Encapsulation {
+	e := &Encapsulation{}
+	switch {
+	case (t == VXLAN || t == NVGRE) && len(b) == 12:
+		// V bit signals VN-ID and M bit signals MAC address
+		if b[0]&0x80 == 0x80 {
+			e.VNID = uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+		}
+		if b[0]&0x40 == 0x40 {
+			e.MAC = net.HardwareAddr(b[4:10]).String()
+		}
+	case t == VXLANGPE && len(b) == 8:
+		e.Version = b[0] >> 6
+		if b[0]&0x20 == 0x20 {
+			e.VNID = uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+		}
+	case (t == GRE || t == MPLSinGRE) && len(b) == 4:
+		e.Key = binary.BigEndian.Uint32(b)
+	case t == L2TPv3 && len(b) >= 4 && len(b) <= 12:
+		e.SessionID = binary.BigEndian.Uint32(b[:4])
+		if len(b) > 4 {
+			e.Cookie = hex.EncodeToString(b[4:])
+		}
+	default:
+		e.Value = hex.EncodeToString(b)
+	}
+
+	return e
+}
+
+// unmarshalEgressEndpoint returns the address of Tunnel Egress Endpoint sub-TLV, RFC 9012 section 3.1
+func unmarshalEgressEndpoint(b []byte) (string, error) {
+	if len(b) < 6 {
+		return "", fmt.Errorf("invalid length %d of Tunnel Egress Endpoint sub-TLV", len(b))
+	}
+	// 4 bytes are reserved
+	afi := binary.BigEndian.Uint16(b[4:6])
+	addr := b[6:]
+	switch {
+	case afi == 0 && len(addr) == 0:
+		return "", nil
+	case afi == 1 && len(addr) == 4, afi == 2 && len(addr) == 16:
+		return net.IP(addr).String(), nil
+	}
+
+	return "", fmt.Errorf("invalid address of family %d of Tunnel Egress Endpoint sub-TLV", afi)
+}
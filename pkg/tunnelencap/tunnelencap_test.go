@@ -0,0 +1,164 @@
+package tunnelencap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/sbezverk/gobmp/pkg/prefixsid"
+	"github.com/sbezverk/gobmp/pkg/srpolicy"
+)
+
+func TestUnmarshalTunnelEncapAttr(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		expect []Tunnel
+		fail   bool
+	}{
+		{
+			name: "vxlan",
+			input: []byte{
+				0, VXLAN, 0, 40,
+				EncapsulationSTLV, 12, 0xc0, 0, 0, 100, 0, 0x11, 0x22, 0x33, 0x44, 0x55, 0, 0,
+				UDPDestinationPortSTLV, 2, 0x12, 0xb5,
+				TunnelEgressEndpointSTLV, 10, 0, 0, 0, 0, 0, 1, 192, 0, 2, 1,
+				ColorSTLV, 8, 0x03, 0x0b, 0, 0, 0, 0, 0, 100,
+			},
+			expect: []Tunnel{
+				{
+					Type:               VXLAN,
+					TypeText:           "VXLAN",
+					Encapsulation:      &Encapsulation{VNID: 100, MAC: "00:11:22:33:44:55"},
+					UDPDestinationPort: 4789,
+					EgressEndpoint:     "192.0.2.1",
+					Color:              100,
+				},
+			},
+		},
+		{
+			name: "mpls in gre",
+			input: []byte{
+				0, MPLSinGRE, 0, 16,
+				EncapsulationSTLV, 4, 0, 0, 0, 5,
+				ProtocolTypeSTLV, 2, 0x88, 0x47,
+				MPLSLabelStackSTLV, 4, 0, 0x01, 0x01, 0x40,
+			},
+			expect: []Tunnel{
+				{
+					Type:           MPLSinGRE,
+					TypeText:       "MPLS in GRE",
+					Encapsulation:  &Encapsulation{Key: 5},
+					ProtocolType:   0x8847,
+					MPLSLabelStack: []LabelStackEntry{{Label: 16, S: true, TTL: 64}},
+				},
+			},
+		},
+		{
+			name: "nvgre with unknown sub-tlv",
+			input: []byte{
+				0, NVGRE, 0, 8,
+				DSFieldSTLV, 1, 0x2e,
+				200, 0, 2, 0xab, 0xcd,
+			},
+			expect: []Tunnel{
+				{
+					Type:           NVGRE,
+					TypeText:       "NVGRE",
+					DSField:        0x2e,
+					UnknownSubTLVs: []SubTLV{{Type: 200, Value: "abcd"}},
+				},
+			},
+		},
+		{
+			name: "sr policy and mpls tunnels",
+			input: []byte{
+				0, SRPolicy, 0, 8,
+				12, 6, 0, 0, 0, 0, 0, 100,
+				0, MPLS, 0, 12,
+				PrefixSIDSTLV, 10, 1, 0, 7, 0, 0, 0, 0, 0, 0, 5,
+			},
+			expect: []Tunnel{
+				{
+					Type:     SRPolicy,
+					TypeText: "SR Policy",
+					SRPolicy: &srpolicy.TLV{
+						Preference:  &srpolicy.Preference{Preference: 100},
+						SegmentList: []*srpolicy.SegmentList{},
+					},
+				},
+				{
+					Type:     MPLS,
+					TypeText: "MPLS",
+					PrefixSID: &prefixsid.PSid{
+						LabelIndex: &prefixsid.LabelIndexTLV{Type: 1, Length: 7, LabelIndex: 5},
+					},
+				},
+			},
+		},
+		{
+			name: "unknown encapsulation",
+			input: []byte{
+				0, Geneve, 0, 5,
+				EncapsulationSTLV, 3, 1, 2, 3,
+			},
+			expect: []Tunnel{
+				{
+					Type:          Geneve,
+					TypeText:      "Geneve",
+					Encapsulation: &Encapsulation{Value: "010203"},
+				},
+			},
+		},
+		{
+			name:  "truncated tunnel",
+			input: []byte{0, VXLAN, 0, 8, UDPDestinationPortSTLV, 2, 0x12, 0xb5},
+			fail:  true,
+		},
+		{
+			name:   "truncated sub-tlv",
+			input:  []byte{0, VXLAN, 0, 4, UDPDestinationPortSTLV, 3, 0x12, 0xb5},
+			expect: []Tunnel{},
+		},
+		{
+			name:   "invalid egress endpoint",
+			input:  []byte{0, VXLAN, 0, 10, TunnelEgressEndpointSTLV, 8, 0, 0, 0, 0, 0, 2, 192, 0},
+			expect: []Tunnel{},
+		},
+		{
+			name: "malformed tunnel skipped",
+			input: []byte{
+				0, VXLAN, 0, 4, UDPDestinationPortSTLV, 3, 0x12, 0xb5,
+				0, MPLS, 0, 12,
+				PrefixSIDSTLV, 10, 1, 0, 7, 0, 0, 0, 0, 0, 0, 5,
+			},
+			expect: []Tunnel{
+				{
+					Type:     MPLS,
+					TypeText: "MPLS",
+					PrefixSID: &prefixsid.PSid{
+						LabelIndex: &prefixsid.LabelIndexTLV{Type: 1, Length: 7, LabelIndex: 5},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalTunnelEncapAttr(tt.input)
+			if err != nil {
+				if !tt.fail {
+					t.Fatalf("expected to succeed but failed with error: %+v", err)
+				}
+				return
+			}
+			if tt.fail {
+				t.Fatal("expected to fail but succeeded")
+			}
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Logf("Diffs: %+v", deep.Equal(got, tt.expect))
+				t.Fatal("decoded and expected tunnels do not match")
+			}
+		})
+	}
+}
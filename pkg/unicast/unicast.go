@@ -28,11 +28,18 @@ func UnmarshalUnicastNLRI(b []byte, pathID bool) (*base.MPNLRI, error) {
 	return &mpnlri, nil
 }
 
-// UnmarshalLUNLRI builds MP NLRI object from the slice of bytes
-func UnmarshalLUNLRI(b []byte, pathID bool) (*base.MPNLRI, error) {
+// UnmarshalLUNLRI builds MP NLRI object from the slice of bytes, when multiLabel is true, labels are decoded
+// up to the one with Bottom of Stack bit set, otherwise each prefix carries a single label.
+func UnmarshalLUNLRI(b []byte, pathID, multiLabel bool) (*base.MPNLRI, error) {
 	if glog.V(6) {
-		glog.Infof("MP Label Unicast NLRI Raw: %s path id flag: %t", tools.MessageHex(b), pathID)
+		glog.Infof("MP Label Unicast NLRI Raw: %s path id flag: %t multiple labels flag: %t", tools.MessageHex(b), pathID, multiLabel)
 	}
+	return unmarshalLUNLRI(b, pathID, multiLabel, true)
+}
+
+// unmarshalLUNLRI builds MP NLRI object, when retry is true and the object cannot be built, the opposite value
+// of pathID is tried
+func unmarshalLUNLRI(b []byte, pathID, multiLabel, retry bool) (*base.MPNLRI, error) {
 	mpnlri := base.MPNLRI{
 		NLRI: make([]base.Route, 0),
 	}
@@ -75,6 +82,10 @@ func UnmarshalLUNLRI(b []byte, pathID bool) (*base.MPNLRI, error) {
 			up.Label = make([]*base.Label, 0)
 			bos := false
 			for !bos && p < len(b) {
+				if p+3 > len(b) {
+					err = fmt.Errorf("not enough bytes to reconstruct labeled unicast prefix")
+					goto error_handle
+				}
 				l, e := base.MakeLabel(b[p : p+3])
 				if e != nil {
 					err = e
@@ -82,7 +93,7 @@ func UnmarshalLUNLRI(b []byte, pathID bool) (*base.MPNLRI, error) {
 				}
 				up.Label = append(up.Label, l)
 				p += 3
-				bos = l.BoS
+				bos = l.BoS || !multiLabel
 			}
 		}
 		// Adjusting prefix length to remove bits used by labels each label takes 3 bytes, or 3 bytes
@@ -112,7 +123,10 @@ error_handle:
 		// might be advertised and received, but BGP Update would not have PathID set due to some other conditions,
 		// example when bgp speakers are in different AS. In error handle, attempting to Unmarshal again with reversed
 		// value of PathID flag.
-		if u, e := UnmarshalLUNLRI(b, !pathID); e == nil {
+		if !retry {
+			return nil, err
+		}
+		if u, e := unmarshalLUNLRI(b, !pathID, multiLabel, false); e == nil {
 			return u, nil
 		}
 		glog.Errorf("failed to reconstruct labeled unicast prefix from slice %s with error: %+v", tools.MessageHex(b), err)
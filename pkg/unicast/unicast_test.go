@@ -181,10 +181,11 @@ func TestUnmarshalUnicastNLRI(t *testing.T) {
 
 func TestUnmarshalLUNLRI(t *testing.T) {
 	tests := []struct {
-		name   string
-		input  []byte
-		expect *base.MPNLRI
-		pathID bool
+		name        string
+		input       []byte
+		expect      *base.MPNLRI
+		pathID      bool
+		singleLabel bool
 	}{
 		{
 			name:  "mp unicast nlri 1",
@@ -342,10 +343,42 @@ func TestUnmarshalLUNLRI(t *testing.T) {
 			},
 			pathID: true,
 		},
+		{
+			name:  "multiple labels",
+			input: []byte{0x38, 0x00, 0x01, 0x00, 0x00, 0x01, 0x11, 0x0a},
+			expect: &base.MPNLRI{
+				NLRI: []base.Route{
+					{
+						Length: 8,
+						Prefix: []byte{0x0a},
+						Label: []*base.Label{
+							{Value: 16},
+							{Value: 17, BoS: true},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "single label without bottom of stack",
+			input: []byte{0x38, 0x00, 0x01, 0x00, 0x00, 0x01, 0x11, 0x0a},
+			expect: &base.MPNLRI{
+				NLRI: []base.Route{
+					{
+						Length: 32,
+						Prefix: []byte{0x00, 0x01, 0x11, 0x0a},
+						Label: []*base.Label{
+							{Value: 16},
+						},
+					},
+				},
+			},
+			singleLabel: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := UnmarshalLUNLRI(tt.input, tt.pathID)
+			got, err := UnmarshalLUNLRI(tt.input, tt.pathID, !tt.singleLabel)
 			if err != nil {
 				t.Fatalf("test failed with error: %+v", err)
 			}
@@ -356,3 +389,32 @@ func TestUnmarshalLUNLRI(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalLUNLRIMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  []byte
+		pathID bool
+	}{
+		{
+			name:  "truncated label",
+			input: []byte{0x38, 0x00, 0x00},
+		},
+		{
+			name:  "truncated second label",
+			input: []byte{0x38, 0x00, 0x00, 0x30, 0x0a},
+		},
+		{
+			name:   "truncated label with path id",
+			input:  []byte{0, 0, 0, 1, 0x38, 0x00, 0x00},
+			pathID: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnmarshalLUNLRI(tt.input, tt.pathID, true); err == nil {
+				t.Fatal("expected to fail but succeeded")
+			}
+		})
+	}
+}